@@ -40,6 +40,13 @@ TEST_PACKAGES = [
     "pkg/lexer",
     "pkg/ast",
     "pkg/parser",
+    "pkg/checker",
+    "pkg/loader",
+    "pkg/tpm",
+    "pkg/bindgen",
+    "pkg/mangle",
+    "pkg/lsp",
+    "pkg/utils",
 ]
 
 VERSION = "alpha_0.1.0"
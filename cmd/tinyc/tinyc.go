@@ -27,9 +27,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/tinylang-org/tiny/pkg/bindgen"
 	"github.com/tinylang-org/tiny/pkg/lexer"
+	"github.com/tinylang-org/tiny/pkg/loader"
+	"github.com/tinylang-org/tiny/pkg/lsp"
+	"github.com/tinylang-org/tiny/pkg/mangle"
 	"github.com/tinylang-org/tiny/pkg/parser"
 	"github.com/tinylang-org/tiny/pkg/repr"
 	"github.com/tinylang-org/tiny/pkg/utils"
@@ -48,7 +54,7 @@ var parserPromptCmd = &cobra.Command{
 
 			lineBytes = lineBytes[:len(lineBytes)-1]
 
-			ph := utils.NewCodeProblemHandler()
+			ph := newProblemHandler()
 			ph.SetSource(lineBytes)
 
 			p := parser.NewParser("<repl>", lineBytes, ph)
@@ -77,7 +83,7 @@ var lexPromptCmd = &cobra.Command{
 			//line = strings.TrimRight(line, "\r\n")
 			lineBytes := []byte(line)
 
-			ph := utils.NewCodeProblemHandler()
+			ph := newProblemHandler()
 			ph.SetSource(lineBytes)
 
 			l := lexer.NewLexer("<repl>", lineBytes, ph)
@@ -162,7 +168,7 @@ var lexCmd = &cobra.Command{
 	Use:   "lex",
 	Short: "Lexer",
 	Run: func(cmd *cobra.Command, args []string) {
-		gh := utils.NewCodeProblemHandler()
+		gh := newProblemHandler()
 
 		if len(args) != 1 {
 			fmt.Println("required format: tinyc lex <filename>")
@@ -171,12 +177,12 @@ var lexCmd = &cobra.Command{
 
 		fileContent, err := ioutil.ReadFile(args[0])
 		if err != nil {
-			gh.AddCodeProblem(utils.NewGlobalError(utils.UnableToReadFileErr, []interface{}{args[0]}))
+			gh.AddCodeProblem(utils.NewGlobalError(utils.UnableToReadFileErr, args[0]))
 			gh.PrintDiagnostics()
 			os.Exit(1)
 		}
 
-		ph := utils.NewCodeProblemHandler()
+		ph := newProblemHandler()
 		ph.SetSource(fileContent)
 		l := lexer.NewLexer(args[0], fileContent, ph)
 		for {
@@ -200,16 +206,366 @@ var lexCmd = &cobra.Command{
 	},
 }
 
+var buildCmd = &cobra.Command{
+	Use:   "build [packages]",
+	Short: "Build packages",
+	Long: `Build loads packages matched by the arguments together with all packages
+they import and checks them.
+
+Argument is either a directory or a directory followed by "/...", which matches
+all packages in the directory and its subdirectories. Default is ".".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		gh := newProblemHandler()
+		gh.SetColorfulOutput()
+
+		l, _ := loadPackages(cmd, args, gh)
+		printPackageProblems(l, gh)
+	},
+}
+
+// loadPackages loads and checks packages matched by the arguments, see build.
+// Returns the loader and matched packages.
+func loadPackages(cmd *cobra.Command, args []string,
+	gh *utils.CodeProblemHandler) (*loader.Loader, []*loader.Package) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	searchPaths, _ := cmd.Flags().GetStringSlice("search-path")
+	l := loader.NewLoader(loader.Config{Root: ".", SearchPaths: searchPaths}, gh)
+	packages := l.Load(args...)
+	l.Check()
+
+	return l, packages
+}
+
+// printPackageProblems prints problems found in loaded packages and exits if
+// there are errors. Problems of all files are printed by gh, so they form a
+// single SARIF log.
+func printPackageProblems(l *loader.Loader, gh *utils.CodeProblemHandler) {
+	l.CollectProblems(gh)
+	gh.PrintDiagnostics()
+
+	if !gh.Ok {
+		os.Exit(1)
+	}
+}
+
+// maxFixPasses limits how many times packages are checked again after fixes
+// are applied.
+const maxFixPasses = 10
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [packages]",
+	Short: "Apply suggested fixes",
+	Long: `Fix loads packages like build does and applies fixes suggested for their
+problems, like insertion of a missing semicolon, to the source files in place.
+Packages are checked again after every change, since a fixed problem may hide
+other ones. Problems, which can't be fixed, are printed.
+
+Imported packages are not changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fixed := map[string]int{}
+		paths := []string{}
+
+		for pass := 0; pass < maxFixPasses; pass++ {
+			_, packages := loadPackages(cmd, args, newProblemHandler())
+
+			changed := false
+			for _, pkg := range packages {
+				for _, file := range pkg.Files {
+					fixes := []*utils.Fix{}
+					for _, problem := range file.ProblemHandler.Problems() {
+						fixes = append(fixes, problem.Fixes()...)
+					}
+
+					source, applied := utils.ApplyFixes(file.Source, fixes)
+					if applied == 0 {
+						continue
+					}
+
+					if err := ioutil.WriteFile(file.Path, source, 0644); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+
+					if fixed[file.Path] == 0 {
+						paths = append(paths, file.Path)
+					}
+
+					fixed[file.Path] += applied
+					changed = true
+				}
+			}
+
+			if !changed {
+				break
+			}
+		}
+
+		for _, path := range paths {
+			fmt.Printf("%s: applied %d fix(-es)\n", path, fixed[path])
+		}
+
+		gh := newProblemHandler()
+		gh.SetColorfulOutput()
+
+		l, _ := loadPackages(cmd, args, gh)
+		printPackageProblems(l, gh)
+	},
+}
+
+var bindgenCmd = &cobra.Command{
+	Use:   "bindgen <header>",
+	Short: "Generate Tiny bindings for C header",
+	Long: `Bindgen generates Tiny source with extern declarations of functions and
+structures declared in the C header. Values of enums and integer macros are
+listed in comments.
+
+Namespace of generated source is the header name without extension by
+default.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		gh := newProblemHandler()
+
+		source, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			gh.AddCodeProblem(utils.NewGlobalError(utils.UnableToReadFileErr, args[0]))
+			gh.PrintDiagnostics()
+			os.Exit(1)
+		}
+
+		namespace, _ := cmd.Flags().GetString("namespace")
+		if namespace == "" {
+			namespace = headerNamespace(args[0])
+		}
+
+		longSize, _ := cmd.Flags().GetInt("long-size")
+
+		// declarations, which can't be bound, are reported at their lines
+		files := utils.NewFileSet()
+		files.AddFile(args[0], source)
+		gh.SetFileSet(files)
+
+		bindings, err := bindgen.Generate(args[0], source,
+			bindgen.Config{Namespace: namespace, LongSize: longSize}, gh)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		gh.PrintDiagnostics()
+		if !gh.Ok {
+			os.Exit(1)
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			os.Stdout.Write(bindings)
+			return
+		}
+
+		if err := ioutil.WriteFile(output, bindings, 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+// headerNamespace returns name of the header file without extension with
+// characters, which can't be used in namespace, replaced by '_'.
+func headerNamespace(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <code>",
+	Short: "Explain error code",
+	Long: `Explain prints detailed description of the error with the code shown in
+diagnostics, together with an example of source causing the error and its
+correction:
+
+  $ tinyc explain E0019`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		code, err := utils.ParseErrorID(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Print(utils.ExplainError(code))
+	},
+}
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run language server",
+	Long: `Lsp runs language server, which talks to the editor over standard input
+and output. It reports problems in open documents and provides go to
+definition, hover, document symbols and completion.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// standard output is used by the protocol
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var demangleCmd = &cobra.Command{
+	Use:   "demangle [symbols]",
+	Short: "Demangle Tiny symbols",
+	Long: `Demangle prints demangled names of symbols given as arguments. Without
+arguments it copies standard input to standard output replacing all Tiny
+symbols with their names, like c++filt:
+
+  $ ld main.o 2>&1 | tinyc demangle`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 0 {
+			for _, symbol := range args {
+				fmt.Println(mangle.DemangleText(symbol))
+			}
+			return
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+		writer := bufio.NewWriter(os.Stdout)
+		defer writer.Flush()
+
+		for {
+			line, err := reader.ReadString('\n')
+			writer.WriteString(mangle.DemangleText(line))
+
+			if err != nil {
+				return
+			}
+
+			// keep output interactive when used in a pipe
+			writer.Flush()
+		}
+	},
+}
+
+var nmCmd = &cobra.Command{
+	Use:   "nm [packages]",
+	Short: "List exported symbols of packages",
+	Long: `Nm loads and checks packages like build and prints symbols of their pub
+functions and pub methods of pub structures together with their signatures,
+sorted by symbols:
+
+  $ tinyc nm ./bank
+  _TN4bank4openE fun bank.open(): *bank.Account
+  _TN4bank7Account5checkE fun bank.Account.check(password: []u8): bool
+
+Generic functions and extern functions are not listed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		gh := newProblemHandler()
+		gh.SetColorfulOutput()
+
+		l, packages := loadPackages(cmd, args, gh)
+		printPackageProblems(l, gh)
+
+		for _, pkg := range packages {
+			for _, export := range pkg.Exports() {
+				fmt.Println(export.Symbol, export.Signature)
+			}
+		}
+	},
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "tinyc",
 	Short: "Compiler for tiny programming language",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		options, _ := cmd.Flags().GetStringArray("warning")
+		for _, option := range options {
+			if err := warningSettings.Set(option); err != nil {
+				return err
+			}
+		}
+
+		maxErrors, _ = cmd.Flags().GetInt("max-errors")
+		if maxErrors < 0 {
+			return fmt.Errorf("invalid --max-errors value %d", maxErrors)
+		}
+
+		name, _ := cmd.Flags().GetString("diagnostics-format")
+
+		format, err := utils.ParseDiagnosticsFormat(name)
+		diagnosticsFormat = format
+		return err
+	},
+}
+
+// diagnosticsFormat is set by `--diagnostics-format` flag.
+var diagnosticsFormat = utils.HumanFormat
+
+// warningSettings are set by `-W` flags.
+var warningSettings = utils.NewWarningSettings()
+
+// maxErrors is set by `--max-errors` flag.
+var maxErrors = 0
+
+// newProblemHandler returns problem handler, which prints problems in the
+// format, reports warnings and limits errors as chosen by user.
+func newProblemHandler() *utils.CodeProblemHandler {
+	h := utils.NewCodeProblemHandler()
+	h.SetDiagnosticsFormat(diagnosticsFormat)
+	h.SetWarningSettings(warningSettings)
+	h.SetMaxErrors(maxErrors)
+	return h
 }
 
 func main() {
+	rootCmd.PersistentFlags().String("diagnostics-format", "human",
+		"format of errors and warnings: human, json, sarif or gcc")
+	rootCmd.PersistentFlags().StringArrayP("warning", "W", []string{},
+		"enable warning (-W<name>), disable it (-Wno-<name>), enable all warnings (-Wall)\n"+
+			"or treat warnings as errors (-Werror, undone by -Wno-error); warnings are\n"+
+			"unused-import, shadowing (disabled by default), unreachable-code,\n"+
+			"incomplete-binding, leak, double-destroy, use-after-destroy,\n"+
+			"destroy-not-heap and incomplete-destroy")
+	rootCmd.PersistentFlags().Int("max-errors", 0,
+		"maximum amount of printed errors, 0 for no limit")
+
 	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(lexPromptCmd)
 	rootCmd.AddCommand(lexCmd)
 	rootCmd.AddCommand(parserPromptCmd)
+
+	buildCmd.Flags().StringSlice("search-path", []string{},
+		"directories to look for imported packages in")
+	rootCmd.AddCommand(buildCmd)
+
+	fixCmd.Flags().StringSlice("search-path", []string{},
+		"directories to look for imported packages in")
+	rootCmd.AddCommand(fixCmd)
+
+	bindgenCmd.Flags().StringP("namespace", "n", "", "namespace of generated source")
+	bindgenCmd.Flags().StringP("output", "o", "", "output file (default is stdout)")
+	bindgenCmd.Flags().Int("long-size", 8,
+		"size of long and size_t in bytes, 4 for 32-bit targets")
+	rootCmd.AddCommand(bindgenCmd)
+	rootCmd.AddCommand(demangleCmd)
+
+	nmCmd.Flags().StringSlice("search-path", []string{},
+		"directories to look for imported packages in")
+	rootCmd.AddCommand(nmCmd)
+	rootCmd.AddCommand(explainCmd)
+
+	// editors start language servers with `--stdio`, which is the only
+	// supported transport
+	lspCmd.Flags().Bool("stdio", true, "communicate over standard input and output")
+	rootCmd.AddCommand(lspCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,332 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinylang-org/tiny/pkg/loader"
+	"github.com/tinylang-org/tiny/pkg/tpm"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// registryFromFlags returns registry set by `--registry` flag, TPM_REGISTRY
+// environment variable or the default one in the home directory.
+func registryFromFlags(cmd *cobra.Command) *tpm.Registry {
+	root, _ := cmd.Flags().GetString("registry")
+
+	if root == "" {
+		root = os.Getenv("TPM_REGISTRY")
+	}
+
+	if root == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fail(err)
+		}
+
+		root = filepath.Join(home, ".tpm", "registry")
+	}
+
+	return tpm.NewRegistry(root)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "tpm:", err)
+	os.Exit(1)
+}
+
+func readManifest() *tpm.Manifest {
+	manifest, err := tpm.ReadManifest(tpm.ManifestFilename)
+	if err != nil {
+		fail(err)
+	}
+
+	return manifest
+}
+
+// lock resolves dependencies of the manifest and writes the lock file.
+// Versions locked in the previous lock file (it can be nil) are kept if
+// possible.
+func lock(manifest *tpm.Manifest, registry *tpm.Registry,
+	previous *tpm.Lockfile) *tpm.Lockfile {
+	lockfile, err := tpm.Resolve(manifest, registry, previous)
+	if err != nil {
+		fail(err)
+	}
+
+	if err := lockfile.Write(tpm.LockfileFilename); err != nil {
+		fail(err)
+	}
+
+	return lockfile
+}
+
+// readLockfile returns the lock file of the project, it is updated if it is
+// missing or doesn't match the manifest anymore.
+func readLockfile(manifest *tpm.Manifest, registry *tpm.Registry) *tpm.Lockfile {
+	lockfile := previousLockfile()
+	if lockfile == nil || !lockfile.Satisfies(manifest) {
+		return lock(manifest, registry, lockfile)
+	}
+
+	return lockfile
+}
+
+// previousLockfile returns the lock file of the project or nil if there is
+// none yet.
+func previousLockfile() *tpm.Lockfile {
+	lockfile, err := tpm.ReadLockfile(tpm.LockfileFilename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		fail(err)
+	}
+
+	return lockfile
+}
+
+// newestReleaseConstraint returns caret constraint of the newest release of
+// the package or the exact newest version if there are only prereleases.
+func newestReleaseConstraint(registry *tpm.Registry, name string) string {
+	versions, err := registry.Versions(name)
+	if err != nil {
+		fail(err)
+	}
+
+	if len(versions) == 0 {
+		fail(fmt.Errorf("package %s has no versions in registry %s", name, registry.Root))
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if len(versions[i].Prerelease) == 0 {
+			return "^" + versions[i].String()
+		}
+	}
+
+	return versions[len(versions)-1].String()
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init [name]",
+	Short: "Create manifest in the current directory",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := os.Stat(tpm.ManifestFilename); err == nil {
+			fail(fmt.Errorf("%s already exists", tpm.ManifestFilename))
+		}
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		} else {
+			directory, err := os.Getwd()
+			if err != nil {
+				fail(err)
+			}
+
+			name = filepath.Base(directory)
+		}
+
+		if err := tpm.ValidatePackageName(name); err != nil {
+			fail(err)
+		}
+
+		if err := tpm.NewManifest(name, "0.1.0").Write(tpm.ManifestFilename); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <package>[@constraint]",
+	Short: "Add dependency",
+	Long: `Add adds dependency to the manifest and updates the lock file.
+
+Constraint is either an exact version ("1.2.3" or "=1.2.3"), a caret range
+("^1.2.3") allowing compatible updates, a tilde range ("~1.2.3") allowing patch
+updates or "*". If it is not specified, the caret range of the newest release
+in the registry is used.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := registryFromFlags(cmd)
+		manifest := readManifest()
+
+		name, constraint := args[0], ""
+		if i := strings.Index(name, "@"); i != -1 {
+			name, constraint = name[:i], name[i+1:]
+		}
+
+		if err := tpm.ValidatePackageName(name); err != nil {
+			fail(err)
+		}
+
+		if constraint == "" {
+			constraint = newestReleaseConstraint(registry, name)
+		} else if _, err := tpm.ParseConstraint(constraint); err != nil {
+			fail(err)
+		}
+
+		manifest.Dependencies[name] = constraint
+		lock(manifest, registry, previousLockfile())
+
+		if err := manifest.Write(tpm.ManifestFilename); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <package>",
+	Short: "Remove dependency",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := registryFromFlags(cmd)
+		manifest := readManifest()
+
+		if _, ok := manifest.Dependencies[args[0]]; !ok {
+			fail(fmt.Errorf("%s is not a dependency of %s", args[0], manifest.Name))
+		}
+
+		delete(manifest.Dependencies, args[0])
+		lock(manifest, registry, previousLockfile())
+
+		if err := manifest.Write(tpm.ManifestFilename); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update [packages]",
+	Short: "Update locked versions of dependencies",
+	Long: `Update resolves dependencies again choosing the newest versions allowed by
+constraints. If packages are specified, only they are updated and other
+packages keep their locked versions when possible.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := registryFromFlags(cmd)
+		manifest := readManifest()
+
+		var previous *tpm.Lockfile
+		if len(args) != 0 {
+			previous = previousLockfile()
+		}
+
+		if previous != nil {
+			packages := []*tpm.LockedPackage{}
+			for _, locked := range previous.Packages {
+				updated := false
+				for _, name := range args {
+					updated = updated || locked.Name == name
+				}
+
+				if !updated {
+					packages = append(packages, locked)
+				}
+			}
+
+			previous.Packages = packages
+		}
+
+		lock(manifest, registry, previous)
+	},
+}
+
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Copy dependencies into vendor directory",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := registryFromFlags(cmd)
+		manifest := readManifest()
+		lockfile := readLockfile(manifest, registry)
+
+		if err := tpm.Vendor(lockfile, registry, manifest.Directory); err != nil {
+			fail(err)
+		}
+	},
+}
+
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build the project",
+	Long: `Build checks checksums of locked dependencies and builds all packages of
+the project. Dependencies are taken from the vendor directory if they are
+vendored and from the registry otherwise.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry := registryFromFlags(cmd)
+		manifest := readManifest()
+		lockfile := readLockfile(manifest, registry)
+
+		directories, err := tpm.PackageDirectories(lockfile, registry, manifest.Directory)
+		if err != nil {
+			fail(err)
+		}
+
+		maxErrors, _ := cmd.Flags().GetInt("max-errors")
+
+		gh := utils.NewCodeProblemHandler()
+		gh.SetColorfulOutput()
+		gh.SetMaxErrors(maxErrors)
+
+		l := loader.NewLoader(loader.Config{Root: ".", Packages: directories}, gh)
+		l.Load("./...")
+		l.Check()
+
+		// problems of all files are printed together like in tinyc
+		l.CollectProblems(gh)
+		gh.PrintDiagnostics()
+
+		if !gh.Ok {
+			os.Exit(1)
+		}
+	},
+}
+
+var rootCmd = &cobra.Command{
+	Use:   "tpm",
+	Short: "Package manager for tiny programming language",
+}
+
+func main() {
+	rootCmd.PersistentFlags().String("registry", "",
+		"registry directory (default is $TPM_REGISTRY or ~/.tpm/registry)")
+
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(vendorCmd)
+	buildCmd.Flags().Int("max-errors", 0, "maximum amount of printed errors, 0 for no limit")
+	rootCmd.AddCommand(buildCmd)
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -87,13 +87,34 @@ type FunctionDeclaration struct {
 	BlockLocation   *utils.CodeBlockLocation
 	Public          bool
 	Name            string
+	TypeParameters  []*TypeParameter
 	StatementsBlock *StatementsBlock
 	Arguments       []*FunctionArgument
+
+	// nil if function doesn't return anything
+	ReturnType Type
+
+	// true if function is declared with `fails`, so its calls must be
+	// handled with `handle`
+	Failable bool
 }
 
 func (f *FunctionDeclaration) Location() *utils.CodeBlockLocation { return f.BlockLocation }
 func (f *FunctionDeclaration) topLevelStatement()                 {}
 
+// TypeParameter describes generic type parameter, for example `T: Comparable`
+// in `fun max<T: Comparable>(a: T, b: T): T`.
+type TypeParameter struct {
+	BlockLocation *utils.CodeBlockLocation
+	Name          string
+
+	// interface, which type argument must implement, nil if type parameter
+	// is not constrained
+	Constraint *CustomType
+}
+
+func (t *TypeParameter) Location() *utils.CodeBlockLocation { return t.BlockLocation }
+
 type FunctionArgument struct {
 	BlockLocation *utils.CodeBlockLocation
 	Name          string
@@ -103,15 +124,74 @@ type FunctionArgument struct {
 func (a *FunctionArgument) Location() *utils.CodeBlockLocation { return a.BlockLocation }
 
 type StructureDeclaration struct {
+	BlockLocation  *utils.CodeBlockLocation
+	Public         bool
+	Name           string
+	TypeParameters []*TypeParameter
+	Methods        []*FunctionDeclaration
+	Members        []*StructureMember
+
+	// interfaces listed after ':' in structure declaration
+	Implements []*CustomType
+
+	// `destroy` generated by the checker for structures, which own heap
+	// members and don't declare their own destroy, nil otherwise
+	DefaultDestroy *FunctionDeclaration
+}
+
+func (s *StructureDeclaration) Location() *utils.CodeBlockLocation { return s.BlockLocation }
+func (s *StructureDeclaration) topLevelStatement()                 {}
+
+// FunctionSignature describes function without its body, for example
+// method of an interface.
+type FunctionSignature struct {
+	BlockLocation  *utils.CodeBlockLocation
+	Name           string
+	TypeParameters []*TypeParameter
+	Arguments      []*FunctionArgument
+
+	// true if arguments list ends with `...`
+	Variadic bool
+
+	// nil if function doesn't return anything
+	ReturnType Type
+
+	// true if signature ends with `fails`
+	Failable bool
+}
+
+func (f *FunctionSignature) Location() *utils.CodeBlockLocation { return f.BlockLocation }
+
+// ExternFunctionDeclaration describes function implemented outside of Tiny,
+// which is called using C calling convention, for example
+// `extern fun printf(fmt: *u8, ...): i32;`.
+type ExternFunctionDeclaration struct {
 	BlockLocation *utils.CodeBlockLocation
 	Public        bool
 	Name          string
-	Functions     *FunctionDeclaration
-	Members       []*StructureMember
+	Arguments     []*FunctionArgument
+
+	// true if function accepts variable amount of arguments after the
+	// listed ones, like C functions declared with `...`
+	Variadic bool
+
+	// nil if function doesn't return anything
+	ReturnType Type
 }
 
-func (s *StructureDeclaration) Location() *utils.CodeBlockLocation { return s.BlockLocation }
-func (s *StructureDeclaration) topLevelStatement()                 {}
+func (f *ExternFunctionDeclaration) Location() *utils.CodeBlockLocation { return f.BlockLocation }
+func (f *ExternFunctionDeclaration) topLevelStatement()                 {}
+
+type InterfaceDeclaration struct {
+	BlockLocation  *utils.CodeBlockLocation
+	Public         bool
+	Name           string
+	TypeParameters []*TypeParameter
+	Methods        []*FunctionSignature
+}
+
+func (i *InterfaceDeclaration) Location() *utils.CodeBlockLocation { return i.BlockLocation }
+func (i *InterfaceDeclaration) topLevelStatement()                 {}
 
 type StructureMember struct {
 	BlockLocation *utils.CodeBlockLocation
@@ -119,6 +199,10 @@ type StructureMember struct {
 	Readonly      bool
 	Name          string
 	Type          Type
+
+	// true if member is marked with `@nodestroy`, so the structure doesn't
+	// own its value and doesn't destroy it
+	NoDestroy bool
 }
 
 func (m *StructureMember) Location() *utils.CodeBlockLocation { return m.BlockLocation }
@@ -127,28 +211,97 @@ type StatementsBlock struct {
 	// location of '{'
 	StartLocation *utils.CodePointLocation
 	Statements    []Statement
+
+	// location of '}'
+	EndLocation *utils.CodePointLocation
 }
 
 func (s *StatementsBlock) Location() *utils.CodeBlockLocation {
 	return &utils.CodeBlockLocation{StartLocation: s.StartLocation,
-		EndLocation: s.Statements[len(s.Statements)-1].Location().EndLocation}
+		EndLocation: s.EndLocation}
 }
 
+func (s *StatementsBlock) statementNode() {}
+
+// var_statement = "var" identifier [ ":" type ] [ "=" expression ] ";" .
 type VarStatement struct {
 	// location of 'var'
-	startLocation *utils.CodePointLocation
-	name          *Name
-	var_type      Type
-	value         Expression
+	StartLocation *utils.CodePointLocation
+	Name          *Name
+
+	// nil if type is not written
+	Type Type
+
+	// nil if variable is not initialized
+	Value Expression
+
+	// location of ';'
+	EndLocation *utils.CodePointLocation
 }
 
 func (v *VarStatement) Location() *utils.CodeBlockLocation {
-	return &utils.CodeBlockLocation{StartLocation: v.startLocation,
-		EndLocation: v.value.Location().EndLocation}
+	return &utils.CodeBlockLocation{StartLocation: v.StartLocation,
+		EndLocation: v.EndLocation}
 }
 func (v *VarStatement) statementNode()     {}
 func (v *VarStatement) topLevelStatement() {}
 
+// AssignStatement describes assignment `target = value;`, where target is a
+// name, a member or an element of array.
+type AssignStatement struct {
+	Target Expression
+	Value  Expression
+
+	// location of ';'
+	EndLocation *utils.CodePointLocation
+}
+
+func (a *AssignStatement) Location() *utils.CodeBlockLocation {
+	return &utils.CodeBlockLocation{StartLocation: a.Target.Location().StartLocation,
+		EndLocation: a.EndLocation}
+}
+
+func (a *AssignStatement) statementNode() {}
+
+// DestroyStatement frees value allocated with `new`: `destroy this.name;`.
+type DestroyStatement struct {
+	// location of 'destroy'
+	StartLocation *utils.CodePointLocation
+	Value         Expression
+
+	// location of ';'
+	EndLocation *utils.CodePointLocation
+}
+
+func (d *DestroyStatement) Location() *utils.CodeBlockLocation {
+	return &utils.CodeBlockLocation{StartLocation: d.StartLocation,
+		EndLocation: d.EndLocation}
+}
+
+func (d *DestroyStatement) statementNode() {}
+
+// if_statement = "if" expression block [ "else" ( if_statement | block ) ] .
+type IfStatement struct {
+	// location of 'if'
+	StartLocation *utils.CodePointLocation
+	Condition     Expression
+	Consequence   *StatementsBlock
+
+	// nil, *StatementsBlock or *IfStatement for `else if`
+	Alternative Statement
+}
+
+func (i *IfStatement) Location() *utils.CodeBlockLocation {
+	end := i.Consequence.EndLocation
+	if i.Alternative != nil {
+		end = i.Alternative.Location().EndLocation
+	}
+
+	return &utils.CodeBlockLocation{StartLocation: i.StartLocation, EndLocation: end}
+}
+
+func (i *IfStatement) statementNode() {}
+
 type ReturnStatement struct {
 	// location of 'return'
 	TokenLocation *utils.CodeBlockLocation
@@ -168,6 +321,24 @@ func (r *ReturnStatement) Location() *utils.CodeBlockLocation {
 
 func (r *ReturnStatement) statementNode() {}
 
+// FailStatement stops failable function with the error: `fail 5;`. The error
+// is passed to the handler of the call.
+type FailStatement struct {
+	// location of 'fail'
+	StartLocation *utils.CodePointLocation
+	Error         Expression
+
+	// location of ';'
+	EndLocation *utils.CodePointLocation
+}
+
+func (f *FailStatement) Location() *utils.CodeBlockLocation {
+	return &utils.CodeBlockLocation{StartLocation: f.StartLocation,
+		EndLocation: f.EndLocation}
+}
+
+func (f *FailStatement) statementNode() {}
+
 type PrefixExpression struct {
 	// location of operator
 	StartLocation *utils.CodePointLocation
@@ -216,12 +387,92 @@ func (c *CallExpression) expressionNode() {}
 func (c *CallExpression) statementNode()  {}
 
 type Name struct {
-	location *utils.CodeBlockLocation
-	Name     string
+	TokenLocation *utils.CodeBlockLocation
+	Name          string
 }
 
-func (n *Name) Location() *utils.CodeBlockLocation { return n.location }
+func (n *Name) Location() *utils.CodeBlockLocation { return n.TokenLocation }
 func (n *Name) expressionNode()                    {}
+func (n *Name) statementNode()                     {}
+
+// MemberExpression describes access to member or method of a structure:
+// `this.name`.
+type MemberExpression struct {
+	Left           Expression
+	Member         string
+	MemberLocation *utils.CodeBlockLocation
+}
+
+func (m *MemberExpression) Location() *utils.CodeBlockLocation {
+	return &utils.CodeBlockLocation{StartLocation: m.Left.Location().StartLocation,
+		EndLocation: m.MemberLocation.EndLocation}
+}
+
+func (m *MemberExpression) expressionNode() {}
+func (m *MemberExpression) statementNode()  {}
+
+// HandleExpression handles error of a call to failable function. Default
+// handler `read(buffer) handle` propagates the error to the caller, custom
+// handler `read(buffer) handle err { ... }` runs the block if the call fails.
+type HandleExpression struct {
+	Call *CallExpression
+
+	// location of 'handle'
+	HandleLocation *utils.CodeBlockLocation
+
+	// nil for default handler or if error is not named: `handle { ... }`
+	ErrorName *Name
+
+	// nil for default handler
+	Handler *StatementsBlock
+}
+
+func (h *HandleExpression) Location() *utils.CodeBlockLocation {
+	end := h.HandleLocation.EndLocation
+	if h.Handler != nil {
+		end = h.Handler.EndLocation
+	}
+
+	return &utils.CodeBlockLocation{StartLocation: h.Call.Location().StartLocation,
+		EndLocation: end}
+}
+
+func (h *HandleExpression) expressionNode() {}
+func (h *HandleExpression) statementNode()  {}
+
+// NewExpression allocates structure on the heap and calls its `init`:
+// `new Account(14, "Adi")`.
+type NewExpression struct {
+	// location of 'new'
+	StartLocation *utils.CodePointLocation
+	Type          *CustomType
+	Arguments     []Expression
+
+	// location of ')'
+	EndLocation *utils.CodePointLocation
+}
+
+func (n *NewExpression) Location() *utils.CodeBlockLocation {
+	return &utils.CodeBlockLocation{StartLocation: n.StartLocation,
+		EndLocation: n.EndLocation}
+}
+
+func (n *NewExpression) expressionNode() {}
+func (n *NewExpression) statementNode()  {}
+
+type NumberLiteral struct {
+	TokenLocation *utils.CodeBlockLocation
+
+	// lexer.IntTokenKind, lexer.FloatTokenKind or lexer.ImaginaryTokenKind
+	Kind int
+
+	// literal as written in the source, like `0x1F`, `1_000` or `1.5e3`
+	Value string
+}
+
+func (n *NumberLiteral) Location() *utils.CodeBlockLocation { return n.TokenLocation }
+func (n *NumberLiteral) expressionNode()                    {}
+func (n *NumberLiteral) statementNode()                     {}
 
 type BooleanLiteral struct {
 	TokenLocation *utils.CodeBlockLocation
@@ -311,6 +562,10 @@ func (a *ArrayType) typeNode() {}
 type CustomType struct {
 	TypeLocation *utils.CodeBlockLocation
 	Name         string
+
+	// type arguments of generic type instantiation, for example
+	// `Vec<i32>`, empty if type is not generic
+	Arguments []Type
 }
 
 func (c *CustomType) Location() *utils.CodeBlockLocation { return c.TypeLocation }
@@ -0,0 +1,163 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package ast
+
+// Inspect traverses the syntax tree in depth-first order: it calls f(node),
+// and if f returns true, Inspect is called for every child of the node.
+// Missing optional nodes (nil return type, no else branch) are skipped.
+func Inspect(node AST, f func(AST) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ProgramUnit:
+		for _, statement := range node.TLStatements {
+			Inspect(statement, f)
+		}
+	case *FunctionDeclaration:
+		inspectSignature(node.TypeParameters, node.Arguments, node.ReturnType, f)
+		Inspect(node.StatementsBlock, f)
+	case *FunctionSignature:
+		inspectSignature(node.TypeParameters, node.Arguments, node.ReturnType, f)
+	case *ExternFunctionDeclaration:
+		inspectSignature(nil, node.Arguments, node.ReturnType, f)
+	case *StructureDeclaration:
+		for _, parameter := range node.TypeParameters {
+			Inspect(parameter, f)
+		}
+
+		for _, implemented := range node.Implements {
+			Inspect(implemented, f)
+		}
+
+		for _, member := range node.Members {
+			Inspect(member, f)
+		}
+
+		for _, method := range node.Methods {
+			Inspect(method, f)
+		}
+	case *InterfaceDeclaration:
+		for _, parameter := range node.TypeParameters {
+			Inspect(parameter, f)
+		}
+
+		for _, method := range node.Methods {
+			Inspect(method, f)
+		}
+	case *TypeParameter:
+		Inspect(node.Constraint, f)
+	case *FunctionArgument:
+		Inspect(node.Type, f)
+	case *StructureMember:
+		Inspect(node.Type, f)
+	case *StatementsBlock:
+		for _, statement := range node.Statements {
+			Inspect(statement, f)
+		}
+	case *VarStatement:
+		Inspect(node.Name, f)
+		Inspect(node.Type, f)
+		Inspect(node.Value, f)
+	case *AssignStatement:
+		Inspect(node.Target, f)
+		Inspect(node.Value, f)
+	case *DestroyStatement:
+		Inspect(node.Value, f)
+	case *IfStatement:
+		Inspect(node.Condition, f)
+		Inspect(node.Consequence, f)
+		Inspect(node.Alternative, f)
+	case *ReturnStatement:
+		Inspect(node.ReturnValue, f)
+	case *FailStatement:
+		Inspect(node.Error, f)
+	case *PrefixExpression:
+		Inspect(node.Expression, f)
+	case *InfixExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *CallExpression:
+		Inspect(node.Function, f)
+		inspectExpressions(node.Arguments, f)
+	case *IndexExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Index, f)
+	case *MemberExpression:
+		Inspect(node.Left, f)
+	case *HandleExpression:
+		Inspect(node.Call, f)
+		Inspect(node.ErrorName, f)
+		Inspect(node.Handler, f)
+	case *NewExpression:
+		Inspect(node.Type, f)
+		inspectExpressions(node.Arguments, f)
+	case *ArrayLiteral:
+		inspectExpressions(node.Elements, f)
+	case *PointerType:
+		Inspect(node.Type, f)
+	case *ArrayType:
+		Inspect(node.Type, f)
+	case *CustomType:
+		for _, argument := range node.Arguments {
+			Inspect(argument, f)
+		}
+	}
+}
+
+func inspectSignature(typeParameters []*TypeParameter, arguments []*FunctionArgument,
+	returnType Type, f func(AST) bool) {
+	for _, parameter := range typeParameters {
+		Inspect(parameter, f)
+	}
+
+	for _, argument := range arguments {
+		Inspect(argument, f)
+	}
+
+	Inspect(returnType, f)
+}
+
+func inspectExpressions(expressions []Expression, f func(AST) bool) {
+	for _, expression := range expressions {
+		Inspect(expression, f)
+	}
+}
+
+// isNil reports whether the node is nil or a nil pointer stored in the
+// interface, like a missing else branch.
+func isNil(node AST) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *StatementsBlock:
+		return node == nil
+	case *CustomType:
+		return node == nil
+	case *Name:
+		return node == nil
+	}
+
+	return false
+}
@@ -0,0 +1,418 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package bindgen generates Tiny bindings for C libraries from their headers:
+// extern declarations of functions and structures with the same fields.
+package bindgen
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tinylang-org/tiny/pkg/lexer"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+type Config struct {
+	// Namespace of the generated source file.
+	Namespace string
+
+	// Size of `long`, `size_t` and pointer-sized integers in bytes: 4 for
+	// 32-bit targets like most microcontrollers, 8 for 64-bit ones.
+	LongSize int
+}
+
+// Generate returns Tiny source with bindings for the C header. Functions,
+// structures, typedefs, enums and `#define` integer constants are supported,
+// declarations, which can't be converted, are listed in comments. Every
+// declaration, which is skipped or converted partially, is also reported to
+// the problem handler as IncompleteBindingWarn at its line in the header.
+func Generate(filename string, source []byte, config Config,
+	problemHandler *utils.CodeProblemHandler) ([]byte, error) {
+	if config.LongSize != 4 && config.LongSize != 8 {
+		return nil, fmt.Errorf("invalid size of long %d, must be 4 or 8", config.LongSize)
+	}
+
+	if config.Namespace == "" {
+		return nil, fmt.Errorf("namespace is empty")
+	}
+
+	tokens, defines := scanHeader(string(source))
+
+	h := newHeader(tokens, config.LongSize)
+	h.parse()
+
+	g := &generator{header: h, filename: filename, problemHandler: problemHandler,
+		lines:   strings.SplitAfter(string(source), "\n"),
+		omitted: map[*cStruct]string{}}
+
+	g.printf("// Code generated by tinyc bindgen from %s. DO NOT EDIT.\n\n",
+		filepath.Base(filename))
+	g.printf("namespace %q;\n", config.Namespace)
+
+	g.generateConstants(defines)
+	g.generateStructures()
+	g.generateFunctions()
+	g.generateVariables()
+
+	if len(h.skipped) != 0 {
+		g.printf("\n// Declarations, which can't be parsed:\n//\n")
+		for _, skipped := range h.skipped {
+			g.printf("//\tline %d: %s\n", skipped.line, skipped.reason)
+			g.warn(skipped.line, "declaration", skipped.reason)
+		}
+	}
+
+	return []byte(g.sb.String()), nil
+}
+
+type generator struct {
+	header *header
+	sb     strings.Builder
+
+	filename       string
+	problemHandler *utils.CodeProblemHandler
+
+	// lines of the header with line breaks
+	lines []string
+
+	// reasons why fields of structures are omitted, empty if they are
+	// generated
+	omitted map[*cStruct]string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.sb, format, args...)
+}
+
+// warn reports that what is declared at the line of the header can't be
+// bound for the reason. The whole line is marked, since tokens of the header
+// have no columns.
+func (g *generator) warn(line int, what string, reason string) {
+	index := 0
+	for i := 0; i < line-1 && i < len(g.lines); i++ {
+		index += len(g.lines[i])
+	}
+
+	text := ""
+	if line >= 1 && line <= len(g.lines) {
+		text = strings.TrimRight(g.lines[line-1], "\r\n")
+	}
+
+	location := &utils.CodeBlockLocation{
+		StartLocation: &utils.CodePointLocation{Filepath: g.filename, Index: index, Line: line},
+		EndLocation: &utils.CodePointLocation{Filepath: g.filename, Index: index + len(text),
+			Line: line, Column: utf8.RuneCountInString(text)},
+	}
+
+	g.problemHandler.AddCodeProblem(
+		utils.NewLocalWarning(location, utils.IncompleteBindingWarn, what, reason))
+}
+
+// generateConstants lists enum members and integer macros. Tiny has no
+// constant declarations yet, so they are written in comments.
+func (g *generator) generateConstants(defines []*cDefine) {
+	macros := g.evaluateDefines(defines)
+
+	if len(g.header.enums) == 0 && len(macros) == 0 {
+		return
+	}
+
+	g.printf("\n// Tiny has no constant declarations yet, so values of enum members and\n")
+	g.printf("// integer macros are listed in comments.\n")
+
+	for _, enum := range g.header.enums {
+		name := enum.name
+		if name == "" {
+			name = enum.tag
+		}
+
+		if name == "" {
+			g.printf("\n// anonymous enum (i32):\n//\n")
+		} else {
+			g.printf("\n// %s (i32):\n//\n", name)
+		}
+
+		for _, member := range enum.members {
+			g.printf("//\t%s = %d\n", member.name, member.value)
+		}
+	}
+
+	if len(macros) != 0 {
+		g.printf("\n// macros:\n//\n")
+		for _, macro := range macros {
+			g.printf("//\t%s = %d\n", macro.name, macro.value)
+		}
+	}
+}
+
+// evaluateDefines returns macros, which expand to integer constants, in order
+// of their definitions. Macros can refer to ones defined after them, so they
+// are evaluated until no more values can be found.
+func (g *generator) evaluateDefines(defines []*cDefine) []*cConstant {
+	values := map[string]int64{}
+	for name, value := range g.header.constants {
+		values[name] = value
+	}
+
+	evaluated := map[string]bool{}
+
+	for progress := true; progress; {
+		progress = false
+
+		for _, define := range defines {
+			if evaluated[define.name] {
+				continue
+			}
+
+			if value, ok := evaluate(define.value, values, g.header.isTypeName); ok {
+				values[define.name] = value
+				evaluated[define.name] = true
+				progress = true
+			}
+		}
+	}
+
+	macros := []*cConstant{}
+	for _, define := range defines {
+		if evaluated[define.name] {
+			macros = append(macros, &cConstant{name: define.name, value: values[define.name]})
+			evaluated[define.name] = false
+		}
+	}
+
+	return macros
+}
+
+func (g *generator) generateStructures() {
+	for _, structure := range g.header.structs {
+		name := structure.tinyName()
+		if name == "" {
+			continue
+		}
+
+		g.printf("\n")
+
+		if !structure.defined {
+			g.printf("// %s is opaque, its fields are not declared in the header.\n", name)
+			g.printf("pub struct %s {}\n", identifier(name))
+			continue
+		}
+
+		if reason := g.omittedFields(structure); reason != "" {
+			g.warn(structure.line, "fields of "+name, reason)
+			g.printf("// Fields of %s are omitted: %s.\n", name, reason)
+			g.printf("pub struct %s {}\n", identifier(name))
+			continue
+		}
+
+		if len(structure.fields) == 0 {
+			g.printf("pub struct %s {}\n", identifier(name))
+			continue
+		}
+
+		g.printf("pub struct %s {\n", identifier(name))
+		for _, field := range structure.fields {
+			t, _ := g.typeName(field.t)
+			g.printf("\tpub %s: %s;\n", identifier(field.name), t)
+		}
+		g.printf("}\n")
+	}
+}
+
+// omittedFields returns the reason why fields of the defined structure can't
+// be generated or an empty string if they can.
+func (g *generator) omittedFields(structure *cStruct) string {
+	if reason, ok := g.omitted[structure]; ok {
+		return reason
+	}
+
+	// structures can't contain themselves by value, this only guards
+	// against invalid headers
+	g.omitted[structure] = "structure contains itself"
+
+	reason := structure.unsupported
+
+	for _, field := range structure.fields {
+		if reason != "" {
+			break
+		}
+
+		t, unsupported := g.typeName(field.t)
+		if unsupported != "" || t == "" {
+			if unsupported == "" {
+				unsupported = "void type"
+			}
+
+			reason = fmt.Sprintf("field %s: %s", field.name, unsupported)
+		}
+	}
+
+	g.omitted[structure] = reason
+	return reason
+}
+
+func (g *generator) generateFunctions() {
+	generated := map[string]bool{}
+
+	for _, function := range g.header.functions {
+		if function.name == "" || generated[function.name] {
+			continue
+		}
+
+		generated[function.name] = true
+
+		declaration, reason := g.functionDeclaration(function)
+
+		g.printf("\n")
+		if reason != "" {
+			g.warn(function.line, "function "+function.name, reason)
+			g.printf("// %s is skipped: %s.\n", function.name, reason)
+		} else {
+			g.printf("%s\n", declaration)
+		}
+	}
+}
+
+// generateVariables lists global variables of the header in comments, since
+// Tiny has no extern variables yet.
+func (g *generator) generateVariables() {
+	generated := map[string]bool{}
+
+	for _, variable := range g.header.variables {
+		if generated[variable.name] {
+			continue
+		}
+
+		generated[variable.name] = true
+
+		reason := "extern variables are not supported"
+		g.warn(variable.line, "variable "+variable.name, reason)
+		g.printf("\n// %s is skipped: %s.\n", variable.name, reason)
+	}
+}
+
+func (g *generator) functionDeclaration(function *cFunction) (string, string) {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "pub extern fun %s(", identifier(function.name))
+
+	for i, argument := range function.arguments {
+		t, reason := g.typeName(argument.t)
+		if reason != "" {
+			return "", reason
+		}
+
+		if t == "" {
+			return "", "argument has void type"
+		}
+
+		name := argument.name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+
+		fmt.Fprintf(&sb, "%s: %s", identifier(name), t)
+	}
+
+	if function.variadic {
+		if len(function.arguments) == 0 {
+			return "", "variadic functions must have at least one named argument"
+		}
+
+		sb.WriteString(", ...")
+	}
+
+	sb.WriteString(")")
+
+	returnType, reason := g.typeName(function.returnType)
+	if reason != "" {
+		return "", reason
+	}
+
+	if returnType != "" {
+		fmt.Fprintf(&sb, ": %s", returnType)
+	}
+
+	sb.WriteString(";")
+	return sb.String(), ""
+}
+
+// typeName returns name of Tiny type, empty for void, or the reason why type
+// can't be converted.
+func (g *generator) typeName(t *cType) (string, string) {
+	if t.unsupported != "" {
+		return "", t.unsupported
+	}
+
+	var name string
+
+	switch {
+	case t.structure != nil:
+		name = t.structure.tinyName()
+		if name == "" {
+			return "", "anonymous structures are not supported"
+		}
+
+		// generated structure is empty, if its fields are unknown, so it
+		// has the wrong size when passed by value
+		if t.pointers == 0 {
+			switch {
+			case t.structure.union:
+				return "", fmt.Sprintf("union %s can't be passed by value", name)
+			case !t.structure.defined:
+				return "", fmt.Sprintf("opaque structure %s can't be passed by value", name)
+			case g.omittedFields(t.structure) != "":
+				return "", fmt.Sprintf("structure %s with omitted fields can't be passed by value",
+					name)
+			}
+		}
+
+		name = identifier(name)
+	case t.primary == "void":
+		if t.pointers == 0 {
+			return "", ""
+		}
+
+		// void pointers are byte pointers
+		name = "u8"
+	default:
+		name = t.primary
+	}
+
+	return strings.Repeat("*", t.pointers) + name, ""
+}
+
+// identifier wraps names, which are Tiny keywords, in backquotes.
+func identifier(name string) string {
+	if lexer.IsKeyword(name) || name == "true" || name == "false" {
+		return "`" + name + "`"
+	}
+
+	return name
+}
@@ -0,0 +1,276 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package bindgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylang-org/tiny/pkg/checker"
+	"github.com/tinylang-org/tiny/pkg/parser"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+const testHeader = `#ifndef HAL_H
+#define HAL_H
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+#define GPIO_PIN_0 ((uint16_t)0x0001U) /* pin 0 */
+#define GPIO_PIN_ALL (GPIO_PIN_0 | GPIO_PIN_1)
+#define GPIO_PIN_1 ((uint16_t)0x0002U)
+#define SQUARE(x) ((x) * (x))
+#define VERSION "1.0"
+
+typedef enum {
+  HAL_OK = 0x00U,
+  HAL_ERROR,
+  HAL_BUSY = 1 << 2
+} HAL_StatusTypeDef;
+
+typedef struct {
+  __IO uint32_t MODER;
+  uint32_t AFR[2];
+} GPIO_TypeDef;
+
+struct node {
+  struct node *next;
+  void *data;
+  unsigned short new, size;
+};
+
+typedef struct __SPI_HandleTypeDef SPI_HandleTypeDef;
+typedef void (*callback_t)(int status);
+
+void HAL_GPIO_Init(GPIO_TypeDef *GPIOx, uint16_t pin);
+HAL_StatusTypeDef HAL_Init(void);
+extern int printf(const char *fmt, ...);
+void HAL_Delay(unsigned long);
+void set_callback(callback_t callback);
+double sqrt(double x);
+int process(SPI_HandleTypeDef *hspi, struct node *list);
+static inline int helper(int x) { return x + 1; }
+
+#ifdef __cplusplus
+}
+#endif
+#endif
+`
+
+const testBindings = `// Code generated by tinyc bindgen from hal.h. DO NOT EDIT.
+
+namespace "hal";
+
+// Tiny has no constant declarations yet, so values of enum members and
+// integer macros are listed in comments.
+
+// HAL_StatusTypeDef (i32):
+//
+//	HAL_OK = 0
+//	HAL_ERROR = 1
+//	HAL_BUSY = 4
+
+// macros:
+//
+//	GPIO_PIN_0 = 1
+//	GPIO_PIN_ALL = 3
+//	GPIO_PIN_1 = 2
+
+pub struct GPIO_TypeDef {
+	pub MODER: u32;
+	pub AFR_0: u32;
+	pub AFR_1: u32;
+}
+
+pub struct node {
+	pub next: *node;
+	pub data: *u8;
+	pub ` + "`new`" + `: u16;
+	pub size: u16;
+}
+
+// SPI_HandleTypeDef is opaque, its fields are not declared in the header.
+pub struct SPI_HandleTypeDef {}
+
+pub extern fun HAL_GPIO_Init(GPIOx: *GPIO_TypeDef, pin: u16);
+
+pub extern fun HAL_Init(): i32;
+
+pub extern fun printf(fmt: *u8, ...): i32;
+
+pub extern fun HAL_Delay(arg0: u32);
+
+pub extern fun set_callback(callback: *u8);
+
+// sqrt is skipped: floating point types are not supported.
+
+pub extern fun process(hspi: *SPI_HandleTypeDef, list: *node): i32;
+`
+
+func TestGenerate(t *testing.T) {
+	warnings := utils.NewCodeProblemHandler()
+	bindings, err := Generate("include/hal.h", []byte(testHeader),
+		Config{Namespace: "hal", LongSize: 4}, warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, testBindings, string(bindings))
+
+	assert.True(t, warnings.Ok)
+	assert.Equal(t, 1, len(warnings.Problems()))
+	assert.Equal(t, "function sqrt can't be bound: floating point types are not supported",
+		warnings.Problems()[0].Message())
+	assert.Equal(t, 41, warnings.Problems()[0].Location().StartLocation.Line)
+
+	p := utils.NewCodeProblemHandler()
+	unit := parser.NewParser("hal.tiny", bindings, p).ParseProgramUnit()
+	checker.NewChecker(p).CheckProgramUnit(unit)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 9, len(unit.TLStatements))
+}
+
+func TestLongSize(t *testing.T) {
+	header := []byte("unsigned long strlen(const char *s);\nlong long atoll(const char *s);")
+
+	bindings, err := Generate("string.h", header, Config{Namespace: "c", LongSize: 8},
+		utils.NewCodeProblemHandler())
+	assert.NoError(t, err)
+	assert.Contains(t, string(bindings), "pub extern fun strlen(s: *u8): u64;")
+	assert.Contains(t, string(bindings), "pub extern fun atoll(s: *u8): i64;")
+
+	bindings, err = Generate("string.h", header, Config{Namespace: "c", LongSize: 4},
+		utils.NewCodeProblemHandler())
+	assert.NoError(t, err)
+	assert.Contains(t, string(bindings), "pub extern fun strlen(s: *u8): u32;")
+	assert.Contains(t, string(bindings), "pub extern fun atoll(s: *u8): i64;")
+
+	_, err = Generate("string.h", header, Config{Namespace: "c", LongSize: 2},
+		utils.NewCodeProblemHandler())
+	assert.Error(t, err)
+}
+
+func TestUnsupportedDeclarations(t *testing.T) {
+	warnings := utils.NewCodeProblemHandler()
+	bindings, err := Generate("x.h", []byte(`
+union value { int i; float f; };
+struct flags { unsigned a : 1; };
+struct matrix { int cells[4][4]; };
+int broken declaration here;
+int get(void);
+extern int errno, counters[4];
+static int hidden;`), Config{Namespace: "x", LongSize: 8}, warnings)
+	assert.NoError(t, err)
+
+	source := string(bindings)
+	assert.Contains(t, source, "// Fields of value are omitted: unions are not supported.\npub struct value {}")
+	assert.Contains(t, source, "// Fields of flags are omitted: bit fields are not supported.\npub struct flags {}")
+	assert.Contains(t, source, "// Fields of matrix are omitted: field cells is a multidimensional array.")
+	assert.Contains(t, source, "//\tline 5: expected ;, got \"declaration\"")
+	assert.Contains(t, source, "pub extern fun get(): i32;")
+	assert.Contains(t, source, "// errno is skipped: extern variables are not supported.")
+	assert.NotContains(t, source, "hidden")
+
+	// every skipped or incomplete declaration is reported at its line
+	lines := map[string]int{}
+	for _, problem := range warnings.Problems() {
+		assert.Equal(t, utils.IncompleteBindingWarn, problem.Code())
+		lines[problem.Message()] = problem.Location().StartLocation.Line
+	}
+
+	assert.Equal(t, map[string]int{
+		"fields of value can't be bound: unions are not supported":                 2,
+		"fields of flags can't be bound: bit fields are not supported":             3,
+		"fields of matrix can't be bound: field cells is a multidimensional array": 4,
+		"declaration can't be bound: expected ;, got \"declaration\"":              5,
+		"variable errno can't be bound: extern variables are not supported":        7,
+		"variable counters can't be bound: extern variables are not supported":     7,
+	}, lines)
+}
+
+func TestStructuresByValue(t *testing.T) {
+	warnings := utils.NewCodeProblemHandler()
+	bindings, err := Generate("x.h", []byte(`
+struct point { int x : 16; int y : 16; };
+struct opaque;
+struct size { int width; int height; };
+struct box { struct point origin; struct size size; };
+struct point make_point(int x, int y);
+struct opaque get(void);
+void put(struct opaque *o);
+struct size area(struct size s);`), Config{Namespace: "x", LongSize: 8}, warnings)
+	assert.NoError(t, err)
+
+	source := string(bindings)
+	assert.Contains(t, source, "// make_point is skipped: structure point with omitted fields can't be passed by value.")
+	assert.Contains(t, source, "// get is skipped: opaque structure opaque can't be passed by value.")
+	assert.Contains(t, source, "// Fields of box are omitted: field origin: structure point with omitted fields can't be passed by value.")
+	assert.Contains(t, source, "pub extern fun put(o: *opaque);")
+	assert.Contains(t, source, "pub extern fun area(s: size): size;")
+
+	lines := map[string]int{}
+	for _, problem := range warnings.Problems() {
+		lines[problem.Message()] = problem.Location().StartLocation.Line
+	}
+
+	assert.Equal(t, map[string]int{
+		"fields of point can't be bound: bit fields are not supported":                                             2,
+		"fields of box can't be bound: field origin: structure point with omitted fields can't be passed by value": 5,
+		"function make_point can't be bound: structure point with omitted fields can't be passed by value":         6,
+		"function get can't be bound: opaque structure opaque can't be passed by value":                            7,
+	}, lines)
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]int64{
+		"0x10":                 16,
+		"010":                  8,
+		"42UL":                 42,
+		"'a'":                  97,
+		"'\\n'":                10,
+		"-(1 + 2) * 3":         -9,
+		"1 << 4 | 1":           17,
+		"~0 & 0xFF":            255,
+		"((uint32_t)0x0001U)":  1,
+		"(unsigned long)5 + 1": 6,
+		"A + 1":                2,
+		"10 / 3 % 2":           1,
+		"1 < 2 && 2 > 1":       1,
+	}
+
+	for expression, expected := range tests {
+		tokens := scanLine(expression, 1)
+		value, ok := evaluate(tokens, map[string]int64{"A": 1}, func(name string) bool {
+			return name == "uint32_t"
+		})
+
+		assert.True(t, ok, expression)
+		assert.Equal(t, expected, value, expression)
+	}
+
+	for _, expression := range []string{"", "1 +", "1 / 0", "B", "(1", "1.5", "\"s\""} {
+		_, ok := evaluate(scanLine(expression, 1), map[string]int64{}, func(string) bool { return false })
+		assert.False(t, ok, expression)
+	}
+}
@@ -0,0 +1,279 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package bindgen
+
+import (
+	"strconv"
+	"strings"
+)
+
+// evaluator computes integer constant expressions used in enum values and
+// `#define` macros, for example `((uint16_t)0x0001U << 3)`.
+type evaluator struct {
+	tokens []*cToken
+	pos    int
+
+	// values of known constants
+	constants map[string]int64
+
+	// reports whether identifier names a type, so that casts can be skipped
+	isTypeName func(name string) bool
+}
+
+// evaluate returns value of the expression and false if it is not an integer
+// constant expression.
+func evaluate(tokens []*cToken, constants map[string]int64,
+	isTypeName func(name string) bool) (int64, bool) {
+	if len(tokens) == 0 {
+		return 0, false
+	}
+
+	e := &evaluator{tokens: tokens, constants: constants, isTypeName: isTypeName}
+
+	value, ok := e.binary(0)
+	if !ok || e.pos != len(e.tokens) {
+		return 0, false
+	}
+
+	return value, true
+}
+
+var binaryPrecedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"|":  3,
+	"^":  4,
+	"&":  5,
+	"==": 6, "!=": 6,
+	"<": 7, ">": 7, "<=": 7, ">=": 7,
+	"<<": 8, ">>": 8,
+	"+": 9, "-": 9,
+	"*": 10, "/": 10, "%": 10,
+}
+
+func (e *evaluator) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos].text
+	}
+
+	return ""
+}
+
+func (e *evaluator) binary(minPrecedence int) (int64, bool) {
+	left, ok := e.unary()
+	if !ok {
+		return 0, false
+	}
+
+	for {
+		operator := e.peek()
+		precedence, isBinary := binaryPrecedences[operator]
+		if !isBinary || precedence <= minPrecedence ||
+			e.tokens[e.pos].kind != cPunctuator {
+			return left, true
+		}
+
+		e.pos++
+
+		right, ok := e.binary(precedence)
+		if !ok {
+			return 0, false
+		}
+
+		if left, ok = applyBinary(operator, left, right); !ok {
+			return 0, false
+		}
+	}
+}
+
+func applyBinary(operator string, a int64, b int64) (int64, bool) {
+	switch operator {
+	case "||":
+		return boolToInt(a != 0 || b != 0), true
+	case "&&":
+		return boolToInt(a != 0 && b != 0), true
+	case "|":
+		return a | b, true
+	case "^":
+		return a ^ b, true
+	case "&":
+		return a & b, true
+	case "==":
+		return boolToInt(a == b), true
+	case "!=":
+		return boolToInt(a != b), true
+	case "<":
+		return boolToInt(a < b), true
+	case ">":
+		return boolToInt(a > b), true
+	case "<=":
+		return boolToInt(a <= b), true
+	case ">=":
+		return boolToInt(a >= b), true
+	case "<<":
+		return a << uint64(b), b >= 0
+	case ">>":
+		return a >> uint64(b), b >= 0
+	case "+":
+		return a + b, true
+	case "-":
+		return a - b, true
+	case "*":
+		return a * b, true
+	case "/":
+		if b == 0 {
+			return 0, false
+		}
+		return a / b, true
+	case "%":
+		if b == 0 {
+			return 0, false
+		}
+		return a % b, true
+	}
+
+	return 0, false
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (e *evaluator) unary() (int64, bool) {
+	switch e.peek() {
+	case "-", "+", "~", "!":
+		operator := e.peek()
+		e.pos++
+
+		value, ok := e.unary()
+		if !ok {
+			return 0, false
+		}
+
+		switch operator {
+		case "-":
+			return -value, true
+		case "~":
+			return ^value, true
+		case "!":
+			return boolToInt(value == 0), true
+		}
+
+		return value, true
+	case "(":
+		if e.isCast() {
+			for e.peek() != ")" {
+				e.pos++
+			}
+
+			e.pos++ // ')'
+			return e.unary()
+		}
+
+		e.pos++ // '('
+
+		value, ok := e.binary(0)
+		if !ok || e.peek() != ")" {
+			return 0, false
+		}
+
+		e.pos++ // ')'
+		return value, true
+	}
+
+	return e.primary()
+}
+
+// isCast reports whether parenthesis at the current position contain type
+// name, for example `(uint16_t)` or `(unsigned long)`.
+func (e *evaluator) isCast() bool {
+	i := e.pos + 1
+	if i >= len(e.tokens) || e.tokens[i].kind != cIdentifier {
+		return false
+	}
+
+	for ; i < len(e.tokens) && e.tokens[i].text != ")"; i++ {
+		token := e.tokens[i]
+		if token.kind != cIdentifier || !(isBuiltinTypeWord(token.text) ||
+			isQualifier(token.text) || e.isTypeName(token.text)) {
+			return false
+		}
+	}
+
+	return i < len(e.tokens)
+}
+
+func (e *evaluator) primary() (int64, bool) {
+	if e.pos >= len(e.tokens) {
+		return 0, false
+	}
+
+	token := e.tokens[e.pos]
+	e.pos++
+
+	switch token.kind {
+	case cNumber:
+		return parseInteger(token.text)
+	case cCharacter:
+		return parseCharacter(token.text)
+	case cIdentifier:
+		value, ok := e.constants[token.text]
+		return value, ok
+	}
+
+	return 0, false
+}
+
+// parseInteger parses C integer literal with optional `u` and `l` suffixes.
+func parseInteger(literal string) (int64, bool) {
+	literal = strings.TrimRight(literal, "uUlL")
+
+	if len(literal) > 1 && literal[0] == '0' && '0' <= literal[1] && literal[1] <= '7' {
+		literal = "0o" + literal[1:]
+	}
+
+	value, err := strconv.ParseInt(literal, 0, 64)
+	if err != nil {
+		unsigned, err := strconv.ParseUint(literal, 0, 64)
+		return int64(unsigned), err == nil
+	}
+
+	return value, true
+}
+
+func parseCharacter(literal string) (int64, bool) {
+	if len(literal) < 3 || literal[len(literal)-1] != '\'' {
+		return 0, false
+	}
+
+	value, _, tail, err := strconv.UnquoteChar(literal[1:len(literal)-1], '\'')
+	if err != nil || tail != "" {
+		return 0, false
+	}
+
+	return int64(value), true
+}
@@ -0,0 +1,802 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package bindgen
+
+import (
+	"fmt"
+)
+
+// cType is a C type converted to Tiny.
+type cType struct {
+	// name of Tiny primary type or "void", empty if type is a structure
+	primary string
+
+	// structure the type refers to, nil if type is primary
+	structure *cStruct
+
+	pointers int
+
+	// enum the type is declared by, nil if none
+	enum *cEnum
+
+	// reason why the type can't be used in bindings, empty if it can
+	unsupported string
+}
+
+type cStruct struct {
+	tag string
+
+	// name given by typedef, it is preferred over the tag
+	name string
+
+	union   bool
+	defined bool
+	fields  []*cField
+
+	// reason why fields can't be generated, empty if they can
+	unsupported string
+
+	line int
+}
+
+func (s *cStruct) tinyName() string {
+	if s.name != "" {
+		return s.name
+	}
+
+	return s.tag
+}
+
+type cField struct {
+	name string
+	t    *cType
+}
+
+type cEnum struct {
+	tag  string
+	name string
+
+	members []*cConstant
+}
+
+type cConstant struct {
+	name  string
+	value int64
+}
+
+// cVariable is a global variable declared in the header. Tiny has no extern
+// variables, so they are only reported.
+type cVariable struct {
+	name string
+	line int
+}
+
+// cSkipped is a declaration, which can't be parsed.
+type cSkipped struct {
+	line   int
+	reason string
+}
+
+type cFunction struct {
+	name       string
+	arguments  []*cField
+	variadic   bool
+	returnType *cType
+	line       int
+}
+
+// declarator is the part of declaration after type specifier: pointers,
+// name and array or function suffixes.
+type declarator struct {
+	name string
+	t    *cType
+
+	// tokens of array lengths, empty if declarator is not an array
+	dimensions [][]*cToken
+
+	// nil if declarator doesn't declare a function
+	function *cFunction
+}
+
+type header struct {
+	tokens []*cToken
+	pos    int
+
+	// size of `long`, `size_t` and pointer-sized integers in bytes
+	longSize int
+
+	typedefs  map[string]*cType
+	structs   []*cStruct
+	tags      map[string]*cStruct
+	enums     []*cEnum
+	constants map[string]int64
+	functions []*cFunction
+	variables []*cVariable
+
+	// declarations, which can't be parsed
+	skipped []*cSkipped
+
+	// error of the current declaration
+	err error
+}
+
+func newHeader(tokens []*cToken, longSize int) *header {
+	return &header{
+		tokens:    tokens,
+		longSize:  longSize,
+		typedefs:  map[string]*cType{},
+		tags:      map[string]*cStruct{},
+		constants: map[string]int64{},
+	}
+}
+
+func (h *header) peek() string {
+	if h.pos < len(h.tokens) {
+		return h.tokens[h.pos].text
+	}
+
+	return ""
+}
+
+func (h *header) peekAt(offset int) *cToken {
+	if h.pos+offset < len(h.tokens) {
+		return h.tokens[h.pos+offset]
+	}
+
+	return &cToken{}
+}
+
+func (h *header) line() int {
+	if h.pos < len(h.tokens) {
+		return h.tokens[h.pos].line
+	}
+
+	if len(h.tokens) != 0 {
+		return h.tokens[len(h.tokens)-1].line
+	}
+
+	return 0
+}
+
+func (h *header) fail(format string, args ...interface{}) {
+	if h.err == nil {
+		h.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (h *header) expect(text string) bool {
+	if h.peek() != text {
+		h.fail("expected %s, got %q", text, h.peek())
+		return false
+	}
+
+	h.pos++
+	return true
+}
+
+// skipBalanced skips tokens from the opening bracket at the current position
+// to the matching closing one.
+func (h *header) skipBalanced() {
+	depth := 0
+
+	for h.pos < len(h.tokens) {
+		switch h.peek() {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+
+		h.pos++
+
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// synchronize skips the rest of unsupported declaration.
+func (h *header) synchronize() {
+	for h.pos < len(h.tokens) {
+		switch h.peek() {
+		case ";":
+			h.pos++
+			return
+		case "(", "[", "{":
+			h.skipBalanced()
+		default:
+			h.pos++
+		}
+	}
+}
+
+func (h *header) parse() {
+	for h.pos < len(h.tokens) {
+		switch {
+		case h.peek() == ";":
+			h.pos++
+		case h.peek() == "extern" && h.peekAt(1).kind == cString:
+			// extern "C" { ... }
+			h.pos += 2
+			if h.peek() == "{" {
+				h.pos++
+			}
+		case h.peek() == "}":
+			// closing brace of extern "C" block
+			h.pos++
+		default:
+			start := h.pos
+			line := h.line()
+
+			h.parseDeclaration()
+
+			if h.err != nil {
+				h.skipped = append(h.skipped, &cSkipped{line: line, reason: h.err.Error()})
+				h.err = nil
+				h.pos = start
+				h.synchronize()
+			}
+		}
+	}
+}
+
+func (h *header) parseDeclaration() {
+	line := h.line()
+
+	typedef := false
+	if h.peek() == "typedef" {
+		typedef = true
+		h.pos++
+	}
+
+	base, static := h.parseSpecifier()
+	if h.err != nil {
+		return
+	}
+
+	if h.peek() == ";" {
+		h.pos++
+		return
+	}
+
+	// variables are added only when the whole declaration is parsed
+	var variables []*cVariable
+
+	for {
+		d := h.parseDeclarator(base)
+		if h.err != nil {
+			return
+		}
+
+		switch {
+		case typedef:
+			h.addTypedef(d)
+		case d.function != nil:
+			if !static {
+				h.functions = append(h.functions, d.function)
+			}
+
+			if h.peek() == "{" {
+				// inline function body
+				h.skipBalanced()
+				return
+			}
+		case !static && d.name != "":
+			variables = append(variables, &cVariable{name: d.name, line: line})
+		}
+
+		if h.peek() != "," {
+			break
+		}
+
+		h.pos++
+	}
+
+	if h.expect(";") {
+		h.variables = append(h.variables, variables...)
+	}
+}
+
+func (h *header) addTypedef(d *declarator) {
+	t := d.t
+
+	if d.function != nil {
+		t = &cType{unsupported: fmt.Sprintf("function type %s is not supported", d.name)}
+	} else if len(d.dimensions) != 0 {
+		t = &cType{unsupported: fmt.Sprintf("array type %s is not supported", d.name)}
+	}
+
+	if t.structure != nil && t.pointers == 0 && t.structure.name == "" {
+		t.structure.name = d.name
+	}
+
+	if t.enum != nil && t.pointers == 0 && t.enum.name == "" {
+		t.enum.name = d.name
+	}
+
+	h.typedefs[d.name] = t
+}
+
+// isTypeName reports whether identifier is a known type name.
+func (h *header) isTypeName(name string) bool {
+	if _, ok := h.typedefs[name]; ok {
+		return true
+	}
+
+	_, ok := standardTypes[name]
+	return ok
+}
+
+var qualifiers = map[string]bool{
+	"const": true, "volatile": true, "restrict": true, "__restrict": true,
+	"__restrict__": true, "register": true, "extern": true, "inline": true,
+	"__inline": true, "__inline__": true, "__extension__": true,
+	"__IO": true, "__I": true, "__O": true, "__IM": true, "__OM": true, "__IOM": true,
+}
+
+func isQualifier(word string) bool {
+	return qualifiers[word]
+}
+
+var builtinTypeWords = map[string]bool{
+	"void": true, "char": true, "short": true, "int": true, "long": true,
+	"signed": true, "unsigned": true, "float": true, "double": true,
+	"_Bool": true, "bool": true,
+}
+
+func isBuiltinTypeWord(word string) bool {
+	return builtinTypeWords[word]
+}
+
+// standardTypes maps types from stdint.h, stddef.h and stdbool.h to Tiny
+// types, "long" stands for integers of `long` size.
+var standardTypes = map[string]string{
+	"int8_t": "i8", "int16_t": "i16", "int32_t": "i32", "int64_t": "i64",
+	"uint8_t": "u8", "uint16_t": "u16", "uint32_t": "u32", "uint64_t": "u64",
+	"size_t": "ulong", "ssize_t": "long", "ptrdiff_t": "long",
+	"intptr_t": "long", "uintptr_t": "ulong", "bool": "u8",
+}
+
+func (h *header) longType(unsigned bool) string {
+	name := fmt.Sprintf("i%d", h.longSize*8)
+	if unsigned {
+		name = "u" + name[1:]
+	}
+
+	return name
+}
+
+// parseSpecifier parses type specifier with qualifiers and storage classes
+// and reports whether declaration is static.
+func (h *header) parseSpecifier() (*cType, bool) {
+	var t *cType
+	static := false
+	words := map[string]int{}
+
+	for h.err == nil && h.pos < len(h.tokens) {
+		word := h.peek()
+
+		switch {
+		case word == "static" || word == "__STATIC_INLINE":
+			static = true
+			h.pos++
+		case isQualifier(word):
+			h.pos++
+		case word == "__attribute__" || word == "__declspec":
+			h.pos++
+			h.skipBalanced()
+		case isBuiltinTypeWord(word) && t == nil:
+			words[word]++
+			h.pos++
+		case (word == "struct" || word == "union") && t == nil && len(words) == 0:
+			t = &cType{structure: h.parseStruct()}
+		case word == "enum" && t == nil && len(words) == 0:
+			t = &cType{primary: "i32", enum: h.parseEnum()}
+		case h.peekAt(0).kind == cIdentifier && t == nil && len(words) == 0:
+			h.pos++
+
+			if !h.isTypeName(word) && h.startsType(h.peekAt(0)) {
+				// unknown macro used as qualifier, for example
+				// `HAL_API int f(void);`
+				continue
+			}
+
+			if typedef, ok := h.typedefs[word]; ok {
+				copied := *typedef
+				t = &copied
+			} else if standard, ok := standardTypes[word]; ok {
+				t = &cType{primary: h.resolveLong(standard)}
+			} else {
+				t = &cType{unsupported: fmt.Sprintf("unknown type %s", word)}
+			}
+		default:
+			if t == nil {
+				if len(words) == 0 {
+					h.fail("expected type, got %q", word)
+					return nil, false
+				}
+
+				t = h.builtinType(words)
+			}
+
+			return t, static
+		}
+	}
+
+	if t == nil {
+		h.fail("unexpected end of header")
+	}
+
+	return t, static
+}
+
+// startsType reports whether token can start type specifier.
+func (h *header) startsType(token *cToken) bool {
+	switch token.text {
+	case "struct", "union", "enum":
+		return true
+	}
+
+	return token.kind == cIdentifier &&
+		(isBuiltinTypeWord(token.text) || isQualifier(token.text) || h.isTypeName(token.text))
+}
+
+func (h *header) resolveLong(name string) string {
+	switch name {
+	case "long":
+		return h.longType(false)
+	case "ulong":
+		return h.longType(true)
+	}
+
+	return name
+}
+
+func (h *header) builtinType(words map[string]int) *cType {
+	unsigned := words["unsigned"] != 0
+
+	switch {
+	case words["void"] != 0:
+		return &cType{primary: "void"}
+	case words["float"] != 0 || words["double"] != 0:
+		return &cType{unsupported: "floating point types are not supported"}
+	case words["_Bool"] != 0 || words["bool"] != 0:
+		return &cType{primary: "u8"}
+	case words["char"] != 0:
+		// plain char is unsigned on ARM and is used for strings, which are
+		// byte pointers in Tiny
+		if words["signed"] != 0 {
+			return &cType{primary: "i8"}
+		}
+		return &cType{primary: "u8"}
+	case words["short"] != 0:
+		if unsigned {
+			return &cType{primary: "u16"}
+		}
+		return &cType{primary: "i16"}
+	case words["long"] >= 2:
+		if unsigned {
+			return &cType{primary: "u64"}
+		}
+		return &cType{primary: "i64"}
+	case words["long"] == 1:
+		return &cType{primary: h.longType(unsigned)}
+	}
+
+	if unsigned {
+		return &cType{primary: "u32"}
+	}
+
+	return &cType{primary: "i32"}
+}
+
+// parseStruct parses `struct [tag] [{ fields }]`.
+func (h *header) parseStruct() *cStruct {
+	union := h.peek() == "union"
+	line := h.line()
+	h.pos++
+
+	for h.peek() == "__attribute__" || h.peek() == "__declspec" {
+		h.pos++
+		h.skipBalanced()
+	}
+
+	var structure *cStruct
+
+	if h.peekAt(0).kind == cIdentifier {
+		tag := h.peek()
+		h.pos++
+
+		structure = h.tags[tag]
+		if structure == nil {
+			structure = &cStruct{tag: tag, union: union, line: line}
+			h.tags[tag] = structure
+			h.structs = append(h.structs, structure)
+		}
+	} else {
+		structure = &cStruct{union: union, line: line}
+		h.structs = append(h.structs, structure)
+	}
+
+	if h.peek() != "{" {
+		return structure
+	}
+
+	h.pos++ // '{'
+	structure.defined = true
+	structure.line = line
+
+	for h.err == nil && h.peek() != "}" {
+		if h.pos >= len(h.tokens) {
+			h.fail("unexpected end of header")
+			return structure
+		}
+
+		base, _ := h.parseSpecifier()
+
+		for h.err == nil {
+			d := h.parseDeclarator(base)
+
+			if h.peek() == ":" {
+				// bit field
+				h.pos += 2
+				structure.unsupported = "bit fields are not supported"
+			}
+
+			array := len(d.dimensions) != 0
+
+			switch {
+			case d.function != nil:
+				structure.unsupported = fmt.Sprintf("field %s has function type", d.name)
+			case array:
+				h.addArrayField(structure, d)
+			case d.name == "":
+				structure.unsupported = "anonymous fields are not supported"
+			case d.t.unsupported != "":
+				structure.unsupported = fmt.Sprintf("field %s: %s", d.name, d.t.unsupported)
+			case d.t.structure != nil && d.t.structure.tinyName() == "":
+				structure.unsupported = fmt.Sprintf("field %s has anonymous type", d.name)
+			}
+
+			if !array {
+				structure.fields = append(structure.fields, &cField{name: d.name, t: d.t})
+			}
+
+			if h.peek() != "," {
+				break
+			}
+
+			h.pos++
+		}
+
+		h.expect(";")
+	}
+
+	h.expect("}")
+
+	if union {
+		structure.unsupported = "unions are not supported"
+	}
+
+	return structure
+}
+
+// maxArrayFieldLength is the maximal length of array fields, which are
+// converted to separate fields.
+const maxArrayFieldLength = 256
+
+// addArrayField adds fields `name_0`, `name_1`, ... for array field with
+// constant length, since Tiny has no fixed size arrays. Layout of the
+// structure stays the same.
+func (h *header) addArrayField(structure *cStruct, d *declarator) {
+	if len(d.dimensions) != 1 {
+		structure.unsupported = fmt.Sprintf("field %s is a multidimensional array", d.name)
+		return
+	}
+
+	length, ok := evaluate(d.dimensions[0], h.constants, h.isTypeName)
+	if !ok || length <= 0 || length > maxArrayFieldLength {
+		structure.unsupported = fmt.Sprintf("field %s is an array of unknown or too big length", d.name)
+		return
+	}
+
+	for i := int64(0); i < length; i++ {
+		structure.fields = append(structure.fields,
+			&cField{name: fmt.Sprintf("%s_%d", d.name, i), t: d.t})
+	}
+}
+
+// parseEnum parses `enum [tag] [{ members }]`, values of members are added to
+// constants. Returns nil if enum has no members list.
+func (h *header) parseEnum() *cEnum {
+	h.pos++ // 'enum'
+
+	enum := &cEnum{}
+	if h.peekAt(0).kind == cIdentifier {
+		enum.tag = h.peek()
+		h.pos++
+	}
+
+	if h.peek() != "{" {
+		return nil
+	}
+
+	h.pos++ // '{'
+	h.enums = append(h.enums, enum)
+
+	next := int64(0)
+
+	for h.err == nil && h.peek() != "}" {
+		if h.peekAt(0).kind != cIdentifier {
+			h.fail("expected enum member, got %q", h.peek())
+			return enum
+		}
+
+		member := &cConstant{name: h.peek(), value: next}
+		h.pos++
+
+		if h.peek() == "=" {
+			h.pos++
+
+			start := h.pos
+			depth := 0
+			for h.pos < len(h.tokens) && !(depth == 0 && (h.peek() == "," || h.peek() == "}")) {
+				switch h.peek() {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				}
+
+				h.pos++
+			}
+
+			value, ok := evaluate(h.tokens[start:h.pos], h.constants, h.isTypeName)
+			if !ok {
+				h.fail("can't evaluate value of %s", member.name)
+				return enum
+			}
+
+			member.value = value
+		}
+
+		enum.members = append(enum.members, member)
+		h.constants[member.name] = member.value
+		next = member.value + 1
+
+		if h.peek() == "," {
+			h.pos++
+		}
+	}
+
+	h.expect("}")
+	return enum
+}
+
+func (h *header) parseDeclarator(base *cType) *declarator {
+	t := *base
+	d := &declarator{t: &t}
+
+	for h.peek() == "*" || isQualifier(h.peek()) {
+		if h.peek() == "*" {
+			d.t.pointers++
+		}
+
+		h.pos++
+	}
+
+	if h.peek() == "(" && h.peekAt(1).text == "*" {
+		// function pointer: `(*name)(arguments)`
+		h.pos += 2
+
+		for isQualifier(h.peek()) {
+			h.pos++
+		}
+
+		if h.peekAt(0).kind == cIdentifier {
+			d.name = h.peek()
+			h.pos++
+		}
+
+		for h.peek() == "[" {
+			h.skipBalanced()
+		}
+
+		if !h.expect(")") {
+			return d
+		}
+
+		if h.peek() == "(" {
+			h.skipBalanced()
+		}
+
+		// function pointers are passed as opaque pointers
+		d.t = &cType{primary: "u8", pointers: 1}
+		return d
+	}
+
+	if h.peekAt(0).kind == cIdentifier && !isQualifier(h.peek()) &&
+		h.peek() != "__attribute__" {
+		d.name = h.peek()
+		h.pos++
+	}
+
+	for h.peek() == "[" {
+		start := h.pos
+		h.skipBalanced()
+		d.dimensions = append(d.dimensions, h.tokens[start+1:h.pos-1])
+	}
+
+	if h.peek() == "(" {
+		d.function = h.parseFunction(d.name, d.t)
+	}
+
+	for h.peek() == "__attribute__" || h.peek() == "__asm__" || h.peek() == "asm" {
+		h.pos++
+		h.skipBalanced()
+	}
+
+	return d
+}
+
+func (h *header) parseFunction(name string, returnType *cType) *cFunction {
+	function := &cFunction{name: name, returnType: returnType, line: h.line()}
+
+	h.pos++ // '('
+
+	if h.peek() == "void" && h.peekAt(1).text == ")" {
+		h.pos++
+	}
+
+	for h.err == nil && h.peek() != ")" {
+		if h.peek() == "..." {
+			function.variadic = true
+			h.pos++
+			break
+		}
+
+		base, _ := h.parseSpecifier()
+		if h.err != nil {
+			return function
+		}
+
+		d := h.parseDeclarator(base)
+
+		argument := &cField{name: d.name, t: d.t}
+		if len(d.dimensions) != 0 || d.function != nil {
+			// arrays and functions decay to pointers
+			argument.t.pointers++
+		}
+
+		function.arguments = append(function.arguments, argument)
+
+		if h.peek() != "," {
+			break
+		}
+
+		h.pos++
+	}
+
+	h.expect(")")
+	return function
+}
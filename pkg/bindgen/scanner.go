@@ -0,0 +1,237 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package bindgen
+
+import (
+	"strings"
+)
+
+const (
+	cIdentifier = iota
+	cNumber
+	cString
+	cCharacter
+	cPunctuator
+)
+
+type cToken struct {
+	kind int
+	text string
+	line int
+}
+
+// cDefine is an object-like macro: `#define NAME value`.
+type cDefine struct {
+	name  string
+	value []*cToken
+	line  int
+}
+
+var cPunctuators = []string{
+	"...", "<<=", ">>=", "<<", ">>", "&&", "||", "==", "!=", "<=", ">=", "->",
+	"++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "##",
+}
+
+// scanHeader splits C header into tokens. Comments are removed, preprocessor
+// directives are not tokenized, but object-like macros are returned
+// separately. Conditional directives are ignored, so declarations from all
+// branches are used.
+func scanHeader(source string) ([]*cToken, []*cDefine) {
+	tokens := []*cToken{}
+	defines := []*cDefine{}
+
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = removeComments(source)
+
+	lines := strings.Split(source, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		lineNumber := i + 1
+
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			directive := strings.TrimSpace(line)
+			for strings.HasSuffix(directive, "\\") && i+1 < len(lines) {
+				i++
+				directive = strings.TrimSuffix(directive, "\\") + " " + strings.TrimSpace(lines[i])
+			}
+
+			if define := parseDefine(directive[1:], lineNumber); define != nil {
+				defines = append(defines, define)
+			}
+
+			continue
+		}
+
+		tokens = append(tokens, scanLine(line, lineNumber)...)
+	}
+
+	return tokens, defines
+}
+
+// removeComments replaces comments with spaces keeping line breaks, so that
+// line numbers don't change.
+func removeComments(source string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(source); i++ {
+		switch {
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+
+			if i < len(source) {
+				sb.WriteByte('\n')
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end == -1 {
+				end = len(source) - i - 2
+			}
+
+			comment := source[i : i+2+end]
+			sb.WriteString(strings.Repeat("\n", strings.Count(comment, "\n")))
+			sb.WriteByte(' ')
+			i += end + 3
+		case source[i] == '"' || source[i] == '\'':
+			quote := source[i]
+			sb.WriteByte(quote)
+
+			for i++; i < len(source) && source[i] != quote && source[i] != '\n'; i++ {
+				if source[i] == '\\' && i+1 < len(source) {
+					sb.WriteByte(source[i])
+					i++
+				}
+
+				sb.WriteByte(source[i])
+			}
+
+			if i < len(source) {
+				sb.WriteByte(source[i])
+			}
+		default:
+			sb.WriteByte(source[i])
+		}
+	}
+
+	return sb.String()
+}
+
+func parseDefine(directive string, line int) *cDefine {
+	directive = strings.TrimSpace(directive)
+	if !strings.HasPrefix(directive, "define") {
+		return nil
+	}
+
+	directive = directive[len("define"):]
+	if directive == "" || (directive[0] != ' ' && directive[0] != '\t') {
+		return nil
+	}
+
+	directive = strings.TrimLeft(directive, " \t")
+
+	nameLength := 0
+	for nameLength < len(directive) && isIdentifierCharacter(directive[nameLength]) {
+		nameLength++
+	}
+
+	// function-like macros have '(' right after the name
+	if nameLength == 0 || nameLength < len(directive) && directive[nameLength] == '(' {
+		return nil
+	}
+
+	return &cDefine{
+		name:  directive[:nameLength],
+		value: scanLine(directive[nameLength:], line),
+		line:  line,
+	}
+}
+
+func isIdentifierCharacter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func scanLine(line string, lineNumber int) []*cToken {
+	tokens := []*cToken{}
+
+	for i := 0; i < len(line); {
+		c := line[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\f' || c == '\v':
+			i++
+			continue
+		case '0' <= c && c <= '9' || c == '.' && i+1 < len(line) && '0' <= line[i+1] && line[i+1] <= '9':
+			for i < len(line) && (isIdentifierCharacter(line[i]) || line[i] == '.') {
+				i++
+			}
+
+			tokens = append(tokens, &cToken{kind: cNumber, text: line[start:i], line: lineNumber})
+		case isIdentifierCharacter(c):
+			for i < len(line) && isIdentifierCharacter(line[i]) {
+				i++
+			}
+
+			tokens = append(tokens, &cToken{kind: cIdentifier, text: line[start:i], line: lineNumber})
+		case c == '"' || c == '\'':
+			for i++; i < len(line) && line[i] != c; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+
+			if i < len(line) {
+				i++
+			}
+
+			kind := cString
+			if c == '\'' {
+				kind = cCharacter
+			}
+
+			tokens = append(tokens, &cToken{kind: kind, text: line[start:min(i, len(line))], line: lineNumber})
+		default:
+			text := line[i : i+1]
+			for _, punctuator := range cPunctuators {
+				if strings.HasPrefix(line[i:], punctuator) {
+					text = punctuator
+					break
+				}
+			}
+
+			i += len(text)
+			tokens = append(tokens, &cToken{kind: cPunctuator, text: text, line: lineNumber})
+		}
+	}
+
+	return tokens
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,332 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package checker
+
+import (
+	"strings"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// access checks that members and functions of other namespaces, which are not
+// `pub`, are not used and that readonly members are changed only by methods
+// of their structure.
+//
+// Types of expressions are inferred from declarations of variables,
+// arguments, members and return types of functions. Members of values, which
+// type is not known, are not checked.
+type access struct {
+	checker *Checker
+
+	// types of variables and arguments, innermost scope last
+	scopes []map[string]ast.Type
+}
+
+func (c *Checker) checkAccess(function *ast.FunctionDeclaration) {
+	arguments := map[string]ast.Type{}
+	for _, argument := range function.Arguments {
+		arguments[argument.Name] = argument.Type
+	}
+
+	a := &access{checker: c, scopes: []map[string]ast.Type{arguments}}
+	a.block(function.StatementsBlock)
+}
+
+func (a *access) block(block *ast.StatementsBlock) {
+	a.scopes = append(a.scopes, map[string]ast.Type{})
+
+	for _, statement := range block.Statements {
+		a.statement(statement)
+	}
+
+	a.scopes = a.scopes[:len(a.scopes)-1]
+}
+
+func (a *access) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.VarStatement:
+		a.expression(statement.Value)
+
+		t := statement.Type
+		if t == nil && statement.Value != nil {
+			t = a.typeOf(statement.Value)
+		}
+
+		a.scopes[len(a.scopes)-1][statement.Name.Name] = t
+	case *ast.AssignStatement:
+		a.expression(statement.Target)
+		a.expression(statement.Value)
+		a.assigned(statement.Target)
+	case *ast.DestroyStatement:
+		a.expression(statement.Value)
+	case *ast.IfStatement:
+		a.expression(statement.Condition)
+		a.block(statement.Consequence)
+
+		if statement.Alternative != nil {
+			a.statement(statement.Alternative)
+		}
+	case *ast.StatementsBlock:
+		a.block(statement)
+	case *ast.ReturnStatement:
+		a.expression(statement.ReturnValue)
+	case *ast.FailStatement:
+		a.expression(statement.Error)
+	case ast.Expression:
+		a.expression(statement)
+	}
+}
+
+// expression reports members and qualified names of the expression, which
+// are not public.
+func (a *access) expression(expression ast.Expression) {
+	ast.Inspect(expression, func(node ast.AST) bool {
+		switch node := node.(type) {
+		case *ast.HandleExpression:
+			// handler has its own statements and the error variable
+			ast.Inspect(node.Call, func(node ast.AST) bool {
+				a.member(node)
+				return true
+			})
+
+			if node.Handler != nil {
+				scope := map[string]ast.Type{}
+				if node.ErrorName != nil {
+					scope[node.ErrorName.Name] = nil
+				}
+
+				a.scopes = append(a.scopes, scope)
+				a.block(node.Handler)
+				a.scopes = a.scopes[:len(a.scopes)-1]
+			}
+
+			return false
+		default:
+			a.member(node)
+		}
+
+		return true
+	})
+}
+
+func (a *access) member(node ast.AST) {
+	expression, ok := node.(*ast.MemberExpression)
+	if !ok {
+		return
+	}
+
+	if name := a.qualifiedName(expression); name != "" {
+		if declaration := a.checker.resolve(name); declaration != nil && !isPublic(declaration) {
+			a.checker.problemHandler.AddCodeProblem(utils.NewLocalError(
+				expression.Location(), utils.NotPublicErr, name))
+		}
+
+		return
+	}
+
+	structure, namespace := a.structureOf(a.typeOf(expression.Left))
+	if structure == nil || namespace == "" {
+		return
+	}
+
+	public := true
+	if member := findMember(structure, expression.Member); member != nil {
+		public = member.Public
+	} else if method := findMethod(structure, expression.Member); method != nil {
+		public = method.Public
+	}
+
+	if !public {
+		a.checker.problemHandler.AddCodeProblem(utils.NewLocalError(
+			expression.MemberLocation, utils.NotPublicErr,
+			namespace+"."+structure.Name+"."+expression.Member))
+	}
+}
+
+// assigned reports assignment to a readonly member outside of methods of its
+// structure.
+func (a *access) assigned(target ast.Expression) {
+	expression, ok := target.(*ast.MemberExpression)
+	if !ok || a.qualifiedName(expression) != "" {
+		return
+	}
+
+	structure, _ := a.structureOf(a.typeOf(expression.Left))
+	if structure == nil || structure == a.checker.structure {
+		return
+	}
+
+	member := findMember(structure, expression.Member)
+	if member == nil || !member.Readonly {
+		return
+	}
+
+	a.checker.problemHandler.AddCodeProblem(utils.NewLocalError(
+		target.Location(), utils.ReadonlyAssignmentErr, structure.Name+"."+member.Name).
+		WithNote("readonly members can only be changed by methods of " + structure.Name))
+}
+
+// qualifiedName returns name of the declaration from imported namespace, if
+// expression refers to it, like `bank.open`, or an empty string otherwise.
+func (a *access) qualifiedName(expression *ast.MemberExpression) string {
+	left, ok := expression.Left.(*ast.Name)
+	if !ok || left.Name == "this" {
+		return ""
+	}
+
+	if _, ok := a.variable(left.Name); ok {
+		return ""
+	}
+
+	return left.Name + "." + expression.Member
+}
+
+func (a *access) variable(name string) (ast.Type, bool) {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if t, ok := a.scopes[i][name]; ok {
+			return t, true
+		}
+	}
+
+	return nil, false
+}
+
+// typeOf returns type of the expression or nil if it's not known. Types of
+// declarations from other namespaces are qualified with their namespace.
+func (a *access) typeOf(expression ast.Expression) ast.Type {
+	switch expression := expression.(type) {
+	case *ast.Name:
+		if expression.Name == "this" && a.checker.structure != nil {
+			return &ast.CustomType{TypeLocation: expression.Location(),
+				Name: a.checker.structure.Name}
+		}
+
+		t, _ := a.variable(expression.Name)
+		return t
+	case *ast.NewExpression:
+		return &ast.PointerType{StartLocation: expression.StartLocation, Type: expression.Type}
+	case *ast.HandleExpression:
+		return a.typeOf(expression.Call)
+	case *ast.IndexExpression:
+		if array, ok := a.typeOf(expression.Left).(*ast.ArrayType); ok {
+			return array.Type
+		}
+	case *ast.MemberExpression:
+		structure, namespace := a.structureOf(a.typeOf(expression.Left))
+		if structure == nil {
+			return nil
+		}
+
+		if member := findMember(structure, expression.Member); member != nil {
+			return qualify(member.Type, namespace)
+		}
+	case *ast.CallExpression:
+		switch function := expression.Function.(type) {
+		case *ast.Name:
+			if declaration, ok := a.checker.resolve(function.Name).(*ast.FunctionDeclaration); ok {
+				return declaration.ReturnType
+			}
+		case *ast.MemberExpression:
+			if name := a.qualifiedName(function); name != "" {
+				if declaration, ok := a.checker.resolve(name).(*ast.FunctionDeclaration); ok {
+					return qualify(declaration.ReturnType, function.Left.(*ast.Name).Name)
+				}
+
+				return nil
+			}
+
+			structure, namespace := a.structureOf(a.typeOf(function.Left))
+			if structure == nil {
+				return nil
+			}
+
+			if method := findMethod(structure, function.Member); method != nil {
+				return qualify(method.ReturnType, namespace)
+			}
+		}
+	}
+
+	return nil
+}
+
+// structureOf returns structure, which values or pointers to them have the
+// type, and namespace of the structure, if it's declared in another
+// namespace.
+func (a *access) structureOf(t ast.Type) (*ast.StructureDeclaration, string) {
+	if pointer, ok := t.(*ast.PointerType); ok {
+		t = pointer.Type
+	}
+
+	custom, ok := t.(*ast.CustomType)
+	if !ok {
+		return nil, ""
+	}
+
+	structure, ok := a.checker.resolve(custom.Name).(*ast.StructureDeclaration)
+	if !ok {
+		return nil, ""
+	}
+
+	namespace := ""
+	if dot := strings.LastIndex(custom.Name, "."); dot != -1 {
+		namespace = custom.Name[:dot]
+	}
+
+	return structure, namespace
+}
+
+// qualify returns type of declaration from the namespace with names of
+// structures qualified by the namespace, so they can be resolved in the
+// checked unit.
+func qualify(t ast.Type, namespace string) ast.Type {
+	if namespace == "" {
+		return t
+	}
+
+	switch t := t.(type) {
+	case *ast.PointerType:
+		return &ast.PointerType{StartLocation: t.StartLocation, Type: qualify(t.Type, namespace)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{StartLocation: t.StartLocation, Type: qualify(t.Type, namespace)}
+	case *ast.CustomType:
+		if strings.Contains(t.Name, ".") {
+			return t
+		}
+
+		return &ast.CustomType{TypeLocation: t.TypeLocation, Name: namespace + "." + t.Name,
+			Arguments: t.Arguments}
+	}
+
+	return t
+}
+
+func findMember(structure *ast.StructureDeclaration, name string) *ast.StructureMember {
+	for _, member := range structure.Members {
+		if member.Name == name {
+			return member
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,516 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package checker implements semantic checks of parsed Tiny program units.
+package checker
+
+import (
+	"strings"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+type Checker struct {
+	problemHandler *utils.CodeProblemHandler
+
+	// declarations of the checked unit by their names
+	declarations map[string]ast.TopLevelStatement
+
+	// resolves names, which are not declared in the checked unit
+	lookup func(name string) ast.TopLevelStatement
+
+	// structure, which methods are checked, nil outside of methods
+	structure *ast.StructureDeclaration
+}
+
+func NewChecker(problemHandler *utils.CodeProblemHandler) *Checker {
+	return &Checker{
+		problemHandler: problemHandler,
+		declarations:   map[string]ast.TopLevelStatement{},
+	}
+}
+
+// SetLookup sets function, which resolves names declared outside of the
+// checked unit: in other files of the same namespace or, if the name is
+// qualified, in imported namespaces. Without it, only declarations of the
+// unit itself are known and qualified names are not checked.
+func (c *Checker) SetLookup(lookup func(name string) ast.TopLevelStatement) {
+	c.lookup = lookup
+}
+
+// CheckProgramUnit reports semantic problems found in the program unit to
+// the problem handler.
+func (c *Checker) CheckProgramUnit(unit *ast.ProgramUnit) {
+	for _, statement := range unit.TLStatements {
+		switch statement := statement.(type) {
+		case *ast.FunctionDeclaration:
+			c.declarations[statement.Name] = statement
+		case *ast.StructureDeclaration:
+			c.declarations[statement.Name] = statement
+		case *ast.InterfaceDeclaration:
+			c.declarations[statement.Name] = statement
+		case *ast.ExternFunctionDeclaration:
+			c.declarations[statement.Name] = statement
+		}
+	}
+
+	for _, statement := range unit.TLStatements {
+		switch statement := statement.(type) {
+		case *ast.FunctionDeclaration:
+			c.checkFunction(statement)
+		case *ast.StructureDeclaration:
+			c.checkTypeParameters(statement.TypeParameters)
+			c.checkImplements(statement)
+
+			for _, member := range statement.Members {
+				c.checkType(member.Type)
+			}
+
+			c.structure = statement
+			for _, method := range statement.Methods {
+				c.checkFunction(method)
+			}
+			c.structure = nil
+
+			c.checkDestroy(statement)
+		case *ast.InterfaceDeclaration:
+			c.checkTypeParameters(statement.TypeParameters)
+
+			for _, method := range statement.Methods {
+				c.checkTypeParameters(method.TypeParameters)
+				c.checkArgumentsAndReturnType(method.Arguments, method.ReturnType)
+			}
+		case *ast.ExternFunctionDeclaration:
+			c.checkExternFunction(statement)
+		}
+	}
+}
+
+func (c *Checker) checkFunction(function *ast.FunctionDeclaration) {
+	c.checkTypeParameters(function.TypeParameters)
+	c.checkArgumentsAndReturnType(function.Arguments, function.ReturnType)
+
+	if function.StatementsBlock != nil {
+		c.checkReachability(function.StatementsBlock)
+		c.checkOwnership(function.StatementsBlock)
+		c.checkFailures(function)
+		c.checkAccess(function)
+	}
+}
+
+// checkReachability reports statements following return or fail statement
+// of the block.
+func (c *Checker) checkReachability(block *ast.StatementsBlock) {
+	last := len(block.Statements) - 1
+
+	for i, statement := range block.Statements {
+		var keyword string
+
+		switch statement.(type) {
+		case *ast.ReturnStatement:
+			keyword = "return"
+		case *ast.FailStatement:
+			keyword = "fail"
+		}
+
+		if keyword == "" || i == last {
+			continue
+		}
+
+		c.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+			&utils.CodeBlockLocation{
+				StartLocation: block.Statements[i+1].Location().StartLocation,
+				EndLocation:   block.Statements[last].Location().EndLocation,
+			},
+			utils.UnreachableCodeWarn).
+			WithLabel(statement.Location(), "any code following this "+keyword+" is unreachable"))
+		return
+	}
+}
+
+// checkShadowing reports type parameter or argument, which has the same name
+// as a top level declaration.
+func (c *Checker) checkShadowing(name string, location *utils.CodeBlockLocation) {
+	if declaration := c.resolve(name); declaration != nil {
+		c.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+			location, utils.ShadowingWarn, name).
+			WithLabel(declaration.Location(), "shadowed declaration is here"))
+	}
+}
+
+func (c *Checker) checkArgumentsAndReturnType(arguments []*ast.FunctionArgument,
+	returnType ast.Type) {
+	for _, argument := range arguments {
+		c.checkShadowing(argument.Name, argument.Location())
+		c.checkType(argument.Type)
+	}
+
+	if returnType != nil {
+		c.checkType(returnType)
+	}
+}
+
+// checkExternFunction checks that arguments and return type of extern function
+// can be passed to and from C.
+func (c *Checker) checkExternFunction(function *ast.ExternFunctionDeclaration) {
+	c.checkArgumentsAndReturnType(function.Arguments, function.ReturnType)
+
+	for _, argument := range function.Arguments {
+		c.checkExternType(function, argument.Type)
+	}
+
+	if function.ReturnType != nil {
+		c.checkExternType(function, function.ReturnType)
+	}
+}
+
+// checkExternType reports arrays and interfaces, which have no C equivalent.
+func (c *Checker) checkExternType(function *ast.ExternFunctionDeclaration, t ast.Type) {
+	switch t := t.(type) {
+	case *ast.PointerType:
+		c.checkExternType(function, t.Type)
+	case *ast.ArrayType:
+		c.problemHandler.AddCodeProblem(utils.NewLocalError(
+			t.Location(), utils.InvalidExternTypeErr, typeName(t), function.Name))
+	case *ast.CustomType:
+		if _, ok := c.resolve(t.Name).(*ast.InterfaceDeclaration); ok {
+			c.problemHandler.AddCodeProblem(utils.NewLocalError(
+				t.Location(), utils.InvalidExternTypeErr, typeName(t), function.Name))
+		}
+	}
+}
+
+// checkTypeParameters checks that constraints of type parameters are
+// interfaces.
+func (c *Checker) checkTypeParameters(parameters []*ast.TypeParameter) {
+	for _, parameter := range parameters {
+		c.checkShadowing(parameter.Name, parameter.Location())
+
+		if parameter.Constraint != nil {
+			c.checkInterfaceType(parameter.Constraint)
+		}
+	}
+}
+
+// checkType checks that instantiations of generic structures and interfaces
+// have right amount of type arguments and that the arguments satisfy
+// constraints of type parameters.
+func (c *Checker) checkType(t ast.Type) {
+	switch t := t.(type) {
+	case *ast.PointerType:
+		c.checkType(t.Type)
+	case *ast.ArrayType:
+		c.checkType(t.Type)
+	case *ast.CustomType:
+		for _, argument := range t.Arguments {
+			c.checkType(argument)
+		}
+
+		c.checkTypeArguments(t, c.resolveCustomType(t))
+	}
+}
+
+// checkInterfaceType resolves interface used as a constraint or in the list
+// of implemented interfaces and checks its type arguments. Returns nil if
+// the interface is not found or has wrong amount of type arguments.
+func (c *Checker) checkInterfaceType(t *ast.CustomType) *ast.InterfaceDeclaration {
+	for _, argument := range t.Arguments {
+		c.checkType(argument)
+	}
+
+	declaration := c.resolveInterface(t)
+	if declaration == nil || !c.checkTypeArguments(t, declaration) {
+		return nil
+	}
+
+	return declaration
+}
+
+// checkTypeArguments checks type arguments of the generic structure or
+// interface declaration and reports whether their amount is right.
+func (c *Checker) checkTypeArguments(t *ast.CustomType, declaration ast.TopLevelStatement) bool {
+	var parameters []*ast.TypeParameter
+
+	switch declaration := declaration.(type) {
+	case *ast.StructureDeclaration:
+		parameters = declaration.TypeParameters
+	case *ast.InterfaceDeclaration:
+		parameters = declaration.TypeParameters
+	default:
+		return true
+	}
+
+	if len(t.Arguments) != len(parameters) {
+		c.problemHandler.AddCodeProblem(utils.NewLocalError(
+			t.Location(), utils.WrongTypeArgumentsAmountErr,
+			t.Name, len(parameters), len(t.Arguments)))
+		return false
+	}
+
+	for i, parameter := range parameters {
+		if parameter.Constraint != nil &&
+			!c.satisfies(t.Arguments[i], parameter.Constraint) {
+			c.problemHandler.AddCodeProblem(utils.NewLocalError(
+				t.Arguments[i].Location(), utils.DoesNotSatisfyConstraintErr,
+				typeName(t.Arguments[i]), parameter.Constraint.Name))
+		}
+	}
+
+	return true
+}
+
+// satisfies reports whether type argument implements constraint interface.
+// Types, which can't be resolved in the unit (type parameters, types from
+// other namespaces) are assumed to satisfy the constraint.
+func (c *Checker) satisfies(argument ast.Type, constraint *ast.CustomType) bool {
+	switch argument := argument.(type) {
+	case *ast.CustomType:
+		if strings.Contains(argument.Name, ".") {
+			// interfaces listed by the structure are named relative to its
+			// own namespace, so they can't be compared with the constraint
+			return true
+		}
+
+		structure, ok := c.resolve(argument.Name).(*ast.StructureDeclaration)
+		if !ok {
+			return true
+		}
+
+		for _, implemented := range structure.Implements {
+			if implemented.Name == constraint.Name {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return false
+	}
+}
+
+func typeName(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.PrimaryType:
+		return t.Token.Literal
+	case *ast.PointerType:
+		return "*" + typeName(t.Type)
+	case *ast.ArrayType:
+		return "[]" + typeName(t.Type)
+	case *ast.CustomType:
+		return t.Name
+	}
+
+	return ""
+}
+
+// resolve returns top level declaration with the given name or nil if it is
+// unknown.
+func (c *Checker) resolve(name string) ast.TopLevelStatement {
+	if declaration, ok := c.declarations[name]; ok {
+		return declaration
+	}
+
+	if c.lookup != nil {
+		return c.lookup(name)
+	}
+
+	return nil
+}
+
+// resolveCustomType resolves name of the type and reports a problem if it
+// refers to a declaration from another namespace, which is not `pub`.
+func (c *Checker) resolveCustomType(t *ast.CustomType) ast.TopLevelStatement {
+	declaration := c.resolve(t.Name)
+
+	if declaration != nil && strings.Contains(t.Name, ".") && !isPublic(declaration) {
+		c.problemHandler.AddCodeProblem(utils.NewLocalError(
+			t.Location(), utils.NotPublicErr, t.Name))
+	}
+
+	return declaration
+}
+
+func isPublic(declaration ast.TopLevelStatement) bool {
+	switch declaration := declaration.(type) {
+	case *ast.FunctionDeclaration:
+		return declaration.Public
+	case *ast.StructureDeclaration:
+		return declaration.Public
+	case *ast.InterfaceDeclaration:
+		return declaration.Public
+	case *ast.ExternFunctionDeclaration:
+		return declaration.Public
+	}
+
+	return false
+}
+
+// resolveInterface looks for an interface declaration with the given name and
+// reports a problem if the name doesn't denote an interface. Returns nil if
+// interface is not found.
+func (c *Checker) resolveInterface(name *ast.CustomType) *ast.InterfaceDeclaration {
+	switch declaration := c.resolveCustomType(name).(type) {
+	case *ast.InterfaceDeclaration:
+		return declaration
+	case nil:
+		if c.lookup != nil || !strings.Contains(name.Name, ".") {
+			c.problemHandler.AddCodeProblem(utils.NewLocalError(
+				name.Location(), utils.UndefinedInterfaceErr, name.Name))
+		}
+	default:
+		c.problemHandler.AddCodeProblem(utils.NewLocalError(
+			name.Location(), utils.NotAnInterfaceErr, name.Name))
+	}
+
+	return nil
+}
+
+// checkImplements checks that structure has all methods of interfaces listed
+// in its declaration and that their signatures are the same.
+func (c *Checker) checkImplements(structure *ast.StructureDeclaration) {
+	for _, implemented := range structure.Implements {
+		declaration := c.checkInterfaceType(implemented)
+		if declaration == nil {
+			continue
+		}
+
+		// type parameters of the interface are replaced with type arguments
+		// listed by the structure: `struct Vec<T> : Container<T>`
+		substitution := map[string]ast.Type{}
+		for i, parameter := range declaration.TypeParameters {
+			substitution[parameter.Name] = implemented.Arguments[i]
+		}
+
+		for _, method := range declaration.Methods {
+			implementation := findMethod(structure, method.Name)
+			if implementation == nil {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					implemented.Location(), utils.MissingInterfaceMethodErr,
+					structure.Name, implemented.Name, method.Name).
+					WithLabel(method.Location(), method.Name+" is declared here"))
+				continue
+			}
+
+			if !identicalSignatures(method, implementation, substitution) {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					implementation.Location(), utils.WrongInterfaceMethodSignatureErr,
+					structure.Name, implemented.Name, method.Name).
+					WithLabel(method.Location(), "expected signature is declared here"))
+			}
+		}
+	}
+}
+
+func findMethod(structure *ast.StructureDeclaration, name string) *ast.FunctionDeclaration {
+	for _, method := range structure.Methods {
+		if method.Name == name {
+			return method
+		}
+	}
+
+	return nil
+}
+
+// identicalSignatures reports whether method has the signature of interface
+// method. substitution maps type parameters of the interface to types, which
+// replace them in the method.
+func identicalSignatures(signature *ast.FunctionSignature,
+	function *ast.FunctionDeclaration, substitution map[string]ast.Type) bool {
+	if len(signature.Arguments) != len(function.Arguments) ||
+		len(signature.TypeParameters) != len(function.TypeParameters) ||
+		signature.Failable != function.Failable {
+		return false
+	}
+
+	// type parameters of the method can be named differently, like
+	// `fun map<U>()` and `fun map<V>()`
+	if len(signature.TypeParameters) != 0 {
+		renamed := make(map[string]ast.Type, len(substitution))
+		for name, t := range substitution {
+			renamed[name] = t
+		}
+
+		for i, parameter := range signature.TypeParameters {
+			renamed[parameter.Name] = &ast.CustomType{Name: function.TypeParameters[i].Name}
+		}
+
+		substitution = renamed
+	}
+
+	for i, parameter := range signature.TypeParameters {
+		constraint := function.TypeParameters[i].Constraint
+		if (parameter.Constraint == nil) != (constraint == nil) ||
+			parameter.Constraint != nil &&
+				!identicalTypes(parameter.Constraint, constraint, substitution) {
+			return false
+		}
+	}
+
+	for i, argument := range signature.Arguments {
+		if !identicalTypes(argument.Type, function.Arguments[i].Type, substitution) {
+			return false
+		}
+	}
+
+	return identicalTypes(signature.ReturnType, function.ReturnType, substitution)
+}
+
+// identicalTypes reports whether two type expressions denote the same type,
+// if names of a from substitution are replaced with their types. nil means
+// absence of type (function returns nothing).
+func identicalTypes(a ast.Type, b ast.Type, substitution map[string]ast.Type) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case *ast.PrimaryType:
+		b, ok := b.(*ast.PrimaryType)
+		return ok && a.Token.Kind == b.Token.Kind
+	case *ast.PointerType:
+		b, ok := b.(*ast.PointerType)
+		return ok && identicalTypes(a.Type, b.Type, substitution)
+	case *ast.ArrayType:
+		b, ok := b.(*ast.ArrayType)
+		return ok && identicalTypes(a.Type, b.Type, substitution)
+	case *ast.CustomType:
+		if t, ok := substitution[a.Name]; ok && len(a.Arguments) == 0 {
+			return identicalTypes(t, b, nil)
+		}
+
+		b, ok := b.(*ast.CustomType)
+		if !ok || a.Name != b.Name || len(a.Arguments) != len(b.Arguments) {
+			return false
+		}
+
+		for i, argument := range a.Arguments {
+			if !identicalTypes(argument, b.Arguments[i], substitution) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
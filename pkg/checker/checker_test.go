@@ -0,0 +1,574 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package checker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/parser"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+func check(source string) *utils.CodeProblemHandler {
+	p := utils.NewCodeProblemHandler()
+	unit := parser.NewParser("", []byte(source), p).ParseProgramUnit()
+	NewChecker(p).CheckProgramUnit(unit)
+	return p
+}
+
+func TestImplements(t *testing.T) {
+	p := check(`namespace "io";
+	interface Reader {
+		fun read(buffer: *u8, size: u64): u64;
+		fun close();
+	}
+
+	struct File : Reader, other.Writer {
+		fun read(buffer: *u8, size: u64): u64 {}
+		fun close() {}
+	}`)
+	assert.True(t, p.Ok)
+}
+
+func TestMissingMethod(t *testing.T) {
+	p := check(`namespace "io";
+	interface Reader {
+		fun read(buffer: *u8, size: u64): u64;
+		fun close();
+	}
+
+	struct File : Reader {
+		fun read(buffer: *u8, size: u64): u64 {}
+	}`)
+	assert.False(t, p.Ok)
+}
+
+func TestWrongSignature(t *testing.T) {
+	p := check(`namespace "io";
+	interface Reader {
+		fun read(buffer: *u8, size: u64): u64;
+	}
+
+	struct File : Reader {
+		fun read(buffer: *u8, size: u32): u64 {}
+	}`)
+	assert.False(t, p.Ok)
+}
+
+func TestNotAnInterface(t *testing.T) {
+	assert.False(t, check(`namespace "io";
+	struct Reader {}
+	struct File : Reader {}`).Ok)
+	assert.False(t, check(`namespace "io";
+	struct File : Reader {}`).Ok)
+}
+
+func TestTypeArgumentsAmount(t *testing.T) {
+	p := check(`namespace "collections";
+	struct Pair<K, V> {}
+	struct Table { rows: []Pair<i32>; }`)
+	assert.False(t, p.Ok)
+}
+
+func TestConstraints(t *testing.T) {
+	source := `namespace "collections";
+	interface Comparable {
+		fun compare(other: *u8): i32;
+	}
+
+	struct Key : Comparable {
+		fun compare(other: *u8): i32 {}
+	}
+
+	struct Value {}
+
+	struct Tree<K: Comparable, V> {}
+	`
+	assert.True(t, check(source+"fun f(tree: *Tree<Key, Value>) {}").Ok)
+	assert.False(t, check(source+"fun f(tree: *Tree<Value, Key>) {}").Ok)
+	assert.False(t, check(source+"fun f(tree: *Tree<i32, Key>) {}").Ok)
+	assert.False(t, check(source+"fun max<T: Value>(a: T, b: T): T {}").Ok)
+}
+
+func TestVisibility(t *testing.T) {
+	imported := map[string]ast.TopLevelStatement{
+		"bank.Account": &ast.StructureDeclaration{Name: "Account", Public: true},
+		"bank.Secret":  &ast.StructureDeclaration{Name: "Secret", Public: false},
+		"io.Writer":    &ast.InterfaceDeclaration{Name: "Writer", Public: false},
+	}
+
+	check := func(source string) *utils.CodeProblemHandler {
+		p := utils.NewCodeProblemHandler()
+		unit := parser.NewParser("", []byte(source), p).ParseProgramUnit()
+		c := NewChecker(p)
+		c.SetLookup(func(name string) ast.TopLevelStatement { return imported[name] })
+		c.CheckProgramUnit(unit)
+		return p
+	}
+
+	assert.True(t, check(`namespace "app";
+	struct Wallet { account: *bank.Account; }`).Ok)
+	assert.False(t, check(`namespace "app";
+	struct Wallet { secret: *bank.Secret; }`).Ok)
+	assert.False(t, check(`namespace "app";
+	fun f(accounts: []Vec<bank.Secret>) {}`).Ok)
+	assert.False(t, check(`namespace "app";
+	struct Log : io.Writer {}`).Ok)
+}
+
+func TestExternFunction(t *testing.T) {
+	assert.True(t, check(`namespace "hal";
+	struct GPIO {}
+	extern fun printf(fmt: *u8, ...): i32;
+	extern fun HAL_GPIO_Init(gpio: *GPIO, pin: u16);`).Ok)
+	assert.False(t, check(`namespace "hal";
+	extern fun write(data: []u8);`).Ok)
+	assert.False(t, check(`namespace "hal";
+	interface Writer {}
+	extern fun write(writer: *Writer);`).Ok)
+}
+
+func TestUnreachableCode(t *testing.T) {
+	p := check(`namespace "app";
+	fun f(): bool {
+		return true;
+		"unreachable";
+		[true, false];
+	}
+
+	fun g() {
+		"reachable";
+		return;
+	}`)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 1, len(p.Problems()))
+
+	problem := p.Problems()[0]
+	assert.Equal(t, utils.UnreachableCodeWarn, problem.Code())
+	assert.Equal(t, 4, problem.Location().StartLocation.Line)
+	assert.Equal(t, 5, problem.Location().EndLocation.Line)
+	assert.Equal(t, 3, problem.Labels()[0].Location.StartLocation.Line)
+}
+
+func TestShadowing(t *testing.T) {
+	source := `namespace "app";
+	struct Node {}
+	struct List<Node> {
+		fun add(List: Node) {}
+	}`
+
+	// disabled by default
+	assert.Empty(t, check(source).Problems())
+
+	settings := utils.NewWarningSettings()
+	settings.Set("shadowing")
+
+	p := utils.NewCodeProblemHandler()
+	p.SetWarningSettings(settings)
+	NewChecker(p).CheckProgramUnit(
+		parser.NewParser("", []byte(source), p).ParseProgramUnit())
+
+	assert.True(t, p.Ok)
+	assert.Equal(t, 2, len(p.Problems()))
+	assert.Equal(t, "Node shadows declaration with the same name", p.Problems()[0].Message())
+	assert.Equal(t, 2, p.Problems()[0].Labels()[0].Location.StartLocation.Line)
+	assert.Equal(t, "List shadows declaration with the same name", p.Problems()[1].Message())
+}
+
+// warnings returns messages of problems with their lines.
+func warnings(p *utils.CodeProblemHandler) map[string]int {
+	messages := map[string]int{}
+	for _, problem := range p.Problems() {
+		messages[problem.Message()] = problem.Location().StartLocation.Line
+	}
+
+	return messages
+}
+
+func TestOwnership(t *testing.T) {
+	p := check(`namespace "app";
+	fun leaks(ok: bool): *Account {
+		var a = new Account();
+		var b = new Account();
+		var c = new Account();
+		if ok {
+			destroy a;
+			return c;
+		}
+
+		var d = b;
+		destroy d;
+		return c;
+	}
+
+	fun destroys(ok: bool) {
+		var a = new Account();
+		if ok {
+			destroy a;
+		}
+
+		a.print();
+		destroy a;
+
+		var name = "Adi";
+		destroy name;
+		destroy 42;
+
+		var b = new Account();
+		b = new Account();
+		if ok {
+			destroy b;
+		} else {
+			destroy b;
+		}
+		destroy b;
+	}`)
+	assert.True(t, p.Ok)
+	assert.Equal(t, map[string]int{
+		"a is not destroyed on every path": 3,
+		"a is used after it is destroyed":  22,
+		"a is destroyed twice":             23,
+		"name is not allocated with new":   26,
+		"42 is not allocated with new":     27,
+		"b is not destroyed on every path": 29,
+		"b is destroyed twice":             36,
+	}, warnings(p))
+
+	for _, problem := range p.Problems() {
+		switch problem.Message() {
+		case "a is not destroyed on every path":
+			// leak on the path, which doesn't enter the branch
+			assert.Equal(t, 13, problem.Labels()[0].Location.StartLocation.Line)
+		case "a is used after it is destroyed":
+			assert.Equal(t, 19, problem.Labels()[0].Location.StartLocation.Line)
+			assert.Equal(t, 1, len(problem.Notes()))
+		case "b is destroyed twice":
+			assert.Empty(t, problem.Notes())
+		}
+	}
+
+	// values moved to other variables, members and callers are not leaked
+	assert.Empty(t, check(`namespace "app";
+	fun f(ok: bool): *Account {
+		var a = new Account();
+		this.account = a;
+		var b = new Account();
+		if ok {
+			var c = b;
+			return c;
+		}
+
+		return b;
+	}`).Problems())
+}
+
+// TestErrorExplanations checks that examples of `tinyc explain` are right.
+func TestErrorExplanations(t *testing.T) {
+	for _, code := range utils.ErrorCodes() {
+		explanation := utils.ExplainError(code)
+		if !assert.NotNil(t, explanation, utils.ErrorID(code)) || explanation.Wrong == "" {
+			continue
+		}
+
+		reported := false
+		for _, problem := range check(explanation.Wrong).Problems() {
+			if problem.Code() == code {
+				reported = true
+			}
+		}
+
+		assert.True(t, reported, "%s is not reported", utils.ErrorID(code))
+		assert.Empty(t, check(explanation.Right).Problems(), utils.ErrorID(code))
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	unit := parser.NewParser("", []byte(`namespace "bank";
+	struct Owner {
+		name: string;
+		@nodestroy bank: *Bank;
+		age: u8;
+	}
+
+	struct Account {
+		owner: Owner;
+		history: []u64;
+		id: u64;
+
+		pub destroy() {
+			this.owner.destroy();
+		}
+	}
+
+	struct Bank {
+		@nodestroy accounts: []*Account;
+		id: u64;
+	}`), utils.NewCodeProblemHandler()).ParseProgramUnit()
+	p := utils.NewCodeProblemHandler()
+	NewChecker(p).CheckProgramUnit(unit)
+
+	assert.True(t, p.Ok)
+	assert.Equal(t, map[string]int{
+		"destroy of Account doesn't destroy member history": 13,
+	}, warnings(p))
+	assert.Equal(t, 10, p.Problems()[0].Labels()[0].Location.StartLocation.Line)
+
+	owner := unit.TLStatements[0].(*ast.StructureDeclaration)
+	assert.True(t, owner.Members[1].NoDestroy)
+	if assert.NotNil(t, owner.DefaultDestroy) {
+		assert.Equal(t, "destroy", owner.DefaultDestroy.Name)
+		assert.Equal(t, 1, len(owner.DefaultDestroy.StatementsBlock.Statements))
+		assert.Equal(t, "name", thisMember(
+			owner.DefaultDestroy.StatementsBlock.Statements[0].(*ast.DestroyStatement).Value))
+	}
+
+	assert.Nil(t, unit.TLStatements[1].(*ast.StructureDeclaration).DefaultDestroy)
+	assert.Nil(t, unit.TLStatements[2].(*ast.StructureDeclaration).DefaultDestroy)
+}
+
+func TestDestroyCascade(t *testing.T) {
+	unit := parser.NewParser("", []byte(`namespace "app";
+	struct Window {
+		title: Title;
+		size: Size;
+	}
+
+	struct Title { text: string; }
+	struct Size { width: u32; height: u32; }`), utils.NewCodeProblemHandler()).ParseProgramUnit()
+	p := utils.NewCodeProblemHandler()
+	NewChecker(p).CheckProgramUnit(unit)
+
+	assert.Empty(t, p.Problems())
+
+	window := unit.TLStatements[0].(*ast.StructureDeclaration)
+	if assert.NotNil(t, window.DefaultDestroy) {
+		statements := window.DefaultDestroy.StatementsBlock.Statements
+		assert.Equal(t, 1, len(statements))
+
+		call := statements[0].(*ast.CallExpression).Function.(*ast.MemberExpression)
+		assert.Equal(t, "destroy", call.Member)
+		assert.Equal(t, "title", thisMember(call.Left))
+	}
+}
+
+func TestFailures(t *testing.T) {
+	p := check(`namespace "app";
+	fun read(): u8 fails {
+		return 0;
+	}
+
+	fun print(value: u8) {}
+
+	fun process(): u8 fails {
+		var a = read() handle;
+		print(read());
+		print(a) handle;
+		fail 1;
+	}
+
+	fun main() {
+		read() handle;
+		read() handle err {
+			fail err;
+		}
+	}
+
+	interface Reader {
+		fun read(): u8 fails;
+	}
+
+	struct File : Reader {
+		fun read(): u8 {
+			this.read() handle;
+			return 0;
+		}
+	}`)
+	assert.False(t, p.Ok)
+
+	errors := map[string]int{}
+	for _, problem := range p.Problems() {
+		errors[problem.Message()] = problem.Location().StartLocation.Line
+	}
+
+	// fail in the handler of main is reported after its default handler
+	assert.Equal(t, map[string]int{
+		"error of failable function read is not handled":                   10,
+		"function print can't fail, so there is nothing to handle":         11,
+		"function main is not declared with fails":                         18,
+		"File does not implement Reader (wrong signature for method read)": 27,
+		"function read can't fail, so there is nothing to handle":          28,
+		"function read is not declared with fails":                         28,
+	}, errors)
+	assert.Equal(t, 7, len(p.Problems()))
+}
+
+func TestLeakOnFailure(t *testing.T) {
+	p := check(`namespace "app";
+	fun read(buffer: *u8): u64 fails {
+		return 0;
+	}
+
+	fun load(): u64 fails {
+		var buffer = new Buffer();
+		var size = read(buffer) handle;
+		destroy buffer;
+		if size == 0 {
+			fail 1;
+		}
+
+		return size;
+	}`)
+	assert.True(t, p.Ok)
+	assert.Equal(t, map[string]int{"buffer is not destroyed on every path": 7}, warnings(p))
+	assert.Equal(t, 8, p.Problems()[0].Labels()[0].Location.StartLocation.Line)
+}
+
+func TestGenericInterfaces(t *testing.T) {
+	source := `namespace "collections";
+	interface Comparable {
+		fun compare(other: *u8): i32;
+	}
+
+	interface Container<T> {
+		fun get(index: u64): T;
+		fun map<U: Comparable>(value: U): Container<U>;
+	}
+
+	struct Key : Comparable {
+		fun compare(other: *u8): i32 {}
+	}
+	`
+	assert.True(t, check(source+`struct Vec<E> : Container<E> {
+		fun get(index: u64): E {}
+		fun map<V: Comparable>(value: V): Container<V> {}
+	}
+	fun f(keys: *Container<Key>) {}`).Ok)
+
+	// amount of type arguments of interfaces is checked like of structures
+	assert.False(t, check(source+"fun f(keys: *Container<Key, Key>) {}").Ok)
+	assert.False(t, check(source+"fun f<T: Container>(keys: *T) {}").Ok)
+	assert.False(t, check(source+"struct Vec<E> : Container {}").Ok)
+
+	// type parameters of interface are replaced with arguments of implements
+	assert.False(t, check(source+`struct Vec<E> : Container<Key> {
+		fun get(index: u64): E {}
+		fun map<V: Comparable>(value: V): Container<V> {}
+	}`).Ok)
+
+	// type parameters of methods are compared with their constraints
+	assert.False(t, check(source+`struct Vec<E> : Container<E> {
+		fun get(index: u64): E {}
+		fun map<V>(value: V): Container<V> {}
+	}`).Ok)
+	assert.False(t, check(source+`struct Vec<E> : Container<E> {
+		fun get(index: u64): E {}
+		fun map(value: Key): Container<Key> {}
+	}`).Ok)
+}
+
+func TestMemberAccess(t *testing.T) {
+	bank := parser.NewParser("", []byte(`namespace "bank";
+	pub struct Account {
+		pub readonly age: i32;
+		pub owner: Owner;
+		password: string;
+
+		pub fun check(password: string): bool {}
+		fun hash(): u64 {}
+	}
+
+	pub struct Owner {
+		pub readonly name: string;
+	}
+
+	pub fun open(): *Account {}
+	fun audit() {}`), utils.NewCodeProblemHandler()).ParseProgramUnit()
+
+	imported := map[string]ast.TopLevelStatement{}
+	for _, statement := range bank.TLStatements {
+		switch statement := statement.(type) {
+		case *ast.StructureDeclaration:
+			imported["bank."+statement.Name] = statement
+		case *ast.FunctionDeclaration:
+			imported["bank."+statement.Name] = statement
+		}
+	}
+
+	check := func(source string) *utils.CodeProblemHandler {
+		p := utils.NewCodeProblemHandler()
+		unit := parser.NewParser("", []byte(`namespace "app";
+		`+source), p).ParseProgramUnit()
+		c := NewChecker(p)
+		c.SetLookup(func(name string) ast.TopLevelStatement { return imported[name] })
+		c.CheckProgramUnit(unit)
+		return p
+	}
+
+	assert.True(t, check(`fun main(account: *bank.Account): i32 {
+		var other = bank.open();
+		other.check("1234");
+		other.owner.name;
+		return account.age;
+	}`).Ok)
+
+	assert.False(t, check(`fun main(account: *bank.Account) {
+		account.password;
+	}`).Ok)
+	assert.False(t, check(`fun main() {
+		var account = bank.open();
+		account.hash();
+	}`).Ok)
+	assert.False(t, check(`fun main() {
+		bank.audit();
+	}`).Ok)
+	assert.False(t, check(`fun main() {
+		var account = new bank.Account();
+		account.age = 15;
+		destroy account;
+	}`).Ok)
+	assert.False(t, check(`fun main(account: *bank.Account) {
+		account.owner.name = "Adi";
+	}`).Ok)
+
+	// readonly members can be changed only by methods of their structure
+	assert.True(t, check(`struct Counter {
+		pub readonly value: u64;
+
+		pub fun increment() {
+			this.value = this.value + 1;
+		}
+	}`).Ok)
+	assert.False(t, check(`struct Counter {
+		pub readonly value: u64;
+	}
+
+	struct Clock {
+		counter: Counter;
+
+		pub fun tick() {
+			this.counter.value = 0;
+		}
+	}`).Ok)
+}
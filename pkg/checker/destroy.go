@@ -0,0 +1,211 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package checker
+
+import (
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// How a structure destroys its member.
+const (
+	// member is not owned by the structure, like a number or a member
+	// marked with `@nodestroy`
+	notOwned = iota
+
+	// member is freed: `destroy this.name;`
+	freed
+
+	// member is a structure value, which destroy is called:
+	// `this.parent.destroy();`
+	cascaded
+)
+
+// checkDestroy generates default destroy for the structure, if it owns heap
+// members and doesn't declare its own destroy, or reports owned members,
+// which the user-written destroy forgets to destroy.
+func (c *Checker) checkDestroy(structure *ast.StructureDeclaration) {
+	destroy := findMethod(structure, "destroy")
+	if destroy == nil {
+		structure.DefaultDestroy = c.defaultDestroy(structure)
+		return
+	}
+
+	if destroy.StatementsBlock == nil {
+		return
+	}
+
+	destroyed := map[string]bool{}
+
+	ast.Inspect(destroy.StatementsBlock, func(node ast.AST) bool {
+		switch node := node.(type) {
+		case *ast.DestroyStatement:
+			if member := thisMember(node.Value); member != "" {
+				destroyed[member] = true
+			}
+		case *ast.CallExpression:
+			if method, ok := node.Function.(*ast.MemberExpression); ok && method.Member == "destroy" {
+				if member := thisMember(method.Left); member != "" {
+					destroyed[member] = true
+				}
+			}
+		}
+
+		return true
+	})
+
+	for _, member := range structure.Members {
+		if destroyed[member.Name] {
+			continue
+		}
+
+		var help string
+
+		switch c.memberDestroy(member, map[*ast.StructureDeclaration]bool{}) {
+		case notOwned:
+			continue
+		case freed:
+			help = "destroy it with `destroy this." + member.Name + ";`"
+		case cascaded:
+			help = "destroy it with `this." + member.Name + ".destroy();`"
+		}
+
+		c.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+			destroy.Location(), utils.IncompleteDestroyWarn, structure.Name, member.Name).
+			WithLabel(member.Location(), member.Name+" is declared here").
+			WithHelp(help).
+			WithHelp("mark it with `@nodestroy`, if the structure doesn't own it"))
+	}
+}
+
+// defaultDestroy returns destroy, which destroys all owned members of the
+// structure in the order of their declaration, or nil if there is nothing to
+// destroy. Generated statements have locations of the members.
+func (c *Checker) defaultDestroy(structure *ast.StructureDeclaration) *ast.FunctionDeclaration {
+	var statements []ast.Statement
+
+	for _, member := range structure.Members {
+		location := member.Location()
+		this := &ast.MemberExpression{
+			Left:           &ast.Name{TokenLocation: location, Name: "this"},
+			Member:         member.Name,
+			MemberLocation: location,
+		}
+
+		switch c.memberDestroy(member, map[*ast.StructureDeclaration]bool{}) {
+		case freed:
+			statements = append(statements, &ast.DestroyStatement{
+				StartLocation: location.StartLocation,
+				Value:         this,
+				EndLocation:   location.EndLocation,
+			})
+		case cascaded:
+			statements = append(statements, &ast.CallExpression{
+				Function: &ast.MemberExpression{Left: this, Member: "destroy",
+					MemberLocation: location},
+				EndLocation: location.EndLocation,
+			})
+		}
+	}
+
+	if statements == nil {
+		return nil
+	}
+
+	return &ast.FunctionDeclaration{
+		BlockLocation: structure.Location(),
+		Public:        true,
+		Name:          "destroy",
+		StatementsBlock: &ast.StatementsBlock{
+			StartLocation: structure.Location().StartLocation,
+			Statements:    statements,
+			EndLocation:   structure.Location().EndLocation,
+		},
+	}
+}
+
+// memberDestroy reports how the structure destroys its member. Pointers,
+// arrays and strings are freed, structure values are destroyed if their
+// structure has destroy. visiting contains structures, which destroy is being
+// computed, so structures containing each other don't loop forever.
+func (c *Checker) memberDestroy(member *ast.StructureMember,
+	visiting map[*ast.StructureDeclaration]bool) int {
+	if member.NoDestroy {
+		return notOwned
+	}
+
+	switch t := member.Type.(type) {
+	case *ast.PointerType, *ast.ArrayType:
+		return freed
+	case *ast.CustomType:
+		if t.Name == "string" {
+			return freed
+		}
+
+		structure, ok := c.resolve(t.Name).(*ast.StructureDeclaration)
+		if ok && c.hasDestroy(structure, visiting) {
+			return cascaded
+		}
+	}
+
+	return notOwned
+}
+
+// hasDestroy reports whether the structure declares destroy or gets the
+// default one.
+func (c *Checker) hasDestroy(structure *ast.StructureDeclaration,
+	visiting map[*ast.StructureDeclaration]bool) bool {
+	if findMethod(structure, "destroy") != nil {
+		return true
+	}
+
+	if visiting[structure] {
+		return false
+	}
+
+	visiting[structure] = true
+	defer delete(visiting, structure)
+
+	for _, member := range structure.Members {
+		if c.memberDestroy(member, visiting) != notOwned {
+			return true
+		}
+	}
+
+	return false
+}
+
+// thisMember returns name of the member, if expression is `this.member`, or
+// an empty string otherwise.
+func thisMember(expression ast.Expression) string {
+	member, ok := expression.(*ast.MemberExpression)
+	if !ok {
+		return ""
+	}
+
+	if this, ok := member.Left.(*ast.Name); ok && this.Name == "this" {
+		return member.Member
+	}
+
+	return ""
+}
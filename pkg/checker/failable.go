@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package checker
+
+import (
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// checkFailures checks that errors of all calls to failable functions in the
+// function are handled, and that errors are propagated with `fail` or default
+// handler only from failable functions.
+//
+// Calls of functions, methods of `this` and functions from imported
+// namespaces are checked. Methods of other values are not checked, because
+// types of expressions are not known yet.
+func (c *Checker) checkFailures(function *ast.FunctionDeclaration) {
+	var inspect func(node ast.AST) bool
+
+	inspect = func(node ast.AST) bool {
+		switch node := node.(type) {
+		case *ast.FailStatement:
+			if !function.Failable {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					node.Location(), utils.NotFailableFunctionErr, function.Name).
+					WithHelp("declare the function with `fails` after its return type"))
+			}
+		case *ast.HandleExpression:
+			name, failable, known := c.failable(node.Call)
+
+			if known && !failable {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					node.HandleLocation, utils.HandleNotFailableErr, name).
+					WithLabel(node.Call.Location(), "this call can't fail"))
+			}
+
+			if node.Handler == nil && !function.Failable {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					node.HandleLocation, utils.NotFailableFunctionErr, function.Name).
+					WithNote("default handler propagates the error to the caller").
+					WithHelp("handle the error in place with `handle err { ... }`"))
+			}
+
+			// the call itself is handled, but its arguments are not
+			ast.Inspect(node.Call.Function, inspect)
+			for _, argument := range node.Call.Arguments {
+				ast.Inspect(argument, inspect)
+			}
+
+			ast.Inspect(node.Handler, inspect)
+			return false
+		case *ast.CallExpression:
+			if name, failable, _ := c.failable(node); failable {
+				c.problemHandler.AddCodeProblem(utils.NewLocalError(
+					node.Location(), utils.UnhandledFailableCallErr, name).
+					WithHelp("handle the error with `handle err { ... }` or propagate it " +
+						"to the caller with `handle`"))
+			}
+		}
+
+		return true
+	}
+
+	ast.Inspect(function.StatementsBlock, inspect)
+}
+
+// failable reports name of the called function, whether it's failable and
+// whether the function is known at all.
+func (c *Checker) failable(call *ast.CallExpression) (string, bool, bool) {
+	var declaration ast.TopLevelStatement
+
+	switch function := call.Function.(type) {
+	case *ast.Name:
+		declaration = c.resolve(function.Name)
+	case *ast.MemberExpression:
+		left, ok := function.Left.(*ast.Name)
+		if !ok {
+			return function.Member, false, false
+		}
+
+		if left.Name == "this" {
+			if c.structure == nil {
+				return function.Member, false, false
+			}
+
+			method := findMethod(c.structure, function.Member)
+			if method == nil {
+				return function.Member, false, false
+			}
+
+			return function.Member, method.Failable, true
+		}
+
+		// function from imported namespace: `io.read()`
+		declaration = c.resolve(left.Name + "." + function.Member)
+	}
+
+	switch declaration := declaration.(type) {
+	case *ast.FunctionDeclaration:
+		return declaration.Name, declaration.Failable, true
+	case *ast.ExternFunctionDeclaration:
+		return declaration.Name, false, true
+	}
+
+	return "", false, false
+}
@@ -0,0 +1,328 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package checker
+
+import (
+	"strconv"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// Flags describing value of a local variable on paths reaching a statement.
+// Flags of different paths are merged with bitwise or, so a variable, which
+// is destroyed only in one branch of `if`, is both owning and destroyed after
+// it.
+const (
+	// variable owns a value allocated with `new`, which is not destroyed yet
+	ownsHeap = 1 << iota
+
+	// value of the variable is destroyed
+	destroyed
+
+	// value of the variable is not allocated on the heap, like a literal
+	notHeap
+)
+
+// variable is a local variable declared with `var`. Function arguments and
+// values of unknown origin, like results of calls, are not owned by the
+// function, so only variables are checked.
+type variable struct {
+	name string
+
+	// location of the last `new`, which is assigned to the variable
+	allocation *utils.CodeBlockLocation
+
+	// location of the last `destroy` of the variable
+	destroy *utils.CodeBlockLocation
+}
+
+// ownership checks that values allocated with `new` and stored in local
+// variables are destroyed exactly once on every path through the function and
+// aren't used after that.
+type ownership struct {
+	checker *Checker
+
+	// variables visible in the current block, innermost scope last
+	scopes []map[string]*variable
+
+	// flags of variables on the current path
+	state map[*variable]int
+
+	// false after return, when the current path has ended
+	reachable bool
+
+	// variables, which leak is already reported, so every leak is reported
+	// once even if the function returns in many places
+	leaked map[*variable]bool
+}
+
+// checkOwnership reports leaks, double destroys, uses of destroyed values and
+// destroys of values, which are not allocated with `new`.
+func (c *Checker) checkOwnership(body *ast.StatementsBlock) {
+	o := &ownership{checker: c, state: map[*variable]int{}, reachable: true,
+		leaked: map[*variable]bool{}}
+	o.block(body, "function returns here")
+}
+
+func (o *ownership) block(block *ast.StatementsBlock, end string) {
+	o.scopes = append(o.scopes, map[string]*variable{})
+
+	for _, statement := range block.Statements {
+		// statements after return are reported as unreachable code
+		if !o.reachable {
+			break
+		}
+
+		o.statement(statement)
+	}
+
+	scope := o.scopes[len(o.scopes)-1]
+	o.scopes = o.scopes[:len(o.scopes)-1]
+
+	for _, v := range scope {
+		if o.reachable && o.state[v]&ownsHeap != 0 {
+			o.leak(v, &utils.CodeBlockLocation{StartLocation: block.EndLocation,
+				EndLocation: block.EndLocation}, end)
+		}
+
+		delete(o.state, v)
+	}
+}
+
+func (o *ownership) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.VarStatement:
+		o.use(statement.Value)
+
+		v := &variable{name: statement.Name.Name}
+		o.state[v] = o.assigned(v, statement.Value)
+		o.scopes[len(o.scopes)-1][v.name] = v
+	case *ast.AssignStatement:
+		o.use(statement.Value)
+
+		v := o.variable(statement.Target)
+		if v == nil {
+			o.use(statement.Target)
+			o.assigned(nil, statement.Value)
+			return
+		}
+
+		if o.state[v]&ownsHeap != 0 {
+			o.leak(v, statement.Location(), "value is lost by this assignment")
+		}
+
+		o.state[v] = o.assigned(v, statement.Value)
+	case *ast.DestroyStatement:
+		o.destroy(statement)
+	case *ast.IfStatement:
+		o.use(statement.Condition)
+
+		before := o.copyState()
+
+		o.block(statement.Consequence, "scope ends here")
+		consequence, consequenceReachable := o.state, o.reachable
+
+		o.state, o.reachable = before, true
+		if statement.Alternative != nil {
+			o.statement(statement.Alternative)
+		}
+
+		switch {
+		case consequenceReachable && o.reachable:
+			for v, flags := range consequence {
+				o.state[v] |= flags
+			}
+		case consequenceReachable:
+			o.state, o.reachable = consequence, true
+		}
+	case *ast.StatementsBlock:
+		o.block(statement, "scope ends here")
+	case *ast.ReturnStatement:
+		o.use(statement.ReturnValue)
+
+		// returned value is owned by the caller
+		if v := o.variable(statement.ReturnValue); v != nil {
+			o.state[v] = 0
+		}
+
+		o.leaks(statement.Location(), "function returns here")
+		o.reachable = false
+	case *ast.FailStatement:
+		o.use(statement.Error)
+		o.leaks(statement.Location(), "function fails here")
+		o.reachable = false
+	case ast.Expression:
+		o.use(statement)
+	}
+}
+
+// leaks reports variables, which own heap values, when the function returns,
+// fails or propagates error of a call.
+func (o *ownership) leaks(location *utils.CodeBlockLocation, message string) {
+	for i := len(o.scopes) - 1; i >= 0; i-- {
+		for _, v := range o.scopes[i] {
+			if o.state[v]&ownsHeap != 0 {
+				o.leak(v, location, message)
+			}
+		}
+	}
+}
+
+// assigned returns flags of the variable, which gets the value. Ownership of
+// a value stored in another variable is moved from it.
+func (o *ownership) assigned(v *variable, value ast.Expression) int {
+	switch value := value.(type) {
+	case *ast.NewExpression:
+		if v != nil {
+			v.allocation = value.Location()
+		}
+
+		return ownsHeap
+	case *ast.StringLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.ArrayLiteral:
+		return notHeap
+	case *ast.Name:
+		source := o.variable(value)
+		if source == nil {
+			return 0
+		}
+
+		flags := o.state[source]
+		o.state[source] = 0
+
+		if v != nil {
+			v.allocation = source.allocation
+		}
+
+		return flags
+	}
+
+	return 0
+}
+
+func (o *ownership) destroy(statement *ast.DestroyStatement) {
+	v := o.variable(statement.Value)
+	if v == nil {
+		o.use(statement.Value)
+
+		if literal := literalText(statement.Value); literal != "" {
+			o.checker.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+				statement.Value.Location(), utils.DestroyNotHeapWarn, literal))
+		}
+
+		return
+	}
+
+	flags := o.state[v]
+
+	switch {
+	case flags&destroyed != 0:
+		problem := utils.NewLocalWarning(statement.Location(), utils.DoubleDestroyWarn, v.name).
+			WithLabel(v.destroy, "first destroyed here")
+		if flags != destroyed {
+			problem.WithNote("it is destroyed only on some paths to this statement")
+		}
+
+		o.checker.problemHandler.AddCodeProblem(problem)
+	case flags == notHeap:
+		o.checker.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+			statement.Value.Location(), utils.DestroyNotHeapWarn, v.name))
+	}
+
+	o.state[v] = destroyed
+	v.destroy = statement.Location()
+}
+
+// use reports variables of the expression, which are destroyed, and leaks on
+// paths, where default handler propagates error of a call.
+func (o *ownership) use(expression ast.Expression) {
+	ast.Inspect(expression, func(node ast.AST) bool {
+		if handle, ok := node.(*ast.HandleExpression); ok && handle.Handler == nil {
+			o.leaks(handle.HandleLocation, "error is propagated here")
+		}
+
+		v := o.variable(node)
+		if v == nil || o.state[v]&destroyed == 0 {
+			return true
+		}
+
+		problem := utils.NewLocalWarning(node.Location(), utils.UseAfterDestroyWarn, v.name).
+			WithLabel(v.destroy, "destroyed here")
+		if o.state[v] != destroyed {
+			problem.WithNote("it is destroyed only on some paths to this statement")
+		}
+
+		o.checker.problemHandler.AddCodeProblem(problem)
+		return true
+	})
+}
+
+func (o *ownership) leak(v *variable, location *utils.CodeBlockLocation, message string) {
+	if o.leaked[v] || v.allocation == nil {
+		return
+	}
+
+	o.leaked[v] = true
+	o.checker.problemHandler.AddCodeProblem(utils.NewLocalWarning(
+		v.allocation, utils.LeakWarn, v.name).
+		WithLabel(location, message).
+		WithHelp("destroy it with `destroy " + v.name + ";`"))
+}
+
+// variable returns local variable, which the node names, or nil.
+func (o *ownership) variable(node ast.AST) *variable {
+	name, ok := node.(*ast.Name)
+	if !ok {
+		return nil
+	}
+
+	for i := len(o.scopes) - 1; i >= 0; i-- {
+		if v, ok := o.scopes[i][name.Name]; ok {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (o *ownership) copyState() map[*variable]int {
+	state := make(map[*variable]int, len(o.state))
+	for v, flags := range o.state {
+		state[v] = flags
+	}
+
+	return state
+}
+
+// literalText returns literal as it's written in the source or an empty
+// string if the expression is not a literal.
+func literalText(expression ast.Expression) string {
+	switch expression := expression.(type) {
+	case *ast.StringLiteral:
+		return strconv.Quote(expression.Value)
+	case *ast.NumberLiteral:
+		return expression.Value
+	}
+
+	return ""
+}
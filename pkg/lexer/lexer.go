@@ -113,7 +113,7 @@ func (l *Lexer) decodeRune() {
 			l.problemHandler.AddCodeProblem(
 				utils.NewLocalError(
 					utils.NewOneCodePointBlockLocation(l.currentLocation),
-					utils.IllegalNullCharacterErr, []interface{}{}))
+					utils.IllegalNullCharacterErr))
 		case r >= utf8.RuneSelf:
 			r, offset = utf8.DecodeRune(l.source[l.currentLocation.Index:])
 			if r == utf8.RuneError && offset == 1 {
@@ -184,6 +184,17 @@ func isIdentifierStart(cp rune) bool {
 		cp >= utf8.RuneSelf && unicode.IsLetter(cp)
 }
 
+// IsKeyword reports whether name is a reserved keyword, so it can be used as
+// an identifier only when wrapped in backquotes.
+func IsKeyword(name string) bool {
+	return binarySearchKeyword(name) != -1
+}
+
+// Keywords returns sorted list of reserved keywords.
+func Keywords() []string {
+	return append([]string{}, keywords...)
+}
+
 // Binary search throw keyword list and try to find a keyword.
 // If not found return -1.
 func binarySearchKeyword(keyword string) int {
@@ -256,6 +267,16 @@ func invalidSeparator(x string) int {
 	return -1
 }
 
+// removeInvalidSeparators removes underscores, which don't separate successive
+// digits, from the number literal.
+func removeInvalidSeparators(x string) string {
+	for i := invalidSeparator(x); i >= 0; i = invalidSeparator(x) {
+		x = x[:i] + x[i+1:]
+	}
+
+	return x
+}
+
 // Scan digits sequence.
 //   2938487198.34
 //   ▲         ▲
@@ -408,11 +429,15 @@ func (l *Lexer) nextNumberToken() *Token {
 
 	if digitSeparator&2 != 0 {
 		if i := invalidSeparator(buffer); i >= 0 {
+			location := &utils.CodeBlockLocation{StartLocation: startLocation,
+				EndLocation: l.currentLocation.Copy()}
+
 			l.problemHandler.AddCodeProblem(
 				utils.NewLocalError(
-					&utils.CodeBlockLocation{StartLocation: startLocation,
-						EndLocation: l.currentLocation.Copy()},
-					utils.UnderscoreMustSeparateSuccessiveDigitsErr))
+					location,
+					utils.UnderscoreMustSeparateSuccessiveDigitsErr).
+					WithFix("remove misplaced underscores",
+						utils.NewReplacement(location, removeInvalidSeparators(buffer))))
 		}
 	}
 
@@ -672,10 +697,11 @@ func (l *Lexer) nextStringToken() *Token {
 
 			l.problemHandler.AddCodeProblem(
 				utils.NewLocalError(
-					location, utils.NotClosedStringErr))
+					location, utils.NotClosedStringErr).
+					WithFix("insert closing quote", utils.NewInsertion(endLocation, "\"")))
 
 			return &Token{Kind: StringTokenKind,
-				Literal:  string(l.source[startLocation.Index+1 : l.currentLocation.Index-1]),
+				Literal:  string(l.source[startLocation.Index+1 : endLocation.Index]),
 				Location: location}
 		}
 
@@ -684,7 +710,9 @@ func (l *Lexer) nextStringToken() *Token {
 		}
 
 		if l.currentCodePoint == '\\' {
+			l.advance() // '\\'
 			l.scanEscape('"')
+			continue
 		}
 
 		l.advance()
@@ -785,6 +813,10 @@ func (l *Lexer) NextToken() *Token {
 		result = l.characterToken(CommaTokenKind, ",")
 	case ';':
 		result = l.characterToken(SemiColTokenKind, ";")
+	case ':':
+		result = l.characterToken(ColonTokenKind, ":")
+	case '@':
+		result = l.characterToken(AtTokenKind, "@")
 
 	case '>':
 		if l.peekByte() == '=' {
@@ -848,6 +880,16 @@ func (l *Lexer) NextToken() *Token {
 			return l.nextNameToken()
 		} else if isDecimal(l.currentCodePoint) || l.currentCodePoint == '.' && isDecimal(rune(l.peekByte())) {
 			return l.nextNumberToken()
+		} else if l.currentCodePoint == '.' && l.peekByte() == '.' &&
+			l.currentLocation.Index+2 < l.sourceLength && l.source[l.currentLocation.Index+2] == '.' {
+			startLocation := l.currentLocation.Copy()
+
+			l.advance()
+			l.advance()
+
+			result = &Token{Kind: EllipsisTokenKind, Literal: "...",
+				Location: &utils.CodeBlockLocation{StartLocation: startLocation,
+					EndLocation: l.currentLocation.NextByteLocation()}}
 		} else if l.currentCodePoint == '.' {
 			result = l.characterToken(DotTokenKind, ".")
 		} else {
@@ -99,6 +99,25 @@ func TestMultiLineComment(t *testing.T) {
 	assert.Equal(t, tok.Literal, "test")
 }
 
+func TestEllipsis(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	l := NewLexer(" ", []byte("a, ...) .."), p)
+
+	assert.Equal(t, l.NextToken().Kind, IdentifierTokenKind)
+	assert.Equal(t, l.NextToken().Kind, CommaTokenKind)
+
+	tok := l.NextToken()
+	assert.Equal(t, tok.Kind, EllipsisTokenKind)
+	assert.Equal(t, tok.Literal, "...")
+	assert.Equal(t, tok.Location.StartLocation.Index, 3)
+	assert.Equal(t, tok.Location.EndLocation.Index, 6)
+
+	assert.Equal(t, l.NextToken().Kind, CloseParentTokenKind)
+	assert.Equal(t, l.NextToken().Kind, DotTokenKind)
+	assert.Equal(t, l.NextToken().Kind, DotTokenKind)
+	assert.Equal(t, l.NextToken().Kind, EOFTokenKind)
+}
+
 func OneCharacterTokenTests(t *testing.T) {
 	tests := map[string]int{
 		"+": PlusOpTokenKind,
@@ -119,6 +138,8 @@ func OneCharacterTokenTests(t *testing.T) {
 		",": CommaTokenKind,
 		".": DotTokenKind,
 		";": SemiColTokenKind,
+		":": ColonTokenKind,
+		"@": AtTokenKind,
 
 		"(": OpenParentTokenKind,
 		")": CloseParentTokenKind,
@@ -189,6 +210,20 @@ func TestKeywords(t *testing.T) {
 			{ImportKeywordTokenKind, "import"},
 			{EOFTokenKind, "\\0"},
 		},
+		"new destroy readonly interface extern": {
+			{NewKeywordTokenKind, "new"},
+			{DestroyKeywordTokenKind, "destroy"},
+			{ReadonlyKeywordTokenKind, "readonly"},
+			{InterfaceKeywordTokenKind, "interface"},
+			{ExternKeywordTokenKind, "extern"},
+			{EOFTokenKind, "\\0"},
+		},
+		"fails fail handle": {
+			{FailsKeywordTokenKind, "fails"},
+			{FailKeywordTokenKind, "fail"},
+			{HandleKeywordTokenKind, "handle"},
+			{EOFTokenKind, "\\0"},
+		},
 		"i8 i16 i32 i64 u8 u16 u32 u64": {
 			{I8KeywordTokenKind, "i8"},
 			{I16KeywordTokenKind, "i16"},
@@ -233,6 +268,30 @@ func TestString(t *testing.T) {
 	p.PrintProblems()
 }
 
+func TestEscapeSequences(t *testing.T) {
+	tests := map[string]int{
+		`"\n\t\\\"\x5A\101\u00E9\U0001F600"`: -1,
+		`"\q"`:     utils.UnknownEscapeSequenceErr,
+		`"\xZZ"`:   utils.IllegalCharacterInEscapeSequenceErr,
+		`"\uD800"`: utils.EscapeSequenceIsInvalidUTF8CodePointErr,
+		`"\x4`:     utils.EscapeSequenceNotTerminatedErr,
+	}
+
+	for input, code := range tests {
+		p := utils.NewCodeProblemHandler()
+		tok := NewLexer("", []byte(input), p).NextToken()
+		assert.Equal(t, StringTokenKind, tok.Kind)
+
+		if code == -1 {
+			assert.Empty(t, p.Problems(), input)
+			continue
+		}
+
+		assert.NotEmpty(t, p.Problems(), input)
+		assert.Equal(t, code, p.Problems()[0].Code(), input)
+	}
+}
+
 func TestNumber(t *testing.T) {
 	tests := map[string][][]interface{}{
 		"1234567890 0xcafebabe 0. .0 3.14159265 1e+17 2.71828e-1000 0i 1i 123456789012345678890i": {
@@ -281,3 +340,33 @@ func TestNumber(t *testing.T) {
 		}
 	}
 }
+
+func TestFixes(t *testing.T) {
+	tests := map[string]string{
+		"1__000":   "1_000",
+		"0x_ff_":   "0x_ff",
+		"1_.5_e_3": "1.5e3",
+		"\"abc":    "\"abc\"",
+		"\"abc\r\n": "\"abc\"\r\n",
+	}
+
+	for input, output := range tests {
+		p := utils.NewCodeProblemHandler()
+		NewLexer("", []byte(input), p).NextToken()
+
+		assert.Equal(t, 1, len(p.Problems()), input)
+		fixed, applied := utils.ApplyFixes([]byte(input), p.Problems()[0].Fixes())
+		assert.Equal(t, 1, applied, input)
+		assert.Equal(t, output, string(fixed), input)
+	}
+}
+
+func TestNotClosedString(t *testing.T) {
+	for source, literal := range map[string]string{"\"abc\n": "abc", "\"abc\r\n": "abc", "\"": ""} {
+		p := utils.NewCodeProblemHandler()
+		tok := NewLexer("", []byte(source), p).NextToken()
+		assert.Equal(t, StringTokenKind, tok.Kind)
+		assert.Equal(t, literal, tok.Literal)
+		assert.False(t, p.Ok)
+	}
+}
@@ -0,0 +1,487 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lexer
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// TextEdit replaces bytes of the source in range [Start, End) with Text.
+type TextEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// lookahead is the amount of bytes after the end of a token, which the lexer
+// may look at to scan it: the code point stopping the token and the byte,
+// which decides whether `.` starts an ellipsis.
+const lookahead = utf8.UTFMax
+
+// Tokens are tokens of the source including comments and the final EOF
+// token together with problems found while scanning them. Tokens reused
+// after an edit keep their old locations and are moved only when the tokens
+// are requested, so relexing doesn't touch the part of the source after the
+// edit.
+type Tokens struct {
+	filepath string
+
+	pieces        []tokenPiece
+	problemPieces []problemPiece
+
+	// moved tokens and problems of all pieces, nil until requested
+	tokens   []*Token
+	problems []*utils.CodeProblem
+}
+
+// tokenPiece is a run of tokens, which are moved by the shifts applied one
+// after another.
+type tokenPiece struct {
+	tokens []*Token
+	shifts []shift
+}
+
+// problemPiece is a run of problems, which are moved by the shifts applied
+// one after another.
+type problemPiece struct {
+	problems []*utils.CodeProblem
+	shifts   []shift
+}
+
+// Tokenize scans the whole source.
+func Tokenize(filepath string, source []byte) *Tokens {
+	problemHandler := utils.NewCodeProblemHandler()
+	tokens, _, _, _ := newLexerAt(filepath, source, nil, problemHandler).tokens(nil,
+		tokenCursor{}, 0, 0)
+
+	return &Tokens{
+		filepath:      filepath,
+		pieces:        []tokenPiece{{tokens: tokens}},
+		problemPieces: []problemPiece{{problems: sortedProblems(problemHandler.Problems())}},
+	}
+}
+
+// newLexerAt returns lexer, which starts scanning at the location. Nil
+// location means the beginning of the source. Line offsets of the lexer
+// starting in the middle of the source contain only lines after the location.
+func newLexerAt(filepath string, source []byte, location *utils.CodePointLocation,
+	problemHandler *utils.CodeProblemHandler) *Lexer {
+	if location == nil {
+		return NewLexer(filepath, source, problemHandler)
+	}
+
+	l := &Lexer{
+		filepath:     filepath,
+		source:       source,
+		sourceLength: len(source),
+
+		currentLocation: location.Copy(),
+
+		problemHandler: problemHandler,
+
+		LineStartOffsets: &[]int{},
+		LineEndOffsets:   &[]int{},
+	}
+
+	l.decodeRune()
+	return l
+}
+
+// Relex returns tokens of the source after the edit. Scanning restarts
+// from the last token, which ends far enough before the edit, so the edit
+// can't change it. Tokens are independent of each other, so restarting at a
+// token is safe even if multi-line comments and strings surround the edit.
+// Scanning stops when a new token after the edit starts where an old token
+// started: from there old tokens are reused and only the shift of their
+// locations is recorded.
+//
+// Problems of rescanned tokens are found again, the others are reused
+// together with their tokens. The old tokens are not modified.
+func (t *Tokens) Relex(source []byte, edit TextEdit) *Tokens {
+	length := t.length()
+
+	// the first token, which ends too close to the edit, or EOF
+	restart := sort.Search(length, func(i int) bool {
+		token, shifts := t.at(i)
+		return token.Kind == EOFTokenKind ||
+			moveIndex(token.Location.EndLocation.Index, shifts)+lookahead > edit.Start
+	})
+
+	// tokens without EOF are scanned again completely
+	if restart == length {
+		restart = 0
+	}
+
+	problemHandler := utils.NewCodeProblemHandler()
+
+	var l *Lexer
+	restartIndex := 0
+
+	if restart == 0 {
+		l = newLexerAt(t.filepath, source, nil, problemHandler)
+	} else {
+		token, shifts := t.at(restart)
+		start := moveAll(token.Location.StartLocation, shifts)
+
+		restartIndex = start.Index
+		l = newLexerAt(t.filepath, source, start, problemHandler)
+	}
+
+	scanned, resync, s, reported := l.tokens(nil, t.cursor(restart), edit.Start+len(edit.Text),
+		edit.Start+len(edit.Text)-edit.End)
+
+	relexed := &Tokens{filepath: t.filepath}
+	relexed.pieces = t.slice(0, restart, nil)
+	if len(scanned) > 0 {
+		relexed.pieces = append(relexed.pieces, tokenPiece{tokens: scanned})
+	}
+
+	// problems before the restart point stay where they were
+	relexed.problemPieces = t.problemsIn(-1, restartIndex, nil)
+	relexed.problemPieces = append(relexed.problemPieces,
+		problemPiece{problems: sortedProblems(problemHandler.Problems()[:reported])})
+
+	if !resync.done() {
+		resyncIndex := resync.startIndex()
+
+		relexed.pieces = append(relexed.pieces, t.slice(resync.index(), length, &s)...)
+		relexed.problemPieces = append(relexed.problemPieces,
+			t.problemsIn(resyncIndex, math.MaxInt, &s)...)
+	}
+
+	return relexed
+}
+
+// Slice returns all tokens with their current locations.
+func (t *Tokens) Slice() []*Token {
+	if t.tokens != nil {
+		return t.tokens
+	}
+
+	t.tokens = make([]*Token, 0, t.length())
+	for _, piece := range t.pieces {
+		if len(piece.shifts) == 0 {
+			t.tokens = append(t.tokens, piece.tokens...)
+		} else {
+			t.tokens = append(t.tokens, shifted(piece.tokens, piece.shifts)...)
+		}
+	}
+
+	// following relexing starts from the moved tokens
+	t.pieces = []tokenPiece{{tokens: t.tokens}}
+	return t.tokens
+}
+
+// Problems returns problems found while scanning the tokens with their
+// current locations.
+func (t *Tokens) Problems() []*utils.CodeProblem {
+	if t.problems != nil {
+		return t.problems
+	}
+
+	t.problems = []*utils.CodeProblem{}
+	for _, piece := range t.problemPieces {
+		for _, problem := range piece.problems {
+			if len(piece.shifts) > 0 {
+				shifts := piece.shifts
+				problem = problem.Moved(func(location *utils.CodePointLocation) *utils.CodePointLocation {
+					return moveAll(location, shifts)
+				})
+			}
+
+			t.problems = append(t.problems, problem)
+		}
+	}
+
+	t.problemPieces = []problemPiece{{problems: t.problems}}
+	return t.problems
+}
+
+func (t *Tokens) length() int {
+	length := 0
+	for _, piece := range t.pieces {
+		length += len(piece.tokens)
+	}
+
+	return length
+}
+
+// at returns the token with the index among tokens of all pieces and the
+// shifts, which move it.
+func (t *Tokens) at(index int) (*Token, []shift) {
+	c := t.cursor(index)
+	piece := t.pieces[c.piece]
+	return piece.tokens[c.offset], piece.shifts
+}
+
+// cursor returns cursor at the token with the index among tokens of all
+// pieces.
+func (t *Tokens) cursor(index int) tokenCursor {
+	c := tokenCursor{pieces: t.pieces}
+	for c.piece < len(t.pieces) && index >= len(t.pieces[c.piece].tokens) {
+		index -= len(t.pieces[c.piece].tokens)
+		c.before += len(t.pieces[c.piece].tokens)
+		c.piece++
+	}
+
+	c.offset = index
+	return c
+}
+
+// slice returns pieces with tokens in range [start, end) of all pieces, which
+// are moved further by the shift if it's not nil.
+func (t *Tokens) slice(start int, end int, s *shift) []tokenPiece {
+	result := []tokenPiece{}
+
+	offset := 0
+	for _, piece := range t.pieces {
+		from, to := start-offset, end-offset
+		offset += len(piece.tokens)
+
+		if from < 0 {
+			from = 0
+		}
+
+		if to > len(piece.tokens) {
+			to = len(piece.tokens)
+		}
+
+		if from < to {
+			result = append(result, tokenPiece{tokens: piece.tokens[from:to],
+				shifts: withShift(piece.shifts, s)})
+		}
+	}
+
+	return result
+}
+
+// problemsIn returns pieces with problems, whose current start index is in
+// range [start, end), moved further by the shift if it's not nil. Index of
+// global problems is -1.
+func (t *Tokens) problemsIn(start int, end int, s *shift) []problemPiece {
+	result := []problemPiece{}
+
+	for _, piece := range t.problemPieces {
+		problems, shifts := piece.problems, piece.shifts
+		index := func(i int) int {
+			if problems[i].Global() {
+				return -1
+			}
+
+			return moveIndex(problems[i].Location().StartLocation.Index, shifts)
+		}
+
+		from := sort.Search(len(problems), func(i int) bool { return index(i) >= start })
+		to := sort.Search(len(problems), func(i int) bool { return index(i) >= end })
+
+		if from < to {
+			result = append(result, problemPiece{problems: problems[from:to],
+				shifts: withShift(shifts, s)})
+		}
+	}
+
+	return result
+}
+
+// sortedProblems returns copy of the problems sorted by their start index,
+// global problems go first.
+func sortedProblems(problems []*utils.CodeProblem) []*utils.CodeProblem {
+	result := make([]*utils.CodeProblem, len(problems))
+	copy(result, problems)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Global() || result[j].Global() {
+			return result[i].Global() && !result[j].Global()
+		}
+
+		return result[i].Location().StartLocation.Index < result[j].Location().StartLocation.Index
+	})
+
+	return result
+}
+
+// withShift returns the shifts followed by the shift if it's not nil. The
+// shifts are copied, since pieces of other tokens may share them.
+func withShift(shifts []shift, s *shift) []shift {
+	if s == nil {
+		return shifts
+	}
+
+	result := make([]shift, len(shifts), len(shifts)+1)
+	copy(result, shifts)
+	return append(result, *s)
+}
+
+// shift moves locations of code after an edit of the source.
+type shift struct {
+	// line of the first moved location before the edit, only locations on
+	// this line change their columns
+	line int
+
+	bytes   int
+	lines   int
+	columns int
+}
+
+// newShift returns shift, which moves location from to location to.
+func newShift(from *utils.CodePointLocation, to *utils.CodePointLocation) shift {
+	return shift{line: from.Line, bytes: to.Index - from.Index,
+		lines: to.Line - from.Line, columns: to.Column - from.Column}
+}
+
+// move moves the location in place.
+func (s shift) move(location *utils.CodePointLocation) {
+	if location.Line == s.line {
+		location.Column += s.columns
+	}
+
+	location.Index += s.bytes
+	location.Line += s.lines
+}
+
+// moveAll returns copy of the location moved by the shifts one after
+// another.
+func moveAll(location *utils.CodePointLocation, shifts []shift) *utils.CodePointLocation {
+	moved := location.Copy()
+	for _, s := range shifts {
+		s.move(moved)
+	}
+
+	return moved
+}
+
+// moveIndex returns byte index moved by the shifts.
+func moveIndex(index int, shifts []shift) int {
+	for _, s := range shifts {
+		index += s.bytes
+	}
+
+	return index
+}
+
+// tokenCursor points at a token of pieces.
+type tokenCursor struct {
+	pieces []tokenPiece
+	piece  int
+	offset int
+
+	// amount of tokens in pieces before the current one
+	before int
+}
+
+// index returns index of the token among tokens of all pieces.
+func (c tokenCursor) index() int {
+	return c.before + c.offset
+}
+
+func (c tokenCursor) done() bool {
+	return c.piece >= len(c.pieces)
+}
+
+// token returns the token together with its current start location.
+func (c tokenCursor) token() (*Token, *utils.CodePointLocation) {
+	piece := c.pieces[c.piece]
+	token := piece.tokens[c.offset]
+	return token, moveAll(token.Location.StartLocation, piece.shifts)
+}
+
+// startIndex returns current start index of the token.
+func (c tokenCursor) startIndex() int {
+	piece := c.pieces[c.piece]
+	return moveIndex(piece.tokens[c.offset].Location.StartLocation.Index, piece.shifts)
+}
+
+func (c tokenCursor) next() tokenCursor {
+	c.offset++
+	for !c.done() && c.offset == len(c.pieces[c.piece].tokens) {
+		c.before += c.offset
+		c.offset = 0
+		c.piece++
+	}
+
+	return c
+}
+
+// tokens appends scanned tokens to result until EOF. Once a token starts at
+// or after the offset after, where an old token shifted by delta bytes
+// started, scanning stops. The cursor at that old token and the shift of old
+// tokens from it are returned too, the cursor is done if EOF was scanned.
+// Problems of the last scanned token are reused with the old token, so the
+// amount of problems reported before it is returned as well.
+func (l *Lexer) tokens(result []*Token, old tokenCursor, after int,
+	delta int) ([]*Token, tokenCursor, shift, int) {
+	for {
+		reported := len(l.problemHandler.Problems())
+		token := l.NextToken()
+		start := token.Location.StartLocation
+
+		if start.Index >= after {
+			for !old.done() && old.startIndex()+delta < start.Index {
+				old = old.next()
+			}
+
+			if !old.done() && old.startIndex()+delta == start.Index {
+				_, from := old.token()
+				return result, old, newShift(from, start), reported
+			}
+		}
+
+		result = append(result, token)
+		if token.Kind == EOFTokenKind {
+			return result, tokenCursor{}, shift{}, len(l.problemHandler.Problems())
+		}
+	}
+}
+
+// shifted returns copies of the tokens moved by the shifts.
+func shifted(tokens []*Token, shifts []shift) []*Token {
+	// tokens with their locations are allocated at once, since there may be
+	// many of them after an edit at the beginning of a large file
+	buffer := make([]struct {
+		token      Token
+		location   utils.CodeBlockLocation
+		start, end utils.CodePointLocation
+	}, len(tokens))
+
+	result := make([]*Token, len(tokens))
+	for i, token := range tokens {
+		b := &buffer[i]
+
+		b.start, b.end = *token.Location.StartLocation, *token.Location.EndLocation
+		for _, s := range shifts {
+			s.move(&b.start)
+			s.move(&b.end)
+		}
+
+		b.location = utils.CodeBlockLocation{StartLocation: &b.start, EndLocation: &b.end}
+		b.token = Token{Kind: token.Kind, Literal: token.Literal, Location: &b.location}
+
+		result[i] = &b.token
+	}
+
+	return result
+}
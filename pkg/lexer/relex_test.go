@@ -0,0 +1,203 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lexer
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+const relexSource = `namespace "app";
+
+/* multi-line
+   comment */
+pub fun main(a: i32, b: *u8) -> i32 {
+	return a + 0x1_F * 1.5e3; // comment
+}
+
+struct 😀 { s: "строка \"x\""; n: 1__0; }
+`
+
+func dumpTokens(tokens []*Token) []string {
+	dumps := []string{}
+	for _, token := range tokens {
+		dumps = append(dumps, token.Dump())
+	}
+
+	return dumps
+}
+
+func dumpProblems(problems []*utils.CodeProblem) []string {
+	dumps := []string{}
+	for _, problem := range problems {
+		dump := problem.ID() + " " + problem.Message() + " " + problem.Location().Dump()
+		for _, fix := range problem.Fixes() {
+			for _, edit := range fix.Edits {
+				dump += " " + edit.Location.Dump() + " " + edit.Text
+			}
+		}
+
+		dumps = append(dumps, dump)
+	}
+
+	sort.Strings(dumps)
+	return dumps
+}
+
+// assertRelex checks that relexing after the edit gives the same tokens and
+// problems as scanning the edited source from scratch.
+func assertRelex(t *testing.T, source string, edit TextEdit) {
+	edited := source[:edit.Start] + edit.Text + source[edit.End:]
+
+	relexed := Tokenize("a.tiny", []byte(source)).Relex([]byte(edited), edit)
+	expected := Tokenize("a.tiny", []byte(edited))
+
+	assert.Equal(t, dumpTokens(expected.Slice()), dumpTokens(relexed.Slice()), "%q", edited)
+	assert.Equal(t, dumpProblems(expected.Problems()), dumpProblems(relexed.Problems()),
+		"%q", edited)
+}
+
+func TestRelex(t *testing.T) {
+	at := func(s string) int { return strings.Index(relexSource, s) }
+
+	for _, edit := range []TextEdit{
+		// typing in an identifier
+		{Start: at("main") + 2, End: at("main") + 2, Text: "x"},
+		// new line shifts following lines
+		{Start: at("pub"), End: at("pub"), Text: "\n\n"},
+		// opening a comment hides the rest of the file
+		{Start: at("pub"), End: at("pub"), Text: "/*"},
+		// closing the comment early
+		{Start: at("multi-line") + 5, End: at("multi-line") + 5, Text: "*/"},
+		// removing end of the comment
+		{Start: at("comment */") + 8, End: at("comment */") + 10, Text: ""},
+		// opening a string
+		{Start: at("return") - 1, End: at("return"), Text: "\""},
+		// joining and splitting tokens
+		{Start: at("1.5e3") - 1, End: at("1.5e3"), Text: ""},
+		{Start: at("+ 0x") + 1, End: at("+ 0x") + 1, Text: "="},
+		{Start: at("b: *u8") + 4, End: at("b: *u8") + 4, Text: " "},
+		// wide characters before the edit
+		{Start: at("s: ") + 1, End: at("s: ") + 1, Text: "ё"},
+		// the whole source
+		{Start: 0, End: len(relexSource), Text: "fun f() {}"},
+		{Start: len(relexSource), End: len(relexSource), Text: "/"},
+	} {
+		assertRelex(t, relexSource, edit)
+	}
+}
+
+func TestRelexRandomEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pieces := []string{"", " ", "\n", "/*", "*/", "//", "\"", "\\", "a", "1", ".", "..", "_", "`", "ж", "😀"}
+
+	for i := 0; i < 2000; i++ {
+		start := r.Intn(len(relexSource) + 1)
+		end := start + r.Intn(4)
+		if end > len(relexSource) {
+			end = len(relexSource)
+		}
+
+		edit := TextEdit{Start: start, End: end, Text: pieces[r.Intn(len(pieces))]}
+		if !utf8Boundary(relexSource, start) || !utf8Boundary(relexSource, end) {
+			continue
+		}
+
+		assertRelex(t, relexSource, edit)
+	}
+}
+
+func TestRelexSequentialEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	pieces := []string{"", " ", "\n", "/*", "*/", "//", "\"", "a", "1", ".", "ж", "😀"}
+
+	for i := 0; i < 200; i++ {
+		source := relexSource
+		tokens := Tokenize("a.tiny", []byte(source))
+
+		// shifts of several edits are recorded before the tokens are moved
+		for j := 0; j < 5; j++ {
+			start := r.Intn(len(source) + 1)
+			end := start + r.Intn(4)
+			if end > len(source) {
+				end = len(source)
+			}
+
+			if !utf8Boundary(source, start) || !utf8Boundary(source, end) {
+				continue
+			}
+
+			edit := TextEdit{Start: start, End: end, Text: pieces[r.Intn(len(pieces))]}
+			source = source[:start] + edit.Text + source[end:]
+			tokens = tokens.Relex([]byte(source), edit)
+		}
+
+		expected := Tokenize("a.tiny", []byte(source))
+		assert.Equal(t, dumpTokens(expected.Slice()), dumpTokens(tokens.Slice()), "%q", source)
+		assert.Equal(t, dumpProblems(expected.Problems()), dumpProblems(tokens.Problems()),
+			"%q", source)
+	}
+}
+
+func utf8Boundary(s string, offset int) bool {
+	return offset == len(s) || offset < len(s) && (s[offset]&0xC0) != 0x80
+}
+
+// largeSource returns source of about 2 MB.
+func largeSource() []byte {
+	return []byte(strings.Repeat(relexSource[len(`namespace "app";`):], 10000))
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	source := largeSource()
+
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		Tokenize("a.tiny", source)
+	}
+}
+
+func BenchmarkRelex(b *testing.B) {
+	source := largeSource()
+	tokens := Tokenize("a.tiny", source)
+
+	// typing a character in the middle of the file
+	offset := len(source) / 2
+	for source[offset] != ' ' {
+		offset++
+	}
+
+	edit := TextEdit{Start: offset, End: offset, Text: "x"}
+	edited := append(append(append([]byte{}, source[:offset]...), 'x'), source[offset:]...)
+
+	b.SetBytes(int64(len(source)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokens.Relex(edited, edit)
+	}
+}
@@ -43,9 +43,12 @@ const (
 	OpenBraceTokenKind    // "{"
 	CloseBraceTokenKind   // "}"
 
-	CommaTokenKind   // ""
-	DotTokenKind     // "."
-	SemiColTokenKind // ";"
+	CommaTokenKind    // ""
+	DotTokenKind      // "."
+	EllipsisTokenKind // "..."
+	SemiColTokenKind  // ";"
+	ColonTokenKind    // ":"
+	AtTokenKind       // "@"
 
 	PlusPlusOpTokenKind   // "++"
 	MinusMinusOpTokenKind // "--"
@@ -65,17 +68,25 @@ const (
 	ConstKeywordTokenKind
 	ContinueKeywordTokenKind
 	DefaultKeywordTokenKind
+	DestroyKeywordTokenKind
 	ElseKeywordTokenKind
+	ExternKeywordTokenKind
+	FailKeywordTokenKind
+	FailsKeywordTokenKind
 	ForKeywordTokenKind
 	FunKeywordTokenKind
+	HandleKeywordTokenKind
 	I16KeywordTokenKind
 	I32KeywordTokenKind
 	I64KeywordTokenKind
 	I8KeywordTokenKind
 	IfKeywordTokenKind
 	ImportKeywordTokenKind
+	InterfaceKeywordTokenKind
 	NamespaceKeywordTokenKind
+	NewKeywordTokenKind
 	PubKeywordTokenKind
+	ReadonlyKeywordTokenKind
 	ReturnKeywordTokenKind
 	StructKeywordTokenKind
 	SwitchKeywordTokenKind
@@ -124,7 +135,10 @@ var dumpedKinds = map[int]string{
 	CloseBraceTokenKind:       "close brace",
 	CommaTokenKind:            "comma",
 	DotTokenKind:              "dot",
+	EllipsisTokenKind:         "ellipsis",
 	SemiColTokenKind:          "semicolon",
+	ColonTokenKind:            "colon",
+	AtTokenKind:               "at",
 	PlusPlusOpTokenKind:       "plus plus",
 	MinusMinusOpTokenKind:     "minus minus",
 	IdentifierTokenKind:       "identifier",
@@ -139,17 +153,25 @@ var dumpedKinds = map[int]string{
 	ConstKeywordTokenKind:     "const keyword",
 	ContinueKeywordTokenKind:  "continue keyword",
 	DefaultKeywordTokenKind:   "default keyword",
+	DestroyKeywordTokenKind:   "destroy keyword",
 	ElseKeywordTokenKind:      "else keyword",
+	ExternKeywordTokenKind:    "extern keyword",
+	FailKeywordTokenKind:      "fail keyword",
+	FailsKeywordTokenKind:     "fails keyword",
 	ForKeywordTokenKind:       "for keyword",
 	FunKeywordTokenKind:       "fun keyword",
+	HandleKeywordTokenKind:    "handle keyword",
 	I16KeywordTokenKind:       "i16 keyword",
 	I32KeywordTokenKind:       "i32 keyword",
 	I64KeywordTokenKind:       "i64 keyword",
 	I8KeywordTokenKind:        "i8 keyword",
 	IfKeywordTokenKind:        "if keyword",
 	ImportKeywordTokenKind:    "import keyword",
+	InterfaceKeywordTokenKind: "interface keyword",
 	NamespaceKeywordTokenKind: "namespace keyword",
+	NewKeywordTokenKind:       "new keyword",
 	PubKeywordTokenKind:       "pub keyword",
+	ReadonlyKeywordTokenKind:  "readonly keyword",
 	ReturnKeywordTokenKind:    "return keyword",
 	StructKeywordTokenKind:    "struct keyword",
 	SwitchKeywordTokenKind:    "switch keyword",
@@ -167,7 +189,7 @@ func DumpTokenKind(k int) string {
 }
 
 var keywords = []string{
-	"break", "case", "const", "continue", "default", "else", "for", "fun", "i16", "i32", "i64", "i8", "if", "import", "namespace", "pub", "return", "struct", "switch", "u16", "u32", "u64", "u8", "var",
+	"break", "case", "const", "continue", "default", "destroy", "else", "extern", "fail", "fails", "for", "fun", "handle", "i16", "i32", "i64", "i8", "if", "import", "interface", "namespace", "new", "pub", "readonly", "return", "struct", "switch", "u16", "u32", "u64", "u8", "var",
 }
 
 var keywordsAmount = len(keywords)
@@ -25,7 +25,8 @@ import subprocess
 keywords_list = ["break", "return", "case", "const", "continue", "default", "else",
                  "for", "fun", "if", "import", "i16", "i32",
                  "i64", "i8", "namespace", "struct", "switch", "u16",
-                 "u32", "u64", "u8", "var", "pub"]
+                 "u32", "u64", "u8", "var", "pub", "new", "destroy", "readonly", "interface", "extern",
+                 "fail", "fails", "handle"]
 keywords_list.sort()
 
 dumped_keywords_list = keywords_list.__str__(
@@ -76,9 +77,12 @@ const (
 	OpenBraceTokenKind    // "{"
 	CloseBraceTokenKind   // "}"
 
-	CommaTokenKind   // ""
-	DotTokenKind     // "."
-	SemiColTokenKind // ";"
+	CommaTokenKind    // ""
+	DotTokenKind      // "."
+	EllipsisTokenKind // "..."
+	SemiColTokenKind  // ";"
+	ColonTokenKind    // ":"
+	AtTokenKind       // "@"
 
 	PlusPlusOpTokenKind   // "++"
 	MinusMinusOpTokenKind // "--"
@@ -132,7 +136,10 @@ var dumpedKinds = map[int]string{
 	CloseBraceTokenKind:       "close brace",
 	CommaTokenKind:            "comma",
 	DotTokenKind:              "dot",
+	EllipsisTokenKind:         "ellipsis",
 	SemiColTokenKind:          "semicolon",
+	ColonTokenKind:            "colon",
+	AtTokenKind:               "at",
 	PlusPlusOpTokenKind:       "plus plus",
 	MinusMinusOpTokenKind:     "minus minus",
 	IdentifierTokenKind:       "identifier",
@@ -0,0 +1,154 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package loader
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/mangle"
+)
+
+// Export is a function or a method of the package, which other libraries can
+// call.
+type Export struct {
+	// Linker symbol of the function, see package mangle.
+	Symbol string
+
+	// Declaration in Tiny notation, for example
+	// `fun bank.Account.check(password: string): bool`.
+	Signature string
+}
+
+// Exports returns `pub` functions and `pub` methods of `pub` structures of the
+// package sorted by their symbols. Default destroy is listed if the package
+// is checked. Generic functions and methods of generic structures get
+// symbols only when they are instantiated, so they are not listed. Extern
+// functions keep their C names and are not listed too.
+func (p *Package) Exports() []*Export {
+	exports := []*Export{}
+
+	for _, file := range p.Files {
+		if file.Unit == nil {
+			continue
+		}
+
+		for _, statement := range file.Unit.TLStatements {
+			switch statement := statement.(type) {
+			case *ast.FunctionDeclaration:
+				if statement.Public && len(statement.TypeParameters) == 0 {
+					exports = append(exports, p.export(nil, statement))
+				}
+			case *ast.StructureDeclaration:
+				if !statement.Public || len(statement.TypeParameters) != 0 {
+					continue
+				}
+
+				for _, method := range statement.Methods {
+					if method.Public && len(method.TypeParameters) == 0 {
+						exports = append(exports, p.export(statement, method))
+					}
+				}
+
+				if statement.DefaultDestroy != nil {
+					exports = append(exports, p.export(statement, statement.DefaultDestroy))
+				}
+			}
+		}
+	}
+
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].Symbol < exports[j].Symbol
+	})
+
+	return exports
+}
+
+// export returns export of the function or the method of the structure, if
+// it's not nil.
+func (p *Package) export(structure *ast.StructureDeclaration,
+	function *ast.FunctionDeclaration) *Export {
+	path := []*mangle.Component{{Name: p.Namespace}}
+	if structure != nil {
+		path = append(path, &mangle.Component{Name: structure.Name})
+	}
+
+	symbol := &mangle.Symbol{Path: append(path, &mangle.Component{Name: function.Name})}
+
+	var sb strings.Builder
+
+	sb.WriteString("fun " + symbol.String() + "(")
+	for i, argument := range function.Arguments {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(argument.Name + ": " + p.mangleType(argument.Type).String())
+	}
+	sb.WriteString(")")
+
+	if function.ReturnType != nil {
+		sb.WriteString(": " + p.mangleType(function.ReturnType).String())
+	}
+
+	if function.Failable {
+		sb.WriteString(" fails")
+	}
+
+	return &Export{Symbol: mangle.Mangle(symbol), Signature: sb.String()}
+}
+
+// mangleType converts type used in the package to its mangle form. Names of
+// structures and interfaces of the package are qualified with its namespace,
+// names of imported ones already are.
+func (p *Package) mangleType(t ast.Type) mangle.Type {
+	switch t := t.(type) {
+	case *ast.PrimaryType:
+		return mangle.PrimaryType(t.Token.Literal)
+	case *ast.PointerType:
+		return &mangle.PointerType{Type: p.mangleType(t.Type)}
+	case *ast.ArrayType:
+		return &mangle.ArrayType{Type: p.mangleType(t.Type)}
+	case *ast.CustomType:
+		path := []*mangle.Component{}
+
+		names := strings.Split(t.Name, ".")
+		if _, ok := p.Declarations[t.Name]; ok && len(names) == 1 {
+			path = append(path, &mangle.Component{Name: p.Namespace})
+		}
+
+		for _, name := range names {
+			path = append(path, &mangle.Component{Name: name})
+		}
+
+		component := path[len(path)-1]
+		for _, argument := range t.Arguments {
+			component.Arguments = append(component.Arguments, p.mangleType(argument))
+		}
+
+		return &mangle.CustomType{Path: path}
+	}
+
+	return nil
+}
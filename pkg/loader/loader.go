@@ -0,0 +1,550 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package loader finds Tiny packages, parses their source files and resolves
+// imports between them.
+//
+// Package is a directory with `.tiny` files, which all declare the same
+// namespace. Import paths are mapped to directories: paths starting with
+// `./` or `../` are relative to the directory of the importing file, other
+// paths are looked up in the project root and then in the search paths.
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/checker"
+	"github.com/tinylang-org/tiny/pkg/lexer"
+	"github.com/tinylang-org/tiny/pkg/parser"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// SourceFileExtension is the extension of Tiny source files.
+const SourceFileExtension = ".tiny"
+
+type Config struct {
+	// Root directory of the project.
+	Root string
+
+	// Directories to look for imported packages in, if they are not found in
+	// the project root.
+	SearchPaths []string
+
+	// Directories of packages provided by package manager by their import
+	// paths. Subpackages are found inside of them: if "util" is mapped to
+	// "/deps/util", then "util/strings" is "/deps/util/strings". These
+	// take precedence over the project root and search paths.
+	Packages map[string]string
+}
+
+type File struct {
+	Path   string
+	Source []byte
+	Unit   *ast.ProgramUnit
+
+	// Problems found in the file.
+	ProblemHandler *utils.CodeProblemHandler
+}
+
+type Package struct {
+	// Absolute path of the package directory.
+	Directory string
+	Namespace string
+	Files     []*File
+
+	// Imported packages by their namespaces.
+	Imports map[string]*Package
+
+	// Top level declarations of all files in the package by their names.
+	Declarations map[string]ast.TopLevelStatement
+
+	loading bool
+}
+
+// Lookup returns top level declaration with the given name. The name is either
+// declared in the package or qualified with namespace of imported package
+// (`bank.Account`). Returns nil if there's no such declaration.
+func (p *Package) Lookup(name string) ast.TopLevelStatement {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		imported, ok := p.Imports[name[:i]]
+		if !ok {
+			return nil
+		}
+
+		return imported.Declarations[name[i+1:]]
+	}
+
+	return p.Declarations[name]
+}
+
+type Loader struct {
+	config Config
+
+	// Problems which are not attached to any file.
+	problemHandler *utils.CodeProblemHandler
+
+	// Packages by their directories, nil if package failed to load.
+	packages map[string]*Package
+
+	// Packages, which are being loaded, used to report import cycles.
+	stack []*Package
+
+	// Loaded packages, every package goes after packages it imports.
+	Packages []*Package
+
+	// Sources of all parsed files, problem handlers of the files use it to
+	// show code of problems, which refer to other files.
+	FileSet *utils.FileSet
+}
+
+func NewLoader(config Config, problemHandler *utils.CodeProblemHandler) *Loader {
+	return &Loader{
+		config:         config,
+		problemHandler: problemHandler,
+		packages:       map[string]*Package{},
+		Packages:       []*Package{},
+		FileSet:        utils.NewFileSet(),
+	}
+}
+
+// Load loads packages matched by patterns together with all packages they
+// import. Pattern is either a directory or a directory followed by `/...`,
+// which matches the directory and all its subdirectories containing source
+// files. Every package is returned once, even if several patterns match it.
+func (l *Loader) Load(patterns ...string) []*Package {
+	packages := []*Package{}
+	loaded := map[*Package]bool{}
+
+	for _, pattern := range patterns {
+		for _, directory := range l.expandPattern(pattern) {
+			if pkg := l.loadPackage(directory); pkg != nil && !loaded[pkg] {
+				loaded[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	return packages
+}
+
+// Ok reports whether all packages were loaded and parsed without errors.
+func (l *Loader) Ok() bool {
+	if !l.problemHandler.Ok {
+		return false
+	}
+
+	for _, pkg := range l.Packages {
+		for _, file := range pkg.Files {
+			if !file.ProblemHandler.Ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (l *Loader) expandPattern(pattern string) []string {
+	if pattern != "..." && !strings.HasSuffix(pattern, "/...") {
+		return []string{pattern}
+	}
+
+	root := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+	if root == "" {
+		root = "."
+	}
+
+	directories := []string{}
+	seen := map[string]bool{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(name, "_") || name == "vendor") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		// files of a directory may be walked before and after its
+		// subdirectories
+		if filepath.Ext(path) == SourceFileExtension {
+			directory := filepath.Dir(path)
+			if !seen[directory] {
+				seen[directory] = true
+				directories = append(directories, directory)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		l.problemHandler.AddCodeProblem(utils.NewGlobalError(
+			utils.UnableToReadDirectoryErr, root))
+	}
+
+	return directories
+}
+
+func (l *Loader) loadPackage(directory string) *Package {
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		l.problemHandler.AddCodeProblem(utils.NewGlobalError(
+			utils.UnableToReadDirectoryErr, directory))
+		return nil
+	}
+
+	if pkg, ok := l.packages[directory]; ok {
+		return pkg
+	}
+
+	pkg := &Package{
+		Directory:    directory,
+		Files:        []*File{},
+		Imports:      map[string]*Package{},
+		Declarations: map[string]ast.TopLevelStatement{},
+		loading:      true,
+	}
+	l.packages[directory] = pkg
+
+	if !l.parseFiles(pkg) {
+		l.packages[directory] = nil
+		return nil
+	}
+
+	l.stack = append(l.stack, pkg)
+	l.resolveImports(pkg)
+	l.stack = l.stack[:len(l.stack)-1]
+
+	l.collectDeclarations(pkg)
+
+	pkg.loading = false
+	l.Packages = append(l.Packages, pkg)
+	return pkg
+}
+
+func (l *Loader) parseFiles(pkg *Package) bool {
+	entries, err := os.ReadDir(pkg.Directory)
+	if err != nil {
+		l.problemHandler.AddCodeProblem(utils.NewGlobalError(
+			utils.UnableToReadDirectoryErr, pkg.Directory))
+		return false
+	}
+
+	filenames := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == SourceFileExtension {
+			filenames = append(filenames, entry.Name())
+		}
+	}
+
+	sort.Strings(filenames)
+
+	if len(filenames) == 0 {
+		l.problemHandler.AddCodeProblem(utils.NewGlobalError(
+			utils.NoSourceFilesErr, pkg.Directory))
+		return false
+	}
+
+	// declaration of the package namespace in the first file
+	var namespace *ast.NamespaceDecl
+
+	for _, filename := range filenames {
+		path := l.displayPath(filepath.Join(pkg.Directory, filename))
+
+		source, err := os.ReadFile(filepath.Join(pkg.Directory, filename))
+		if err != nil {
+			l.problemHandler.AddCodeProblem(utils.NewGlobalError(
+				utils.UnableToReadFileErr, path))
+			continue
+		}
+
+		l.FileSet.AddFile(path, source)
+
+		problemHandler := utils.NewCodeProblemHandler()
+		problemHandler.SetWarningSettings(l.problemHandler.WarningSettings())
+		problemHandler.SetFileSet(l.FileSet)
+		problemHandler.SetSource(source)
+
+		p := parser.NewParser(path, source, problemHandler)
+		unit := p.ParseProgramUnit()
+
+		problemHandler.SetLineStartOffsets(p.LineStartOffsets)
+		problemHandler.SetLineEndOffsets(p.LineEndOffsets)
+
+		pkg.Files = append(pkg.Files, &File{
+			Path:           path,
+			Source:         source,
+			Unit:           unit,
+			ProblemHandler: problemHandler,
+		})
+
+		if unit == nil {
+			continue
+		}
+
+		if namespace == nil {
+			namespace = unit.Namespace
+			pkg.Namespace = unit.Namespace.Name
+		} else if pkg.Namespace != unit.Namespace.Name {
+			problemHandler.AddCodeProblem(utils.NewLocalError(
+				unit.Namespace.Location(), utils.MultipleNamespacesErr,
+				pkg.Namespace, unit.Namespace.Name, l.displayPath(pkg.Directory)).
+				WithLabel(namespace.Location(), "namespace "+pkg.Namespace+" is declared here"))
+		}
+	}
+
+	return true
+}
+
+func (l *Loader) resolveImports(pkg *Package) {
+	for _, file := range pkg.Files {
+		if file.Unit == nil {
+			continue
+		}
+
+		for _, importDecl := range file.Unit.Imports {
+			directory := l.findPackageDirectory(pkg, importDecl.Path)
+			if directory == "" {
+				file.ProblemHandler.AddCodeProblem(utils.NewLocalError(
+					importDecl.Location(), utils.PackageNotFoundErr, importDecl.Path))
+				continue
+			}
+
+			if imported, ok := l.packages[directory]; ok && imported != nil && imported.loading {
+				file.ProblemHandler.AddCodeProblem(utils.NewLocalError(
+					importDecl.Location(), utils.ImportCycleErr, l.describeCycle(imported)))
+				continue
+			}
+
+			imported := l.loadPackage(directory)
+			if imported == nil || imported.Namespace == "" {
+				continue
+			}
+
+			if previous, ok := pkg.Imports[imported.Namespace]; ok && previous != imported {
+				file.ProblemHandler.AddCodeProblem(utils.NewLocalError(
+					importDecl.Location(), utils.NamespaceImportedTwiceErr,
+					imported.Namespace, l.displayPath(previous.Directory)))
+				continue
+			}
+
+			pkg.Imports[imported.Namespace] = imported
+
+			if !usesNamespace(file, imported.Namespace) {
+				file.ProblemHandler.AddCodeProblem(utils.NewLocalWarning(
+					importDecl.Location(), utils.UnusedImportWarn, imported.Namespace))
+			}
+		}
+	}
+}
+
+// usesNamespace reports whether the file refers to a declaration of the
+// namespace: `namespace.name`. Tokens are used instead of the syntax tree,
+// since names in function bodies are not parsed yet.
+func usesNamespace(file *File, namespace string) bool {
+	l := lexer.NewLexer(file.Path, file.Source, utils.NewCodeProblemHandler())
+
+	previous := l.NextToken()
+	for previous.Kind != lexer.EOFTokenKind {
+		token := l.NextToken()
+
+		if previous.Kind == lexer.IdentifierTokenKind && previous.Literal == namespace &&
+			token.Kind == lexer.DotTokenKind {
+			return true
+		}
+
+		previous = token
+	}
+
+	return false
+}
+
+// findPackageDirectory returns absolute path of the directory with package
+// imported by importPath, or empty string if there's no such directory.
+func (l *Loader) findPackageDirectory(pkg *Package, importPath string) string {
+	var candidates []string
+
+	if strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") {
+		candidates = []string{filepath.Join(pkg.Directory, filepath.FromSlash(importPath))}
+	} else {
+		if directory, ok := l.findProvidedPackage(importPath); ok {
+			candidates = append(candidates, directory)
+		}
+
+		for _, directory := range append([]string{l.config.Root}, l.config.SearchPaths...) {
+			candidates = append(candidates,
+				filepath.Join(directory, filepath.FromSlash(importPath)))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			if candidate, err = filepath.Abs(candidate); err == nil {
+				return candidate
+			}
+		}
+	}
+
+	return ""
+}
+
+// findProvidedPackage returns directory of the package from Config.Packages
+// with the longest import path, which is a prefix of importPath.
+func (l *Loader) findProvidedPackage(importPath string) (string, bool) {
+	directory, longest := "", -1
+
+	for path, packageDirectory := range l.config.Packages {
+		if len(path) <= longest {
+			continue
+		}
+
+		if importPath == path {
+			directory, longest = packageDirectory, len(path)
+		} else if strings.HasPrefix(importPath, path+"/") {
+			directory = filepath.Join(packageDirectory,
+				filepath.FromSlash(importPath[len(path)+1:]))
+			longest = len(path)
+		}
+	}
+
+	return directory, longest >= 0
+}
+
+// CollectProblems adds problems of all loaded files to the handler and lets
+// it show code of the files, so problems of all packages are sorted,
+// deduplicated and limited together when the handler prints them.
+func (l *Loader) CollectProblems(h *utils.CodeProblemHandler) {
+	h.SetFileSet(l.FileSet)
+
+	for _, pkg := range l.Packages {
+		for _, file := range pkg.Files {
+			for _, problem := range file.ProblemHandler.Problems() {
+				h.AddCodeProblem(problem)
+			}
+		}
+	}
+
+	if !l.Ok() {
+		h.Ok = false
+	}
+}
+
+// Check runs semantic checks over all loaded files. Problems are reported to
+// problem handlers of the files.
+func (l *Loader) Check() {
+	for _, pkg := range l.Packages {
+		for _, file := range pkg.Files {
+			if file.Unit == nil {
+				continue
+			}
+
+			c := checker.NewChecker(file.ProblemHandler)
+			c.SetLookup(pkg.Lookup)
+			c.CheckProgramUnit(file.Unit)
+		}
+	}
+}
+
+// describeCycle returns import chain from pkg to the package, which is being
+// loaded right now and imports pkg again: `app -> bank -> app`.
+func (l *Loader) describeCycle(pkg *Package) string {
+	chain := []string{}
+
+	for i := len(l.stack) - 1; i >= 0; i-- {
+		chain = append([]string{l.displayPath(l.stack[i].Directory)}, chain...)
+
+		if l.stack[i] == pkg {
+			break
+		}
+	}
+
+	return strings.Join(append(chain, l.displayPath(pkg.Directory)), " -> ")
+}
+
+func (l *Loader) collectDeclarations(pkg *Package) {
+	for _, file := range pkg.Files {
+		if file.Unit == nil {
+			continue
+		}
+
+		for _, statement := range file.Unit.TLStatements {
+			name := DeclarationName(statement)
+			if name == "" {
+				continue
+			}
+
+			if previous, ok := pkg.Declarations[name]; ok {
+				file.ProblemHandler.AddCodeProblem(utils.NewLocalError(
+					statement.Location(), utils.RedeclaredErr, name, pkg.Namespace).
+					WithLabel(previous.Location(), "previous declaration of "+name+" is here"))
+				continue
+			}
+
+			pkg.Declarations[name] = statement
+		}
+	}
+}
+
+// displayPath returns path relative to the project root if it is inside of it.
+func (l *Loader) displayPath(path string) string {
+	root, err := filepath.Abs(l.config.Root)
+	if err != nil {
+		return path
+	}
+
+	relative, err := filepath.Rel(root, path)
+	if err != nil || relative == ".." ||
+		strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return filepath.ToSlash(relative)
+}
+
+// DeclarationName returns name of function, structure or interface declared
+// by the top level statement.
+func DeclarationName(statement ast.TopLevelStatement) string {
+	switch statement := statement.(type) {
+	case *ast.FunctionDeclaration:
+		return statement.Name
+	case *ast.StructureDeclaration:
+		return statement.Name
+	case *ast.InterfaceDeclaration:
+		return statement.Name
+	case *ast.ExternFunctionDeclaration:
+		return statement.Name
+	}
+
+	return ""
+}
@@ -0,0 +1,272 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// writeTree creates files with the given contents in a temporary directory
+// and returns path of the directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+
+	for path, content := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	return root
+}
+
+func load(root string, patterns ...string) (*Loader, []*Package) {
+	l := NewLoader(Config{Root: root}, utils.NewCodeProblemHandler())
+
+	for i, pattern := range patterns {
+		patterns[i] = filepath.Join(root, pattern)
+	}
+
+	return l, l.Load(patterns...)
+}
+
+func TestLoad(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/main.tiny":         "namespace \"app\";\nimport \"lib/bank\";\npub fun main() {}",
+		"lib/bank/account.tiny": "namespace \"bank\";\npub struct Account {}",
+		"lib/bank/util.tiny":    "namespace \"bank\";\nimport \"../util\";\nfun check() {}",
+		"lib/util/util.tiny":    "namespace \"util\";\npub fun max() {}",
+	})
+
+	l, packages := load(root, "app")
+	assert.True(t, l.Ok())
+	assert.Equal(t, 1, len(packages))
+	assert.Equal(t, 3, len(l.Packages))
+
+	// dependencies go first
+	assert.Equal(t, "util", l.Packages[0].Namespace)
+	assert.Equal(t, "bank", l.Packages[1].Namespace)
+	assert.Equal(t, "app", l.Packages[2].Namespace)
+
+	bank := l.Packages[1]
+	assert.Equal(t, 2, len(bank.Files))
+	assert.Equal(t, "lib/bank/account.tiny", bank.Files[0].Path)
+	assert.Equal(t, l.Packages[0], bank.Imports["util"])
+
+	assert.Equal(t, 4, len(l.FileSet.Files()))
+	assert.Equal(t, bank.Files[0].Source, l.FileSet.File("lib/bank/account.tiny").Source())
+
+	app := packages[0]
+	assert.Equal(t, "Account", app.Lookup("bank.Account").(*ast.StructureDeclaration).Name)
+	assert.Equal(t, "main", app.Lookup("main").(*ast.FunctionDeclaration).Name)
+	assert.Nil(t, app.Lookup("util.max"))
+	assert.Nil(t, app.Lookup("bank.Missing"))
+}
+
+func TestDisplayPath(t *testing.T) {
+	root := t.TempDir()
+	l := NewLoader(Config{Root: root}, utils.NewCodeProblemHandler())
+
+	// directories starting with dots are still inside of the root
+	assert.Equal(t, "..a/a.tiny", l.displayPath(filepath.Join(root, "..a", "a.tiny")))
+
+	outside := filepath.Join(filepath.Dir(root), "a.tiny")
+	assert.Equal(t, outside, l.displayPath(outside))
+	assert.Equal(t, filepath.Dir(root), l.displayPath(filepath.Dir(root)))
+}
+
+func TestLoadPattern(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"main.tiny":          "namespace \"app\";",
+		"a/a.tiny":           "namespace \"a\";",
+		"a/b/b.tiny":         "namespace \"b\";",
+		"a/empty/README.md":  "",
+		"vendor/v/v.tiny":    "namespace \"v\";",
+		"_testdata/bad.tiny": "bad",
+	})
+
+	l, packages := load(root, "...")
+	assert.True(t, l.Ok())
+	assert.Equal(t, 3, len(packages))
+}
+
+func TestLoadPatternOnce(t *testing.T) {
+	// files of a are walked before and after its subdirectory
+	root := writeTree(t, map[string]string{
+		"a/a.tiny":   "namespace \"a\";",
+		"a/b/x.tiny": "namespace \"b\";",
+		"a/c.tiny":   "namespace \"a\";",
+	})
+
+	l, packages := load(root, "a/...", "a", "a/b/...")
+	assert.True(t, l.Ok())
+	assert.Equal(t, 2, len(packages))
+	assert.Equal(t, "a", packages[0].Namespace)
+	assert.Equal(t, "b", packages[1].Namespace)
+}
+
+func TestMultipleNamespaces(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/a.tiny": "namespace \"app\";",
+		"app/b.tiny": "namespace \"other\";",
+	})
+
+	l, _ := load(root, "app")
+	assert.False(t, l.Ok())
+	assert.False(t, l.Packages[0].Files[1].ProblemHandler.Ok)
+
+	labels := l.Packages[0].Files[1].ProblemHandler.Problems()[0].Labels()
+	assert.Equal(t, 1, len(labels))
+	assert.Equal(t, "app/a.tiny", labels[0].Location.StartLocation.Filepath)
+}
+
+func TestPackageNotFound(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/a.tiny": "namespace \"app\";\nimport \"missing\";",
+	})
+
+	l, _ := load(root, "app")
+	assert.False(t, l.Ok())
+}
+
+func TestImportCycle(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a/a.tiny": "namespace \"a\";\nimport \"b\";",
+		"b/b.tiny": "namespace \"b\";\nimport \"a\";",
+	})
+
+	l, _ := load(root, "a")
+	assert.False(t, l.Ok())
+	assert.Equal(t, 2, len(l.Packages))
+	assert.False(t, l.Packages[0].Files[0].ProblemHandler.Ok)
+	assert.True(t, l.Packages[1].Files[0].ProblemHandler.Ok)
+}
+
+func TestRedeclared(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/a.tiny": "namespace \"app\";\nfun f() {}",
+		"app/b.tiny": "namespace \"app\";\nstruct f {}",
+	})
+
+	l, _ := load(root, "app")
+	assert.False(t, l.Ok())
+
+	// the problem points to the previous declaration in another file
+	labels := l.Packages[0].Files[1].ProblemHandler.Problems()[0].Labels()
+	assert.Equal(t, 1, len(labels))
+	assert.Equal(t, "previous declaration of f is here", labels[0].Message)
+	assert.Equal(t, 2, labels[0].Location.StartLocation.Line)
+	assert.Equal(t, "app/a.tiny", labels[0].Location.StartLocation.Filepath)
+}
+
+func TestUnusedImport(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/a.tiny": "namespace \"app\";\nimport \"../lib\";\nimport \"../util\";\n" +
+			"fun f(a: *lib.T) {}",
+		"lib/l.tiny":  "namespace \"lib\";\npub struct T {}",
+		"util/u.tiny": "namespace \"util\";",
+	})
+
+	l, packages := load(root, "app")
+	assert.True(t, l.Ok())
+
+	problems := packages[0].Files[0].ProblemHandler.Problems()
+	assert.Equal(t, 1, len(problems))
+	assert.Equal(t, utils.UnusedImportWarn, problems[0].Code())
+	assert.Equal(t, "namespace util is imported but not used", problems[0].Message())
+}
+
+func TestProvidedPackages(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app/main.tiny":              "namespace \"app\";\nimport \"util\";\nimport \"util/strings\";",
+		"util/util.tiny":             "namespace \"wrong\";",
+		"deps/util/util.tiny":        "namespace \"util\";",
+		"deps/util/strings/str.tiny": "namespace \"strings\";",
+	})
+
+	l := NewLoader(Config{
+		Root:     root,
+		Packages: map[string]string{"util": filepath.Join(root, "deps", "util")},
+	}, utils.NewCodeProblemHandler())
+	packages := l.Load(filepath.Join(root, "app"))
+	assert.True(t, l.Ok())
+	assert.Equal(t, 2, len(packages[0].Imports))
+	assert.NotNil(t, packages[0].Imports["util"])
+	assert.NotNil(t, packages[0].Imports["strings"])
+}
+
+func TestExports(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"bank/account.tiny": `namespace "bank";
+		pub struct Account {
+			pub readonly owner: *Owner;
+			name: string;
+
+			pub init(owner: *Owner) {}
+			pub fun check(password: []u8): bool fails {}
+			fun hash(): u64 {}
+		}
+
+		pub struct Owner {
+			age: i32;
+		}
+
+		struct Ledger {
+			pub fun add(account: *Account) {}
+		}
+
+		pub struct Vec<T> {
+			pub fun push(value: T) {}
+		}`,
+		"bank/open.tiny": `namespace "bank";
+		import "io";
+		pub fun open(log: *io.Writer, accounts: Vec<Account>): *Account {}
+		pub fun max<T>(a: T, b: T): T {}
+		pub extern fun rand(): i32;
+		fun audit() {}`,
+		"io/io.tiny": `namespace "io";
+		pub interface Writer {}`,
+	})
+
+	l, packages := load(root, "bank")
+	l.Check()
+	assert.True(t, l.Ok())
+
+	exports := map[string]string{}
+	for _, export := range packages[0].Exports() {
+		exports[export.Symbol] = export.Signature
+	}
+
+	assert.Equal(t, map[string]string{
+		"_TN4bank4openE":            "fun bank.open(log: *io.Writer, accounts: bank.Vec<bank.Account>): *bank.Account",
+		"_TN4bank7Account4initE":    "fun bank.Account.init(owner: *bank.Owner)",
+		"_TN4bank7Account5checkE":   "fun bank.Account.check(password: []u8): bool fails",
+		"_TN4bank7Account7destroyE": "fun bank.Account.destroy()",
+	}, exports)
+}
@@ -0,0 +1,788 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lsp
+
+import (
+	"bytes"
+	"net/url"
+	"path/filepath"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+	"github.com/tinylang-org/tiny/pkg/checker"
+	"github.com/tinylang-org/tiny/pkg/lexer"
+	"github.com/tinylang-org/tiny/pkg/parser"
+	"github.com/tinylang-org/tiny/pkg/utils"
+)
+
+// document is an open source file together with results of its analysis,
+// which are recomputed on every change. Documents are analyzed one by one,
+// so declarations from other files of the namespace are unknown.
+type document struct {
+	uri     string
+	version int
+	source  []byte
+
+	// byte offsets of line starts
+	lines []int
+
+	// all tokens of the source and problems found while scanning them,
+	// which are relexed on the next change
+	lexed *lexer.Tokens
+
+	// tokens of the source without comments
+	tokens []*lexer.Token
+
+	// nil if the source doesn't start with namespace declaration
+	unit *ast.ProgramUnit
+
+	// problems of the parser and the checker, problems of the lexer are
+	// kept with the tokens
+	problemHandler *utils.CodeProblemHandler
+}
+
+// newDocument analyzes text of the document. Tokens of the previous version
+// of the document, if it's not nil, are reused where the text is unchanged,
+// so only the edited part of the source is scanned again.
+func newDocument(uri string, version int, text string, previous *document) *document {
+	source := []byte(text)
+
+	if previous != nil {
+		return analyze(uri, version, source,
+			previous.lexed.Relex(source, changed(previous.source, source)))
+	}
+
+	return analyze(uri, version, source, lexer.Tokenize(uriToPath(uri), source))
+}
+
+// edited returns new version of the document after the changes, which are
+// applied one after another. Changes without range replace the whole text.
+func (d *document) edited(version int, changes []TextDocumentContentChangeEvent) *document {
+	text, lexed := d, d.lexed
+
+	for i, change := range changes {
+		var edit lexer.TextEdit
+		if change.Range != nil {
+			edit = lexer.TextEdit{Start: text.offset(change.Range.Start),
+				End: text.offset(change.Range.End), Text: change.Text}
+
+			if edit.End < edit.Start {
+				edit.End = edit.Start
+			}
+		} else {
+			edit = changed(text.source, []byte(change.Text))
+		}
+
+		source := make([]byte, 0, len(text.source)-(edit.End-edit.Start)+len(edit.Text))
+		source = append(source, text.source[:edit.Start]...)
+		source = append(source, edit.Text...)
+		source = append(source, text.source[edit.End:]...)
+
+		lexed = lexed.Relex(source, edit)
+		text = &document{source: source}
+
+		// positions of the following changes are in the edited text
+		if i+1 < len(changes) {
+			text.lines = lineStarts(source)
+		}
+	}
+
+	return analyze(d.uri, version, text.source, lexed)
+}
+
+// analyze parses and checks the source given its tokens.
+func analyze(uri string, version int, source []byte, lexed *lexer.Tokens) *document {
+	d := &document{uri: uri, version: version, source: source, lines: lineStarts(source),
+		lexed: lexed}
+
+	d.problemHandler = utils.NewCodeProblemHandler()
+	d.problemHandler.SetSource(d.source)
+
+	tokens := d.lexed.Slice()
+
+	p := parser.NewParserFromTokens(uriToPath(uri), tokens, d.problemHandler)
+	d.unit = p.ParseProgramUnit()
+
+	if d.unit != nil {
+		checker.NewChecker(d.problemHandler).CheckProgramUnit(d.unit)
+	}
+
+	for _, token := range tokens {
+		if token.Kind != lexer.EOFTokenKind && token.Kind != lexer.CommentTokenKind {
+			d.tokens = append(d.tokens, token)
+		}
+	}
+
+	return d
+}
+
+// lineStarts returns byte offsets of line starts of the source.
+func lineStarts(source []byte) []int {
+	lines := []int{0}
+	for i, c := range source {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+
+	return lines
+}
+
+// problems returns problems of the lexer followed by problems of the parser
+// and the checker.
+func (d *document) problems() []*utils.CodeProblem {
+	return append(append([]*utils.CodeProblem{}, d.lexed.Problems()...),
+		d.problemHandler.Problems()...)
+}
+
+// changed returns edit, which turns old text into the new one, replacing
+// everything between their common prefix and suffix.
+func changed(old []byte, new []byte) lexer.TextEdit {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	return lexer.TextEdit{Start: prefix, End: len(old) - suffix,
+		Text: string(new[prefix : len(new)-suffix])}
+}
+
+// uriToPath returns path of the file for `file` URIs and the URI itself
+// otherwise.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+// line returns text of the line without line break.
+func (d *document) line(line int) string {
+	text := d.source[d.lines[line]:]
+	if i := bytes.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+
+	return string(text)
+}
+
+// offset returns byte offset of the position. Positions outside of the
+// document are moved to its closest end, characters after the end of the
+// line to the end of the line.
+func (d *document) offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+
+	if position.Line >= len(d.lines) {
+		return len(d.source)
+	}
+
+	text := d.line(position.Line)
+
+	offset := utils.UTF16ColumnToByte(text, position.Character)
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	return d.lines[position.Line] + offset
+}
+
+func (d *document) position(location *utils.CodePointLocation) Position {
+	line := location.Line - 1
+	if line >= len(d.lines) {
+		line = len(d.lines) - 1
+	}
+
+	index := location.Index
+	if index > len(d.source) {
+		index = len(d.source)
+	}
+
+	return Position{Line: line,
+		Character: utils.ByteToUTF16Column(d.line(line), index-d.lines[line])}
+}
+
+func (d *document) rangeOf(location *utils.CodeBlockLocation) Range {
+	return Range{Start: d.position(location.StartLocation),
+		End: d.position(location.EndLocation)}
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, problem := range d.problems() {
+		diagnostics = append(diagnostics, d.diagnostic(problem))
+	}
+
+	return diagnostics
+}
+
+func (d *document) diagnostic(problem *utils.CodeProblem) Diagnostic {
+	diagnostic := Diagnostic{
+		Severity: SeverityWarning,
+		Code:     problem.ID(),
+		Source:   "tinyc",
+		Message:  problem.Message(),
+	}
+
+	if problem.Severity() == utils.ErrorSeverity {
+		diagnostic.Severity = SeverityError
+	}
+
+	for _, note := range problem.Notes() {
+		diagnostic.Message += "\nnote: " + note
+	}
+
+	for _, help := range problem.Help() {
+		diagnostic.Message += "\nhelp: " + help
+	}
+
+	// global problems are shown in the beginning of the document
+	if location := problem.Location(); location != nil {
+		diagnostic.Range = d.rangeOf(location)
+	}
+
+	// positions in other files can't be converted without their source
+	for _, label := range problem.Labels() {
+		if label.Location.StartLocation.Filepath == uriToPath(d.uri) {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation,
+				DiagnosticRelatedInformation{
+					Location: Location{URI: d.uri, Range: d.rangeOf(label.Location)},
+					Message:  label.Message,
+				})
+		}
+	}
+
+	return diagnostic
+}
+
+// codeActions returns quick fixes of problems in the range.
+func (d *document) codeActions(r Range) []CodeAction {
+	actions := []CodeAction{}
+
+	for _, problem := range d.problems() {
+		if len(problem.Fixes()) == 0 || problem.Location() == nil {
+			continue
+		}
+
+		diagnostic := d.diagnostic(problem)
+		if before(diagnostic.Range.End, r.Start) || before(r.End, diagnostic.Range.Start) {
+			continue
+		}
+
+		for _, fix := range problem.Fixes() {
+			edits := []TextEdit{}
+			for _, edit := range fix.Edits {
+				edits = append(edits, TextEdit{Range: d.rangeOf(edit.Location), NewText: edit.Text})
+			}
+
+			actions = append(actions, CodeAction{
+				Title:       fix.Message,
+				Kind:        QuickFixKind,
+				Diagnostics: []Diagnostic{diagnostic},
+				IsPreferred: len(problem.Fixes()) == 1,
+				Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}},
+			})
+		}
+	}
+
+	return actions
+}
+
+// before reports whether position a is before position b.
+func before(a Position, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+// scope contains names declared by a function or a structure, which are
+// visible inside of it.
+type scope struct {
+	parent *scope
+
+	typeParameters []*ast.TypeParameter
+	arguments      []*ast.FunctionArgument
+}
+
+// resolve returns declaration of the name visible in the scope or nil if the
+// name isn't declared in the document.
+func (d *document) resolve(name string, s *scope) ast.AST {
+	for ; s != nil; s = s.parent {
+		for _, argument := range s.arguments {
+			if argument.Name == name {
+				return argument
+			}
+		}
+
+		for _, parameter := range s.typeParameters {
+			if parameter.Name == name {
+				return parameter
+			}
+		}
+	}
+
+	if d.unit == nil {
+		return nil
+	}
+
+	for _, statement := range d.unit.TLStatements {
+		if declarationName(statement) == name {
+			return statement
+		}
+	}
+
+	return nil
+}
+
+// structureOf returns structure, which is the type or which the type points
+// to, or nil if the type is not a structure declared in the document.
+func (d *document) structureOf(t ast.Type, s *scope) *ast.StructureDeclaration {
+	for {
+		pointer, ok := t.(*ast.PointerType)
+		if !ok {
+			break
+		}
+
+		t = pointer.Type
+	}
+
+	custom, ok := t.(*ast.CustomType)
+	if !ok {
+		return nil
+	}
+
+	structure, _ := d.resolve(custom.Name, s).(*ast.StructureDeclaration)
+	return structure
+}
+
+// symbol is a name in the source and declaration the name refers to.
+type symbol struct {
+	token *lexer.Token
+
+	// nil if the name is not declared in the document
+	declaration ast.AST
+}
+
+func contains(location *utils.CodeBlockLocation, token *lexer.Token) bool {
+	return location.StartLocation.Index <= token.Location.StartLocation.Index &&
+		token.Location.EndLocation.Index <= location.EndLocation.Index
+}
+
+// identifierAt returns identifier, which contains the offset or ends at it.
+func (d *document) identifierAt(offset int) *lexer.Token {
+	for _, token := range d.tokens {
+		if token.Location.StartLocation.Index > offset {
+			break
+		}
+
+		if token.Kind == lexer.IdentifierTokenKind &&
+			offset <= token.Location.EndLocation.Index {
+			return token
+		}
+	}
+
+	return nil
+}
+
+// nameToken returns token of the name in the declaration.
+func (d *document) nameToken(declaration ast.AST) *lexer.Token {
+	name := declarationName(declaration)
+
+	for _, token := range d.tokens {
+		if token.Kind != lexer.StringTokenKind && token.Literal == name &&
+			contains(declaration.Location(), token) {
+			return token
+		}
+	}
+
+	return nil
+}
+
+// nameRange returns range of the name in the declaration.
+func (d *document) nameRange(declaration ast.AST) Range {
+	if token := d.nameToken(declaration); token != nil {
+		return d.rangeOf(token.Location)
+	}
+
+	return d.rangeOf(declaration.Location())
+}
+
+// symbolAt returns symbol at the offset or nil if there's no identifier.
+func (d *document) symbolAt(offset int) *symbol {
+	token := d.identifierAt(offset)
+	if token == nil || d.unit == nil {
+		return nil
+	}
+
+	for _, statement := range d.unit.TLStatements {
+		if !contains(statement.Location(), token) {
+			continue
+		}
+
+		var declaration ast.AST
+
+		switch statement := statement.(type) {
+		case *ast.FunctionDeclaration:
+			declaration = d.findInFunction(token, statement, nil)
+		case *ast.StructureDeclaration:
+			declaration = d.findInStructure(token, statement)
+		case *ast.InterfaceDeclaration:
+			declaration = d.findInInterface(token, statement)
+		case *ast.ExternFunctionDeclaration:
+			declaration = d.findInSignature(token, statement, nil,
+				statement.Arguments, statement.ReturnType, nil)
+		}
+
+		return &symbol{token: token, declaration: declaration}
+	}
+
+	return nil
+}
+
+// findInSignature returns declaration the token refers to, if the token is
+// the name of the declaration or is a part of its type parameters, arguments
+// or return type.
+func (d *document) findInSignature(token *lexer.Token, declaration ast.AST,
+	typeParameters []*ast.TypeParameter, arguments []*ast.FunctionArgument,
+	returnType ast.Type, s *scope) ast.AST {
+	if d.nameToken(declaration) == token {
+		return declaration
+	}
+
+	for _, parameter := range typeParameters {
+		if d.nameToken(parameter) == token {
+			return parameter
+		}
+
+		if parameter.Constraint != nil && contains(parameter.Constraint.Location(), token) {
+			return d.findInType(token, parameter.Constraint, s)
+		}
+	}
+
+	for _, argument := range arguments {
+		if d.nameToken(argument) == token {
+			return argument
+		}
+
+		if contains(argument.Type.Location(), token) {
+			return d.findInType(token, argument.Type, s)
+		}
+	}
+
+	if returnType != nil && contains(returnType.Location(), token) {
+		return d.findInType(token, returnType, s)
+	}
+
+	return nil
+}
+
+func (d *document) findInFunction(token *lexer.Token, function *ast.FunctionDeclaration,
+	parent *scope) ast.AST {
+	s := &scope{parent: parent, typeParameters: function.TypeParameters,
+		arguments: function.Arguments}
+
+	if function.StatementsBlock.StartLocation.Index < token.Location.StartLocation.Index {
+		return d.findInBody(token, s)
+	}
+
+	return d.findInSignature(token, function, function.TypeParameters,
+		function.Arguments, function.ReturnType, s)
+}
+
+// findInBody resolves names used in function body. Only arguments, top level
+// declarations and members of structures accessed through arguments
+// (`account.balance`) are known.
+func (d *document) findInBody(token *lexer.Token, s *scope) ast.AST {
+	i := d.tokenIndex(token)
+
+	if i >= 2 && d.tokens[i-1].Kind == lexer.DotTokenKind &&
+		d.tokens[i-2].Kind == lexer.IdentifierTokenKind {
+		return d.findMember(d.tokens[i-2].Literal, token.Literal, s)
+	}
+
+	return d.resolve(token.Literal, s)
+}
+
+// findMember returns member or method of the structure, which is type of the
+// argument.
+func (d *document) findMember(argumentName string, name string, s *scope) ast.AST {
+	argument, ok := d.resolve(argumentName, s).(*ast.FunctionArgument)
+	if !ok {
+		return nil
+	}
+
+	structure := d.structureOf(argument.Type, s)
+	if structure == nil {
+		return nil
+	}
+
+	for _, member := range structure.Members {
+		if member.Name == name {
+			return member
+		}
+	}
+
+	for _, method := range structure.Methods {
+		if method.Name == name {
+			return method
+		}
+	}
+
+	return nil
+}
+
+func (d *document) findInStructure(token *lexer.Token,
+	structure *ast.StructureDeclaration) ast.AST {
+	s := &scope{typeParameters: structure.TypeParameters}
+
+	for _, method := range structure.Methods {
+		if contains(method.Location(), token) {
+			return d.findInFunction(token, method, s)
+		}
+	}
+
+	for _, member := range structure.Members {
+		if d.nameToken(member) == token {
+			return member
+		}
+
+		if contains(member.Type.Location(), token) {
+			return d.findInType(token, member.Type, s)
+		}
+	}
+
+	for _, implements := range structure.Implements {
+		if contains(implements.Location(), token) {
+			return d.findInType(token, implements, s)
+		}
+	}
+
+	return d.findInSignature(token, structure, structure.TypeParameters, nil, nil, s)
+}
+
+func (d *document) findInInterface(token *lexer.Token,
+	declaration *ast.InterfaceDeclaration) ast.AST {
+	for _, method := range declaration.Methods {
+		if contains(method.Location(), token) {
+			s := &scope{typeParameters: method.TypeParameters}
+			return d.findInSignature(token, method, method.TypeParameters,
+				method.Arguments, method.ReturnType, s)
+		}
+	}
+
+	if d.nameToken(declaration) == token {
+		return declaration
+	}
+
+	return nil
+}
+
+func (d *document) findInType(token *lexer.Token, t ast.Type, s *scope) ast.AST {
+	switch t := t.(type) {
+	case *ast.PointerType:
+		return d.findInType(token, t.Type, s)
+	case *ast.ArrayType:
+		return d.findInType(token, t.Type, s)
+	case *ast.CustomType:
+		for _, argument := range t.Arguments {
+			if contains(argument.Location(), token) {
+				return d.findInType(token, argument, s)
+			}
+		}
+
+		return d.resolve(t.Name, s)
+	}
+
+	return nil
+}
+
+func (d *document) tokenIndex(token *lexer.Token) int {
+	for i, t := range d.tokens {
+		if t == token {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// scopeAt returns scope of the function, which body contains the offset, or
+// nil if offset is outside of function bodies.
+func (d *document) scopeAt(offset int) *scope {
+	if d.unit == nil {
+		return nil
+	}
+
+	inBody := func(function *ast.FunctionDeclaration) bool {
+		return function.StatementsBlock.StartLocation.Index < offset &&
+			offset < function.Location().EndLocation.Index
+	}
+
+	for _, statement := range d.unit.TLStatements {
+		switch statement := statement.(type) {
+		case *ast.FunctionDeclaration:
+			if inBody(statement) {
+				return &scope{typeParameters: statement.TypeParameters,
+					arguments: statement.Arguments}
+			}
+		case *ast.StructureDeclaration:
+			for _, method := range statement.Methods {
+				if inBody(method) {
+					return &scope{
+						parent:         &scope{typeParameters: statement.TypeParameters},
+						typeParameters: method.TypeParameters,
+						arguments:      method.Arguments,
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// completion returns members of the structure if the offset goes after
+// `argument.`, otherwise keywords and top level declarations.
+func (d *document) completion(offset int) []CompletionItem {
+	i := len(d.tokens) - 1
+	for i >= 0 && d.tokens[i].Location.StartLocation.Index >= offset {
+		i--
+	}
+
+	// skip the name, which is being typed
+	if i >= 0 && d.tokens[i].Kind == lexer.IdentifierTokenKind &&
+		d.tokens[i].Location.EndLocation.Index >= offset {
+		i--
+	}
+
+	if i >= 1 && d.tokens[i].Kind == lexer.DotTokenKind {
+		items := []CompletionItem{}
+
+		if d.tokens[i-1].Kind != lexer.IdentifierTokenKind {
+			return items
+		}
+
+		s := d.scopeAt(offset)
+		argument, ok := d.resolve(d.tokens[i-1].Literal, s).(*ast.FunctionArgument)
+		if !ok {
+			return items
+		}
+
+		structure := d.structureOf(argument.Type, s)
+		if structure == nil {
+			return items
+		}
+
+		for _, member := range structure.Members {
+			items = append(items, CompletionItem{Label: member.Name,
+				Kind: FieldCompletionKind, Detail: describe(member)})
+		}
+
+		for _, method := range structure.Methods {
+			items = append(items, CompletionItem{Label: method.Name,
+				Kind: MethodCompletionKind, Detail: describe(method)})
+		}
+
+		return items
+	}
+
+	items := []CompletionItem{}
+	for _, keyword := range lexer.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: KeywordCompletionKind})
+	}
+
+	if d.unit == nil {
+		return items
+	}
+
+	for _, statement := range d.unit.TLStatements {
+		item := CompletionItem{Label: declarationName(statement), Detail: describe(statement)}
+
+		switch statement.(type) {
+		case *ast.StructureDeclaration:
+			item.Kind = StructCompletionKind
+		case *ast.InterfaceDeclaration:
+			item.Kind = InterfaceCompletionKind
+		default:
+			item.Kind = FunctionCompletionKind
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	if d.unit == nil {
+		return symbols
+	}
+
+	for _, statement := range d.unit.TLStatements {
+		symbol := d.documentSymbol(statement, FunctionSymbolKind)
+
+		switch statement := statement.(type) {
+		case *ast.StructureDeclaration:
+			symbol.Kind = StructSymbolKind
+
+			for _, member := range statement.Members {
+				symbol.Children = append(symbol.Children,
+					d.documentSymbol(member, FieldSymbolKind))
+			}
+
+			for _, method := range statement.Methods {
+				symbol.Children = append(symbol.Children,
+					d.documentSymbol(method, MethodSymbolKind))
+			}
+		case *ast.InterfaceDeclaration:
+			symbol.Kind = InterfaceSymbolKind
+
+			for _, method := range statement.Methods {
+				symbol.Children = append(symbol.Children,
+					d.documentSymbol(method, MethodSymbolKind))
+			}
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+func (d *document) documentSymbol(declaration ast.AST, kind int) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           declarationName(declaration),
+		Detail:         describe(declaration),
+		Kind:           kind,
+		Range:          d.rangeOf(declaration.Location()),
+		SelectionRange: d.nameRange(declaration),
+	}
+}
@@ -0,0 +1,193 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lsp
+
+import (
+	"strings"
+
+	"github.com/tinylang-org/tiny/pkg/ast"
+)
+
+// describe returns declaration the way it is written in the source, without
+// function bodies and structure items: `pub fun max<T: Comparable>(a: T, b: T): T`.
+func describe(declaration ast.AST) string {
+	var sb strings.Builder
+
+	switch declaration := declaration.(type) {
+	case *ast.FunctionDeclaration:
+		writePublic(&sb, declaration.Public)
+		if declaration.Name != "init" && declaration.Name != "destroy" {
+			sb.WriteString("fun ")
+		}
+
+		writeFunction(&sb, declaration.Name, declaration.TypeParameters,
+			declaration.Arguments, false, declaration.ReturnType)
+	case *ast.FunctionSignature:
+		sb.WriteString("fun ")
+		writeFunction(&sb, declaration.Name, declaration.TypeParameters,
+			declaration.Arguments, declaration.Variadic, declaration.ReturnType)
+	case *ast.ExternFunctionDeclaration:
+		writePublic(&sb, declaration.Public)
+		sb.WriteString("extern fun ")
+		writeFunction(&sb, declaration.Name, nil,
+			declaration.Arguments, declaration.Variadic, declaration.ReturnType)
+	case *ast.StructureDeclaration:
+		writePublic(&sb, declaration.Public)
+		sb.WriteString("struct ")
+		sb.WriteString(declaration.Name)
+		writeTypeParameters(&sb, declaration.TypeParameters)
+
+		for i, implements := range declaration.Implements {
+			if i == 0 {
+				sb.WriteString(": ")
+			} else {
+				sb.WriteString(", ")
+			}
+
+			sb.WriteString(formatType(implements))
+		}
+	case *ast.InterfaceDeclaration:
+		writePublic(&sb, declaration.Public)
+		sb.WriteString("interface ")
+		sb.WriteString(declaration.Name)
+	case *ast.StructureMember:
+		writePublic(&sb, declaration.Public)
+		if declaration.Readonly {
+			sb.WriteString("readonly ")
+		}
+
+		sb.WriteString(declaration.Name)
+		sb.WriteString(": ")
+		sb.WriteString(formatType(declaration.Type))
+	case *ast.FunctionArgument:
+		sb.WriteString(declaration.Name)
+		sb.WriteString(": ")
+		sb.WriteString(formatType(declaration.Type))
+	case *ast.TypeParameter:
+		sb.WriteString(declaration.Name)
+		if declaration.Constraint != nil {
+			sb.WriteString(": ")
+			sb.WriteString(formatType(declaration.Constraint))
+		}
+	}
+
+	return sb.String()
+}
+
+func writePublic(sb *strings.Builder, public bool) {
+	if public {
+		sb.WriteString("pub ")
+	}
+}
+
+func writeFunction(sb *strings.Builder, name string, typeParameters []*ast.TypeParameter,
+	arguments []*ast.FunctionArgument, variadic bool, returnType ast.Type) {
+	sb.WriteString(name)
+	writeTypeParameters(sb, typeParameters)
+
+	sb.WriteByte('(')
+	for i, argument := range arguments {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(describe(argument))
+	}
+
+	if variadic {
+		sb.WriteString(", ...")
+	}
+
+	sb.WriteByte(')')
+
+	if returnType != nil {
+		sb.WriteString(": ")
+		sb.WriteString(formatType(returnType))
+	}
+}
+
+func writeTypeParameters(sb *strings.Builder, typeParameters []*ast.TypeParameter) {
+	if len(typeParameters) == 0 {
+		return
+	}
+
+	sb.WriteByte('<')
+	for i, parameter := range typeParameters {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(describe(parameter))
+	}
+
+	sb.WriteByte('>')
+}
+
+func formatType(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.PrimaryType:
+		return t.Token.Literal
+	case *ast.PointerType:
+		return "*" + formatType(t.Type)
+	case *ast.ArrayType:
+		return "[]" + formatType(t.Type)
+	case *ast.CustomType:
+		if len(t.Arguments) == 0 {
+			return t.Name
+		}
+
+		arguments := make([]string, len(t.Arguments))
+		for i, argument := range t.Arguments {
+			arguments[i] = formatType(argument)
+		}
+
+		return t.Name + "<" + strings.Join(arguments, ", ") + ">"
+	}
+
+	return ""
+}
+
+// declarationName returns name of the declaration, which can be referred to
+// by a name in the source.
+func declarationName(declaration ast.AST) string {
+	switch declaration := declaration.(type) {
+	case *ast.FunctionDeclaration:
+		return declaration.Name
+	case *ast.FunctionSignature:
+		return declaration.Name
+	case *ast.ExternFunctionDeclaration:
+		return declaration.Name
+	case *ast.StructureDeclaration:
+		return declaration.Name
+	case *ast.InterfaceDeclaration:
+		return declaration.Name
+	case *ast.StructureMember:
+		return declaration.Name
+	case *ast.FunctionArgument:
+		return declaration.Name
+	case *ast.TypeParameter:
+		return declaration.Name
+	}
+
+	return ""
+}
@@ -0,0 +1,463 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testURI = "file:///project/bank/bank.tiny"
+
+const testSource = `namespace "bank";
+
+pub interface Printable {
+	fun print();
+}
+
+pub struct Account: Printable {
+	pub balance: i64;
+	pub readonly owner: *u8;
+
+	fun print() {}
+	pub fun deposit(amount: i64) {}
+}
+
+// moves money between accounts
+fun transfer(from: *Account, to: *Account, amount: i64) {
+	from.
+}
+`
+
+// client talks to the server running in the same process.
+type client struct {
+	t      *testing.T
+	writer io.WriteCloser
+	nextID int
+
+	// contents of messages sent by the server
+	messages chan []byte
+	done     chan error
+
+	// received notifications
+	notifications []*requestMessage
+}
+
+func newClient(t *testing.T) *client {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+
+	c := &client{
+		t:        t,
+		writer:   clientWriter,
+		messages: make(chan []byte, 64),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverReader, serverWriter).Run()
+		serverWriter.Close()
+	}()
+
+	// messages are read all the time, so the server never waits for the
+	// client to read a notification
+	go func() {
+		reader := bufio.NewReader(clientReader)
+		for {
+			content, err := readMessage(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+
+			c.messages <- content
+		}
+	}()
+
+	return c
+}
+
+func (c *client) send(message *requestMessage) {
+	assert.NoError(c.t, writeMessage(c.writer, message))
+}
+
+func (c *client) notify(method string, params interface{}) {
+	content, err := json.Marshal(params)
+	assert.NoError(c.t, err)
+	c.send(&requestMessage{JSONRPC: "2.0", Method: method, Params: content})
+}
+
+// call sends request and decodes result of the response into result.
+// Returns error of the response.
+func (c *client) call(method string, params interface{}, result interface{}) *ResponseError {
+	c.nextID++
+	id, _ := json.Marshal(c.nextID)
+
+	content, err := json.Marshal(params)
+	assert.NoError(c.t, err)
+	c.send(&requestMessage{JSONRPC: "2.0", ID: id, Method: method, Params: content})
+
+	for content := range c.messages {
+		message := &responseMessage{}
+		assert.NoError(c.t, json.Unmarshal(content, message))
+
+		if message.ID == nil {
+			c.addNotification(content)
+			continue
+		}
+
+		assert.Equal(c.t, string(id), string(message.ID))
+		if message.Error == nil && result != nil {
+			assert.NoError(c.t, json.Unmarshal(message.Result, result))
+		}
+
+		return message.Error
+	}
+
+	c.t.Fatal("connection is closed")
+	return nil
+}
+
+func (c *client) addNotification(content []byte) {
+	notification := &requestMessage{}
+	assert.NoError(c.t, json.Unmarshal(content, notification))
+	c.notifications = append(c.notifications, notification)
+}
+
+// diagnostics waits for the next diagnostics of the document.
+func (c *client) diagnostics() *PublishDiagnosticsParams {
+	for {
+		for i, notification := range c.notifications {
+			if notification.Method == "textDocument/publishDiagnostics" {
+				c.notifications = append(c.notifications[:i], c.notifications[i+1:]...)
+
+				params := &PublishDiagnosticsParams{}
+				assert.NoError(c.t, json.Unmarshal(notification.Params, params))
+				return params
+			}
+		}
+
+		content, ok := <-c.messages
+		if !ok {
+			c.t.Fatal("connection is closed")
+		}
+
+		c.addNotification(content)
+	}
+}
+
+func (c *client) open(text string) *PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "tiny",
+			Version: 1, Text: text}})
+	return c.diagnostics()
+}
+
+func (c *client) close() error {
+	assert.Nil(c.t, c.call("shutdown", nil, nil))
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func position(uri string, line int, character int) *TextDocumentPositionParams {
+	return &TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri},
+		Position: Position{Line: line, Character: character}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+
+	result := &InitializeResult{}
+	assert.Nil(t, c.call("initialize", map[string]interface{}{}, result))
+	assert.Equal(t, IncrementalTextDocumentSync, result.Capabilities.TextDocumentSync)
+	assert.True(t, result.Capabilities.HoverProvider)
+	c.notify("initialized", map[string]interface{}{})
+
+	err := c.call("textDocument/formatting", map[string]interface{}{}, nil)
+	assert.Equal(t, MethodNotFoundCode, err.Code)
+
+	assert.NoError(t, c.close())
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+	assert.Error(t, <-c.done)
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	diagnostics := c.open("namespace \"bank\";\n\nfun f(a i32) {}")
+	assert.Equal(t, testURI, diagnostics.URI)
+	assert.NotEmpty(t, diagnostics.Diagnostics)
+	assert.Equal(t, SeverityError, diagnostics.Diagnostics[0].Severity)
+	assert.Equal(t, Range{Start: Position{2, 8}, End: Position{2, 11}},
+		diagnostics.Diagnostics[0].Range)
+
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Text: "namespace \"bank\";\n\nextern fun f(a: []i32);"}},
+	})
+
+	// errors of the checker are reported too
+	diagnostics = c.diagnostics()
+	assert.Equal(t, 2, diagnostics.Version)
+	assert.Equal(t, 1, len(diagnostics.Diagnostics))
+	assert.Equal(t, "type []i32 can't be used in extern function f",
+		diagnostics.Diagnostics[0].Message)
+
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: testURI, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{
+			Range: &Range{Start: Position{2, 0}, End: Position{2, 7}}, Text: ""}, {
+			Range: &Range{Start: Position{2, 15}, End: Position{2, 16}}, Text: " {}"}},
+	})
+	assert.Equal(t, 0, len(c.diagnostics().Diagnostics))
+
+	// labels of problems are related information
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: testURI, Version: 4},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "namespace \"bank\";\n\n" +
+			"interface Printable { fun print(); }\n" +
+			"struct Account: Printable { fun print(a: i32) {} }"}},
+	})
+
+	diagnostics = c.diagnostics()
+	assert.Equal(t, 1, len(diagnostics.Diagnostics))
+	assert.Equal(t, []DiagnosticRelatedInformation{{
+		Location: Location{URI: testURI,
+			Range: Range{Start: Position{2, 22}, End: Position{2, 33}}},
+		Message: "expected signature is declared here",
+	}}, diagnostics.Diagnostics[0].RelatedInformation)
+
+	c.notify("textDocument/didClose", &DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI}})
+	assert.Equal(t, 0, len(c.diagnostics().Diagnostics))
+
+	err := c.call("textDocument/hover", position(testURI, 0, 0), nil)
+	assert.Equal(t, InvalidParamsCode, err.Code)
+
+	assert.NoError(t, c.close())
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	// only the member after `from.`, which is left for completion, is missing
+	assert.Equal(t, 1, len(c.open(testSource).Diagnostics))
+
+	location := &Location{}
+
+	// type of argument
+	assert.Nil(t, c.call("textDocument/definition", position(testURI, 15, 22), location))
+	assert.Equal(t, testURI, location.URI)
+	assert.Equal(t, Range{Start: Position{6, 11}, End: Position{6, 18}}, location.Range)
+
+	// implemented interface
+	assert.Nil(t, c.call("textDocument/definition", position(testURI, 6, 22), location))
+	assert.Equal(t, Range{Start: Position{2, 14}, End: Position{2, 23}}, location.Range)
+
+	// argument used in the body
+	assert.Nil(t, c.call("textDocument/definition", position(testURI, 16, 2), location))
+	assert.Equal(t, Range{Start: Position{15, 13}, End: Position{15, 17}}, location.Range)
+
+	// keyword
+	location = &Location{}
+	assert.Nil(t, c.call("textDocument/definition", position(testURI, 15, 1), &location))
+	assert.Nil(t, location)
+
+	assert.NoError(t, c.close())
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(testSource)
+
+	hover := &Hover{}
+	assert.Nil(t, c.call("textDocument/hover", position(testURI, 15, 22), hover))
+	assert.Equal(t, "```tiny\npub struct Account: Printable\n```", hover.Contents.Value)
+	assert.Equal(t, Range{Start: Position{15, 20}, End: Position{15, 27}}, hover.Range)
+
+	assert.Nil(t, c.call("textDocument/hover", position(testURI, 15, 5), hover))
+	assert.Equal(t, "```tiny\nfun transfer(from: *Account, to: *Account, amount: i64)\n```",
+		hover.Contents.Value)
+
+	assert.Nil(t, c.call("textDocument/hover", position(testURI, 8, 16), hover))
+	assert.Equal(t, "```tiny\npub readonly owner: *u8\n```", hover.Contents.Value)
+
+	assert.Nil(t, c.call("textDocument/hover", position(testURI, 16, 1), hover))
+	assert.Equal(t, "```tiny\nfrom: *Account\n```", hover.Contents.Value)
+
+	assert.NoError(t, c.close())
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(testSource)
+
+	symbols := []DocumentSymbol{}
+	assert.Nil(t, c.call("textDocument/documentSymbol", &DocumentSymbolParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols))
+
+	assert.Equal(t, 3, len(symbols))
+	assert.Equal(t, "Printable", symbols[0].Name)
+	assert.Equal(t, InterfaceSymbolKind, symbols[0].Kind)
+	assert.Equal(t, "print", symbols[0].Children[0].Name)
+
+	account := symbols[1]
+	assert.Equal(t, StructSymbolKind, account.Kind)
+	assert.Equal(t, Range{Start: Position{6, 0}, End: Position{12, 1}}, account.Range)
+	assert.Equal(t, Range{Start: Position{6, 11}, End: Position{6, 18}}, account.SelectionRange)
+	assert.Equal(t, 4, len(account.Children))
+	assert.Equal(t, "balance", account.Children[0].Name)
+	assert.Equal(t, FieldSymbolKind, account.Children[0].Kind)
+	assert.Equal(t, "pub fun deposit(amount: i64)", account.Children[3].Detail)
+
+	assert.Equal(t, "transfer", symbols[2].Name)
+	assert.Equal(t, FunctionSymbolKind, symbols[2].Kind)
+
+	assert.NoError(t, c.close())
+}
+
+func TestCodeActions(t *testing.T) {
+	c := newClient(t)
+	assert.NotEmpty(t, c.open("namespace \"bank\"\n\nfun f() {}").Diagnostics)
+
+	codeActions := func(start Position, end Position) []CodeAction {
+		actions := []CodeAction{}
+		assert.Nil(t, c.call("textDocument/codeAction", &CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Range:        Range{Start: start, End: end},
+		}, &actions))
+		return actions
+	}
+
+	// missing semicolon is reported at the next token
+	actions := codeActions(Position{2, 1}, Position{2, 1})
+	assert.Equal(t, 1, len(actions))
+	assert.Equal(t, "insert semicolon", actions[0].Title)
+	assert.Equal(t, QuickFixKind, actions[0].Kind)
+	assert.Equal(t, "E0019", actions[0].Diagnostics[0].Code)
+	assert.Equal(t, map[string][]TextEdit{testURI: {{
+		Range:   Range{Start: Position{0, 16}, End: Position{0, 16}},
+		NewText: ";",
+	}}}, actions[0].Edit.Changes)
+
+	assert.Empty(t, codeActions(Position{0, 0}, Position{1, 0}))
+
+	assert.NoError(t, c.close())
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(testSource)
+
+	labels := func(items []CompletionItem) []string {
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+
+		return labels
+	}
+
+	items := []CompletionItem{}
+	assert.Nil(t, c.call("textDocument/completion", position(testURI, 16, 6), &items))
+	assert.Equal(t, []string{"balance", "owner", "print", "deposit"}, labels(items))
+	assert.Equal(t, FieldCompletionKind, items[0].Kind)
+	assert.Equal(t, "pub balance: i64", items[0].Detail)
+
+	items = []CompletionItem{}
+	assert.Nil(t, c.call("textDocument/completion", position(testURI, 13, 0), &items))
+	assert.Contains(t, labels(items), "struct")
+	assert.Contains(t, labels(items), "extern")
+	assert.Contains(t, labels(items), "Account")
+
+	assert.NoError(t, c.close())
+}
+
+func TestUTF16Positions(t *testing.T) {
+	d := newDocument(testURI, 1, "namespace \"ü😀\"; struct S {}", nil)
+
+	// 😀 takes two UTF-16 code units
+	token := d.identifierAt(d.offset(Position{0, 24}))
+	assert.Equal(t, "S", token.Literal)
+	assert.Equal(t, Range{Start: Position{0, 24}, End: Position{0, 25}},
+		d.rangeOf(token.Location))
+}
+
+func TestRelexedTokens(t *testing.T) {
+	previous := newDocument(testURI, 1, "namespace \"app\";\n\n/* fun f() {} */\nstruct S {}\n", nil)
+
+	for _, text := range []string{
+		"namespace \"app\";\n\n/* fun f() {} */\nstruct Sx {}\n",
+		"namespace \"app\";\n\n/* fun f() {} \nstruct Sx {}\n",
+		"namespace \"app\";\n\nfun f() {} */\nstruct Sx {}\n",
+		"namespace \"app\";\n\nfun f() { return \"a; } */\nstruct Sx {}\n",
+		"namespace \"app\";\n\n\nfun f() { return \"a; } */\nstruct Sx {}\n",
+	} {
+		d := newDocument(testURI, 2, text, previous)
+		fresh := newDocument(testURI, 2, text, nil)
+
+		assert.Equal(t, len(fresh.tokens), len(d.tokens))
+		for i, token := range fresh.tokens {
+			assert.Equal(t, token.Dump(), d.tokens[i].Dump())
+		}
+
+		assert.ElementsMatch(t, fresh.diagnostics(), d.diagnostics(), text)
+		previous = d
+	}
+}
+
+func TestIncrementalChanges(t *testing.T) {
+	d := newDocument(testURI, 1, "namespace \"app\";\n\nstruct S {}\n", nil)
+
+	d = d.edited(2, []TextDocumentContentChangeEvent{
+		// ranges of later changes are in the text after earlier ones
+		{Range: &Range{Start: Position{2, 8}, End: Position{2, 8}}, Text: "x"},
+		{Range: &Range{Start: Position{2, 0}, End: Position{2, 0}}, Text: "fun f() { return \"ё; }\n"},
+		{Range: &Range{Start: Position{2, 17}, End: Position{2, 19}}, Text: "\"ё\""},
+	})
+
+	text := "namespace \"app\";\n\nfun f() { return \"ё\"; }\nstruct Sx {}\n"
+	assert.Equal(t, text, string(d.source))
+	assert.Equal(t, 2, d.version)
+
+	fresh := newDocument(testURI, 2, text, nil)
+	assert.Equal(t, len(fresh.tokens), len(d.tokens))
+	for i, token := range fresh.tokens {
+		assert.Equal(t, token.Dump(), d.tokens[i].Dump())
+	}
+
+	assert.Equal(t, fresh.diagnostics(), d.diagnostics())
+
+	// change without range replaces the whole text
+	d = d.edited(3, []TextDocumentContentChangeEvent{{Text: "namespace \"app\";"}})
+	assert.Equal(t, "namespace \"app\";", string(d.source))
+	assert.Equal(t, 3, len(d.tokens))
+}
@@ -0,0 +1,290 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package lsp implements a language server for Tiny. It publishes problems
+// found by the parser and the checker as diagnostics and provides go to
+// definition, hover, document symbols and completion.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Server is a language server, which talks to a client with JSON-RPC
+// messages framed by `Content-Length` headers, like LSP clients do over
+// stdio.
+type Server struct {
+	reader *bufio.Reader
+	writer io.Writer
+
+	// open documents by their URIs
+	documents map[string]*document
+
+	shutdown bool
+
+	// error of writing a notification, which stops the server
+	err error
+}
+
+func NewServer(reader io.Reader, writer io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(reader),
+		writer:    writer,
+		documents: map[string]*document{},
+	}
+}
+
+// Run handles messages until client sends `exit` notification or closes the
+// connection. Returns error if connection fails or client exits without
+// `shutdown` request.
+func (s *Server) Run() error {
+	for {
+		content, err := readMessage(s.reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		request := &requestMessage{}
+		if err := json.Unmarshal(content, request); err != nil {
+			err = s.reply(json.RawMessage("null"), nil,
+				&ResponseError{Code: ParseErrorCode, Message: err.Error()})
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("client exited without shutdown request")
+			}
+
+			return nil
+		}
+
+		result, responseError := s.handle(request)
+		if s.err != nil {
+			return s.err
+		}
+
+		// notifications don't have responses
+		if request.ID == nil {
+			continue
+		}
+
+		if err := s.reply(request.ID, result, responseError); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{},
+	responseError *ResponseError) error {
+	response := &responseMessage{JSONRPC: "2.0", ID: id, Error: responseError}
+
+	if responseError == nil {
+		content, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+
+		response.Result = content
+	}
+
+	return writeMessage(s.writer, response)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	if s.err == nil {
+		s.err = writeMessage(s.writer, &notificationMessage{JSONRPC: "2.0",
+			Method: method, Params: params})
+	}
+}
+
+func decodeParams(request *requestMessage, params interface{}) *ResponseError {
+	if err := json.Unmarshal(request.Params, params); err != nil {
+		return &ResponseError{Code: InvalidParamsCode,
+			Message: fmt.Sprintf("invalid params of %s: %s", request.Method, err)}
+	}
+
+	return nil
+}
+
+func (s *Server) handle(request *requestMessage) (interface{}, *ResponseError) {
+	switch request.Method {
+	case "initialize":
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:       IncrementalTextDocumentSync,
+				DefinitionProvider:     true,
+				HoverProvider:          true,
+				DocumentSymbolProvider: true,
+				CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"."}},
+				CodeActionProvider:     true,
+			},
+			ServerInfo: ServerInfo{Name: "tinyc"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := &DidOpenTextDocumentParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		uri := params.TextDocument.URI
+		s.update(newDocument(uri, params.TextDocument.Version, params.TextDocument.Text,
+			s.documents[uri]))
+		return nil, nil
+	case "textDocument/didChange":
+		params := &DidChangeTextDocumentParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		d, responseError := s.document(params.TextDocument.URI)
+		if responseError != nil {
+			return nil, responseError
+		}
+
+		s.update(d.edited(params.TextDocument.Version, params.ContentChanges))
+		return nil, nil
+	case "textDocument/didClose":
+		params := &DidCloseTextDocumentParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		delete(s.documents, params.TextDocument.URI)
+
+		// clear diagnostics of the closed document
+		s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/definition":
+		d, symbol, err := s.symbolAt(request)
+		if err != nil || symbol == nil || symbol.declaration == nil {
+			return nil, err
+		}
+
+		return &Location{URI: d.uri, Range: d.nameRange(symbol.declaration)}, nil
+	case "textDocument/hover":
+		d, symbol, err := s.symbolAt(request)
+		if err != nil || symbol == nil || symbol.declaration == nil {
+			return nil, err
+		}
+
+		return &Hover{
+			Contents: MarkupContent{Kind: "markdown",
+				Value: "```tiny\n" + describe(symbol.declaration) + "\n```"},
+			Range: d.rangeOf(symbol.token.Location),
+		}, nil
+	case "textDocument/documentSymbol":
+		params := &DocumentSymbolParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.symbols(), nil
+	case "textDocument/codeAction":
+		params := &CodeActionParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.codeActions(params.Range), nil
+	case "textDocument/completion":
+		params := &TextDocumentPositionParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.completion(d.offset(params.Position)), nil
+	}
+
+	// notifications and requests starting with `$/` may be ignored
+	if request.ID == nil || strings.HasPrefix(request.Method, "$/") {
+		return nil, nil
+	}
+
+	return nil, &ResponseError{Code: MethodNotFoundCode,
+		Message: fmt.Sprintf("method %s is not supported", request.Method)}
+}
+
+// update replaces the document with its new version and publishes its
+// diagnostics.
+func (s *Server) update(d *document) {
+	s.documents[d.uri] = d
+
+	s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: d.uri,
+		Version: d.version, Diagnostics: d.diagnostics()})
+}
+
+func (s *Server) document(uri string) (*document, *ResponseError) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: InvalidParamsCode,
+			Message: fmt.Sprintf("document %s is not open", uri)}
+	}
+
+	return d, nil
+}
+
+func (s *Server) symbolAt(request *requestMessage) (*document, *symbol, *ResponseError) {
+	params := &TextDocumentPositionParams{}
+	if err := decodeParams(request, params); err != nil {
+		return nil, nil, err
+	}
+
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, d.symbolAt(d.offset(params.Position)), nil
+}
@@ -0,0 +1,433 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package mangle converts names of Tiny functions and types into linker
+// symbols and back.
+//
+// Symbol consists of "_T" prefix and a path: namespace, structure and
+// function names, each with type arguments if it is a generic
+// instantiation. For example `bank.Vec<i32>.push` is mangled into
+// `_TN4bank3VecIiE4pushE`. Grammar of symbols:
+//
+//	symbol     = "_T" path .
+//	path       = "N" { component } "E" .
+//	component  = identifier [ "I" { type } "E" ] .
+//	identifier = length chars | "u" length "_" hex .
+//	type       = "a" | "s" | "i" | "l" | "h" | "t" | "j" | "m" |
+//	             "P" type | "A" type | path .
+//
+// Identifiers, which contain only ASCII letters, digits and '_' and don't
+// start with a digit, are written after their length in bytes. Other
+// identifiers (with Unicode letters or wrapped in backquotes) are written as
+// "u", length, "_" and hexadecimal UTF-8 bytes, so symbols contain only
+// characters allowed by linkers.
+//
+// Primary types i8, i16, i32, i64, u8, u16, u32, u64 are encoded as
+// "a", "s", "i", "l", "h", "t", "j", "m" respectively, pointers as "P"
+// followed by the pointed type and arrays as "A" followed by the element
+// type.
+package mangle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Prefix starts every mangled Tiny symbol.
+const Prefix = "_T"
+
+// Symbol is a path to a function or a type, for example
+// `bank.Vec<i32>.push`.
+type Symbol struct {
+	Path []*Component
+}
+
+// Component is a name in a symbol path with type arguments of generic
+// instantiation.
+type Component struct {
+	Name      string
+	Arguments []Type
+}
+
+type Type interface {
+	String() string
+	mangle(sb *strings.Builder)
+}
+
+// PrimaryType is one of i8, i16, i32, i64, u8, u16, u32, u64.
+type PrimaryType string
+
+type PointerType struct {
+	Type Type
+}
+
+type ArrayType struct {
+	Type Type
+}
+
+// CustomType is a structure or an interface, for example `io.File`.
+type CustomType struct {
+	Path []*Component
+}
+
+var primaryTypeCodes = map[PrimaryType]byte{
+	"i8": 'a', "i16": 's', "i32": 'i', "i64": 'l',
+	"u8": 'h', "u16": 't', "u32": 'j', "u64": 'm',
+}
+
+var primaryTypesByCode = map[byte]PrimaryType{}
+
+func init() {
+	for t, code := range primaryTypeCodes {
+		primaryTypesByCode[code] = t
+	}
+}
+
+// Mangle returns linker symbol for the path.
+func Mangle(symbol *Symbol) string {
+	var sb strings.Builder
+
+	sb.WriteString(Prefix)
+	manglePath(&sb, symbol.Path)
+
+	return sb.String()
+}
+
+func manglePath(sb *strings.Builder, path []*Component) {
+	sb.WriteByte('N')
+
+	for _, component := range path {
+		mangleIdentifier(sb, component.Name)
+
+		if len(component.Arguments) != 0 {
+			sb.WriteByte('I')
+			for _, argument := range component.Arguments {
+				argument.mangle(sb)
+			}
+			sb.WriteByte('E')
+		}
+	}
+
+	sb.WriteByte('E')
+}
+
+func mangleIdentifier(sb *strings.Builder, name string) {
+	if isPlainIdentifier(name) {
+		sb.WriteString(strconv.Itoa(len(name)))
+		sb.WriteString(name)
+		return
+	}
+
+	encoded := hex.EncodeToString([]byte(name))
+	sb.WriteByte('u')
+	sb.WriteString(strconv.Itoa(len(encoded)))
+	sb.WriteByte('_')
+	sb.WriteString(encoded)
+}
+
+// isPlainIdentifier reports whether name can be written as is. Names starting
+// with a digit are not plain, since the digit would be read as a part of the
+// length.
+func isPlainIdentifier(name string) bool {
+	if name == "" || '0' <= name[0] && name[0] <= '9' {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierCharacter(name[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t PrimaryType) mangle(sb *strings.Builder) { sb.WriteByte(primaryTypeCodes[t]) }
+func (t PrimaryType) String() string             { return string(t) }
+
+func (t *PointerType) mangle(sb *strings.Builder) {
+	sb.WriteByte('P')
+	t.Type.mangle(sb)
+}
+
+func (t *PointerType) String() string { return "*" + t.Type.String() }
+
+func (t *ArrayType) mangle(sb *strings.Builder) {
+	sb.WriteByte('A')
+	t.Type.mangle(sb)
+}
+
+func (t *ArrayType) String() string { return "[]" + t.Type.String() }
+
+func (t *CustomType) mangle(sb *strings.Builder) { manglePath(sb, t.Path) }
+func (t *CustomType) String() string             { return pathString(t.Path) }
+
+// String returns symbol in Tiny notation, for example `bank.Vec<i32>.push`.
+// Names, which are not plain identifiers, are wrapped in backquotes.
+func (s *Symbol) String() string {
+	return pathString(s.Path)
+}
+
+func pathString(path []*Component) string {
+	var sb strings.Builder
+
+	for i, component := range path {
+		if i != 0 {
+			sb.WriteByte('.')
+		}
+
+		if isPlainIdentifier(component.Name) {
+			sb.WriteString(component.Name)
+		} else {
+			sb.WriteString("`" + component.Name + "`")
+		}
+
+		if len(component.Arguments) != 0 {
+			sb.WriteByte('<')
+			for j, argument := range component.Arguments {
+				if j != 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteString(argument.String())
+			}
+			sb.WriteByte('>')
+		}
+	}
+
+	return sb.String()
+}
+
+// Demangle parses linker symbol produced by Mangle.
+func Demangle(symbol string) (*Symbol, error) {
+	if !strings.HasPrefix(symbol, Prefix) {
+		return nil, fmt.Errorf("%s is not a Tiny symbol", symbol)
+	}
+
+	d := &demangler{symbol: symbol, pos: len(Prefix)}
+
+	path, err := d.path()
+	if err != nil {
+		return nil, fmt.Errorf("invalid symbol %s: %s", symbol, err)
+	}
+
+	if d.pos != len(symbol) {
+		return nil, fmt.Errorf("invalid symbol %s: unexpected %q at %d",
+			symbol, symbol[d.pos:], d.pos)
+	}
+
+	return &Symbol{Path: path}, nil
+}
+
+type demangler struct {
+	symbol string
+	pos    int
+}
+
+func (d *demangler) peek() byte {
+	if d.pos < len(d.symbol) {
+		return d.symbol[d.pos]
+	}
+
+	return 0
+}
+
+func (d *demangler) expect(c byte) error {
+	if d.peek() != c {
+		if d.pos >= len(d.symbol) {
+			return fmt.Errorf("expected %q, got end of symbol", c)
+		}
+		return fmt.Errorf("expected %q at %d, got %q", c, d.pos, d.peek())
+	}
+
+	d.pos++
+	return nil
+}
+
+func (d *demangler) path() ([]*Component, error) {
+	if err := d.expect('N'); err != nil {
+		return nil, err
+	}
+
+	path := []*Component{}
+
+	for d.peek() != 'E' {
+		name, err := d.identifier()
+		if err != nil {
+			return nil, err
+		}
+
+		component := &Component{Name: name}
+
+		if d.peek() == 'I' {
+			d.pos++
+
+			for d.peek() != 'E' {
+				argument, err := d.typ()
+				if err != nil {
+					return nil, err
+				}
+
+				component.Arguments = append(component.Arguments, argument)
+			}
+
+			if len(component.Arguments) == 0 {
+				return nil, fmt.Errorf("empty type arguments at %d", d.pos)
+			}
+
+			d.pos++ // 'E'
+		}
+
+		path = append(path, component)
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty path at %d", d.pos)
+	}
+
+	d.pos++ // 'E'
+	return path, nil
+}
+
+func (d *demangler) identifier() (string, error) {
+	encoded := false
+	if d.peek() == 'u' {
+		encoded = true
+		d.pos++
+	}
+
+	start := d.pos
+	for '0' <= d.peek() && d.peek() <= '9' {
+		d.pos++
+	}
+
+	if start == d.pos {
+		if d.pos >= len(d.symbol) {
+			return "", fmt.Errorf("expected identifier, got end of symbol")
+		}
+		return "", fmt.Errorf("expected identifier at %d, got %q", d.pos, d.peek())
+	}
+
+	length, err := strconv.Atoi(d.symbol[start:d.pos])
+	if err == nil && encoded {
+		err = d.expect('_')
+	}
+
+	if err != nil || length == 0 || d.symbol[start] == '0' || length > len(d.symbol)-d.pos {
+		return "", fmt.Errorf("invalid identifier length at %d", start)
+	}
+
+	name := d.symbol[d.pos : d.pos+length]
+	d.pos += length
+
+	if !encoded {
+		if !isPlainIdentifier(name) {
+			return "", fmt.Errorf("invalid identifier %q at %d", name, start)
+		}
+		return name, nil
+	}
+
+	decoded, err := hex.DecodeString(name)
+	if err != nil || isPlainIdentifier(string(decoded)) {
+		return "", fmt.Errorf("invalid encoded identifier at %d", start)
+	}
+
+	return string(decoded), nil
+}
+
+func (d *demangler) typ() (Type, error) {
+	c := d.peek()
+
+	switch c {
+	case 'P':
+		d.pos++
+		t, err := d.typ()
+		if err != nil {
+			return nil, err
+		}
+		return &PointerType{Type: t}, nil
+	case 'A':
+		d.pos++
+		t, err := d.typ()
+		if err != nil {
+			return nil, err
+		}
+		return &ArrayType{Type: t}, nil
+	case 'N':
+		path, err := d.path()
+		if err != nil {
+			return nil, err
+		}
+		return &CustomType{Path: path}, nil
+	}
+
+	if t, ok := primaryTypesByCode[c]; ok {
+		d.pos++
+		return t, nil
+	}
+
+	if d.pos >= len(d.symbol) {
+		return nil, fmt.Errorf("expected type, got end of symbol")
+	}
+
+	return nil, fmt.Errorf("expected type at %d, got %q", d.pos, c)
+}
+
+// DemangleText replaces all Tiny symbols in the text, for example in linker
+// errors or profiler output, with their demangled names. Suffixes added by
+// compilers and linkers, like `.cold` or `@plt`, are kept. Words, which look
+// like symbols, but can't be demangled, are left unchanged.
+func DemangleText(text string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(text); {
+		start := strings.Index(text[i:], Prefix+"N")
+		if start == -1 {
+			sb.WriteString(text[i:])
+			break
+		}
+
+		start += i
+		sb.WriteString(text[i:start])
+
+		d := &demangler{symbol: text[start:], pos: len(Prefix)}
+		path, err := d.path()
+
+		// symbol must not be a part of another word
+		if err != nil || start > 0 && isIdentifierCharacter(text[start-1]) ||
+			d.pos < len(d.symbol) && isIdentifierCharacter(d.symbol[d.pos]) {
+			sb.WriteString(Prefix)
+			i = start + len(Prefix)
+			continue
+		}
+
+		sb.WriteString(pathString(path))
+		i = start + d.pos
+	}
+
+	return sb.String()
+}
+
+func isIdentifierCharacter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
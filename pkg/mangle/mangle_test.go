@@ -0,0 +1,109 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package mangle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func path(names ...string) []*Component {
+	components := []*Component{}
+	for _, name := range names {
+		components = append(components, &Component{Name: name})
+	}
+
+	return components
+}
+
+func TestMangle(t *testing.T) {
+	tests := []struct {
+		symbol   *Symbol
+		mangled  string
+		readable string
+	}{
+		{&Symbol{Path: path("main", "main")}, "_TN4main4mainE", "main.main"},
+		{&Symbol{Path: path("bank", "Account", "deposit")},
+			"_TN4bank7Account7depositE", "bank.Account.deposit"},
+		{&Symbol{Path: []*Component{
+			{Name: "bank"},
+			{Name: "Vec", Arguments: []Type{PrimaryType("i32")}},
+			{Name: "push"},
+		}}, "_TN4bank3VecIiE4pushE", "bank.Vec<i32>.push"},
+		{&Symbol{Path: []*Component{
+			{Name: "util"},
+			{Name: "max", Arguments: []Type{
+				&PointerType{Type: PrimaryType("u8")},
+				&ArrayType{Type: &CustomType{Path: []*Component{
+					{Name: "io"},
+					{Name: "Pair", Arguments: []Type{PrimaryType("u64"), PrimaryType("i16")}},
+				}}},
+			}},
+		}}, "_TN4util3maxIPhAN2io4PairImsEEEE", "util.max<*u8, []io.Pair<u64, i16>>"},
+		{&Symbol{Path: path("утилиты", "hello world")},
+			"_TNu28_d183d182d0b8d0bbd0b8d182d18bu22_68656c6c6f20776f726c64E",
+			"`утилиты`.`hello world`"},
+		{&Symbol{Path: path("x", "1st")}, "_TN1xu6_317374E", "x.`1st`"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.mangled, Mangle(test.symbol))
+		assert.Equal(t, test.readable, test.symbol.String())
+
+		demangled, err := Demangle(test.mangled)
+		assert.NoError(t, err)
+		assert.Equal(t, test.symbol, demangled)
+	}
+}
+
+func TestDemangleInvalid(t *testing.T) {
+	for _, symbol := range []string{
+		"main", "_T", "_TN", "_TNE", "_TN4mainE4main", "_TN5mainE", "_TN04mainE",
+		"_TN4mainIE", "_TN4mainIzEE", "_TN4mainIPE", "_TNu3_abcE", "_TNu8_6d61696eE", "_TNu2_zzE",
+		"_TN4ma-nE",
+		// lengths past the end of the symbol, overflowing and too large for int
+		"_TN9999xE", "_TN9223372036854775807xE", "_TN9223372036854775806xE",
+		"_TN99999999999999999999xE", "_TNu9223372036854775807_abE",
+	} {
+		_, err := Demangle(symbol)
+		assert.Error(t, err, symbol)
+	}
+}
+
+func TestDemangleText(t *testing.T) {
+	assert.Equal(t,
+		"main.o: in function `main.main':\n"+
+			"main.tiny:(.text+0x1c): undefined reference to `bank.Vec<i32>.push'",
+		DemangleText("main.o: in function `_TN4main4mainE':\n"+
+			"main.tiny:(.text+0x1c): undefined reference to `_TN4bank3VecIiE4pushE'"))
+
+	assert.Equal(t, "  12.50%  bank.Account.deposit.cold  bank.Account.deposit@plt",
+		DemangleText("  12.50%  _TN4bank7Account7depositE.cold  _TN4bank7Account7depositE@plt"))
+
+	// invalid and embedded symbols are left unchanged
+	assert.Equal(t, "_TN4mainX x_TN4main4mainE _TN4main4mainEx _TN",
+		DemangleText("_TN4mainX x_TN4main4mainE _TN4main4mainEx _TN"))
+	assert.Equal(t, "undefined ref _TN9223372036854775806xE",
+		DemangleText("undefined ref _TN9223372036854775806xE"))
+}
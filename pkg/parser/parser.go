@@ -44,18 +44,20 @@ const (
 )
 
 var precedences = map[int]int{
-	lexer.EQOpTokenKind:        Equals,
-	lexer.NEQOpTokenKind:       Equals,
-	lexer.LTOpTokenKind:        LessOrGreater,
-	lexer.GTOpTokenKind:        LessOrGreater,
-	lexer.LTEOpTokenKind:       LessOrGreater,
-	lexer.GTEOpTokenKind:       LessOrGreater,
-	lexer.PlusOpTokenKind:      Sum,
-	lexer.MinusOpTokenKind:     Sum,
-	lexer.MulOpTokenKind:       Product,
-	lexer.DivOpTokenKind:       Product,
-	lexer.OpenParentTokenKind:  FunctionCall,
-	lexer.OpenBracketTokenKind: Index,
+	lexer.EQOpTokenKind:          Equals,
+	lexer.NEQOpTokenKind:         Equals,
+	lexer.LTOpTokenKind:          LessOrGreater,
+	lexer.GTOpTokenKind:          LessOrGreater,
+	lexer.LTEOpTokenKind:         LessOrGreater,
+	lexer.GTEOpTokenKind:         LessOrGreater,
+	lexer.PlusOpTokenKind:        Sum,
+	lexer.MinusOpTokenKind:       Sum,
+	lexer.MulOpTokenKind:         Product,
+	lexer.DivOpTokenKind:         Product,
+	lexer.OpenParentTokenKind:    FunctionCall,
+	lexer.HandleKeywordTokenKind: FunctionCall,
+	lexer.OpenBracketTokenKind:   Index,
+	lexer.DotTokenKind:           Index,
 }
 
 type Parser struct {
@@ -65,13 +67,18 @@ type Parser struct {
 	filepath string
 
 	problem_handler *utils.CodeProblemHandler
-	lexer           *lexer.Lexer
+
+	// returns the next token of the source including comments
+	nextToken func() *lexer.Token
 
 	prefixParseFunctions map[int]prefixParseFunction
 	infixParseFunctions  map[int]infixParseFunction
 
 	currentToken *lexer.Token
 	peekToken    *lexer.Token
+
+	// token, which goes after peekToken, when `>>` was split into two `>`
+	pendingToken *lexer.Token
 }
 
 type (
@@ -81,20 +88,54 @@ type (
 
 func NewParser(filepath string, source []byte,
 	problem_handler *utils.CodeProblemHandler) *Parser {
-	p := &Parser{filepath: filepath,
-		lexer: lexer.NewLexer(filepath, source, problem_handler)}
+	l := lexer.NewLexer(filepath, source, problem_handler)
 
-	p.problem_handler = problem_handler
+	p := newParser(filepath, l.NextToken, problem_handler)
+	p.LineStartOffsets = l.LineStartOffsets
+	p.LineEndOffsets = l.LineEndOffsets
+
+	return p
+}
+
+// NewParserFromTokens returns parser of already scanned tokens, for example
+// relexed after an edit of the source. Tokens must end with EOF token, their
+// lexical problems are not reported by the parser. Line offsets of the parser
+// are empty, because the source is unknown to it.
+func NewParserFromTokens(filepath string, tokens []*lexer.Token,
+	problem_handler *utils.CodeProblemHandler) *Parser {
+	next := 0
 
-	p.LineStartOffsets = p.lexer.LineStartOffsets
-	p.LineEndOffsets = p.lexer.LineEndOffsets
+	p := newParser(filepath, func() *lexer.Token {
+		token := tokens[next]
+		if next < len(tokens)-1 {
+			next++
+		}
+
+		return token
+	}, problem_handler)
+	p.LineStartOffsets = &[]int{}
+	p.LineEndOffsets = &[]int{}
+
+	return p
+}
+
+func newParser(filepath string, nextToken func() *lexer.Token,
+	problem_handler *utils.CodeProblemHandler) *Parser {
+	p := &Parser{filepath: filepath, nextToken: nextToken}
+
+	p.problem_handler = problem_handler
 
 	p.prefixParseFunctions = make(map[int]prefixParseFunction)
 
+	p.registerPrefixFunction(lexer.IdentifierTokenKind, p.parseName)
+	p.registerPrefixFunction(lexer.IntTokenKind, p.parseNumberLiteral)
+	p.registerPrefixFunction(lexer.FloatTokenKind, p.parseNumberLiteral)
+	p.registerPrefixFunction(lexer.ImaginaryTokenKind, p.parseNumberLiteral)
 	p.registerPrefixFunction(lexer.BooleanTokenKind, p.parseBooleanLiteral)
 	p.registerPrefixFunction(lexer.StringTokenKind, p.parseStringLiteral)
 	p.registerPrefixFunction(lexer.OpenParentTokenKind, p.parseGroupedExpression)
 	p.registerPrefixFunction(lexer.OpenBracketTokenKind, p.parseArrayLiteral)
+	p.registerPrefixFunction(lexer.NewKeywordTokenKind, p.parseNewExpression)
 
 	p.infixParseFunctions = make(map[int]infixParseFunction)
 
@@ -115,6 +156,7 @@ func NewParser(filepath string, source []byte,
 
 	p.registerInfixFunction(lexer.DotTokenKind, p.parseMemberExpression)
 	p.registerInfixFunction(lexer.OpenParentTokenKind, p.parseFunctionCallExpression)
+	p.registerInfixFunction(lexer.HandleKeywordTokenKind, p.parseHandleExpression)
 	p.registerInfixFunction(lexer.OpenBracketTokenKind, p.parseIndexExpression)
 
 	p.advance()
@@ -210,7 +252,8 @@ func (p *Parser) parseImport() *ast.Import {
 
 // top_level_statement = function_declaration |
 //
-//	struct_declaration .
+//	struct_declaration |
+//	interface_declaration .
 func (p *Parser) parseTopLevelStatementList() []ast.TopLevelStatement {
 	var list []ast.TopLevelStatement
 
@@ -235,6 +278,10 @@ func (p *Parser) parseTopLevelStatement() ast.TopLevelStatement {
 				return p.parseFunctionDeclaration(true)
 			case lexer.StructKeywordTokenKind:
 				return p.parseStructureDeclaration(true)
+			case lexer.InterfaceKeywordTokenKind:
+				return p.parseInterfaceDeclaration(true)
+			case lexer.ExternKeywordTokenKind:
+				return p.parseExternFunctionDeclaration(true)
 			default:
 				p.addUnexpectedPeekTokenError()
 				return nil
@@ -245,6 +292,10 @@ func (p *Parser) parseTopLevelStatement() ast.TopLevelStatement {
 		return p.parseFunctionDeclaration(false)
 	case lexer.StructKeywordTokenKind:
 		return p.parseStructureDeclaration(false)
+	case lexer.InterfaceKeywordTokenKind:
+		return p.parseInterfaceDeclaration(false)
+	case lexer.ExternKeywordTokenKind:
+		return p.parseExternFunctionDeclaration(false)
 	default:
 		p.addUnexpectedCurrentTokenError()
 		return nil
@@ -266,87 +317,161 @@ func (p *Parser) parseFunctionDeclaration(public bool) ast.TopLevelStatement {
 		return nil
 	}
 
+	function := p.parseFunction(startLocation, public)
+	if function == nil {
+		return nil
+	}
+
+	return function
+}
+
+// parseFunction parses function starting from its name, so it can be used for
+// both `fun` declarations and struct methods declared without `fun` keyword
+// (`init` and `destroy`).
+func (p *Parser) parseFunction(startLocation *utils.CodePointLocation,
+	public bool) *ast.FunctionDeclaration {
+	signature := p.parseFunctionSignature(startLocation, false)
+	if signature == nil {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.OpenBraceTokenKind) {
+		return nil
+	}
+
+	block := p.parseStatementsBlock()
+	if block == nil {
+		return nil
+	}
+
+	return &ast.FunctionDeclaration{Public: public,
+		Name:            signature.Name,
+		TypeParameters:  signature.TypeParameters,
+		Arguments:       signature.Arguments,
+		ReturnType:      signature.ReturnType,
+		Failable:        signature.Failable,
+		StatementsBlock: block,
+		BlockLocation: &utils.CodeBlockLocation{
+			StartLocation: startLocation,
+			EndLocation:   block.EndLocation,
+		},
+	}
+}
+
+// function_signature = identifier [ type_parameters ]
+//
+//	"(" [ function_argument { "," function_argument } [ "," "..." ] ] ")"
+//	[ ":" type ] [ "fails" ] .
+//
+// `...` is reported as an error if function is not extern.
+func (p *Parser) parseFunctionSignature(startLocation *utils.CodePointLocation,
+	extern bool) *ast.FunctionSignature {
 	functionName := p.currentToken.Literal
 
-	// TODO: generics
+	typeParameters := []*ast.TypeParameter{}
+	if p.expectPeekNoErr(lexer.LTOpTokenKind) {
+		typeParameters = p.parseTypeParameters()
+		if typeParameters == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(lexer.OpenParentTokenKind) {
 		return nil
 	}
 
 	var arguments []*ast.FunctionArgument
-
-	// p.parseFunctionArgumentsDeclaration()
+	variadic := false
 
 	p.advance()
 	if p.currentTokenIs(lexer.CloseParentTokenKind) {
 		arguments = []*ast.FunctionArgument{}
 	} else {
-		arguments = p.parseFunctionArguments()
+		arguments, variadic = p.parseFunctionArguments(extern)
 
 		if !p.expectCurrent(lexer.CloseParentTokenKind) {
 			return nil
 		}
 	}
 
-	if !p.expectPeek(lexer.OpenBraceTokenKind) {
-		return nil
-	}
-
-	statementsBlockStartLocation := p.currentToken.Location.StartLocation
+	var returnType ast.Type
 
-	p.advance()
+	if p.expectPeekNoErr(lexer.ColonTokenKind) {
+		p.advance() // ':'
 
-	var statements []ast.Statement
-	if p.currentTokenIs(lexer.CloseBraceTokenKind) {
-		statements = []ast.Statement{}
-	} else {
-		statements = p.parseStatementList()
+		returnType = p.parseType()
+		if returnType == nil {
+			return nil
+		}
 	}
 
-	if !p.expectCurrent(lexer.CloseBraceTokenKind) {
-		return nil
-	}
+	failable := false
 
-	endLocation := p.currentToken.Location.EndLocation
+	if p.expectPeekNoErr(lexer.FailsKeywordTokenKind) {
+		failable = true
 
-	return &ast.FunctionDeclaration{Public: public,
-		Name:      functionName,
-		Arguments: arguments,
-		StatementsBlock: &ast.StatementsBlock{
-			Statements:    statements,
-			StartLocation: statementsBlockStartLocation,
-		},
+		// extern functions are implemented in C, which has no way to
+		// report errors to handlers
+		if extern {
+			p.problem_handler.AddCodeProblem(utils.NewLocalError(
+				p.currentToken.Location.Copy(), utils.FailableExternErr, functionName))
+		}
+	}
+
+	return &ast.FunctionSignature{
+		Name:           functionName,
+		TypeParameters: typeParameters,
+		Arguments:      arguments,
+		Variadic:       variadic,
+		ReturnType:     returnType,
+		Failable:       failable,
 		BlockLocation: &utils.CodeBlockLocation{
 			StartLocation: startLocation,
-			EndLocation:   endLocation,
+			EndLocation:   p.currentToken.Location.EndLocation,
 		},
 	}
 }
 
-func (p *Parser) parseFunctionArguments() []*ast.FunctionArgument {
+// parseFunctionArguments parses arguments until ')' and reports whether they
+// end with `...`.
+func (p *Parser) parseFunctionArguments(extern bool) ([]*ast.FunctionArgument, bool) {
 	var arguments []*ast.FunctionArgument
 
-	// i := 0
 	for p.currentToken.Kind != lexer.CloseParentTokenKind {
 		if p.currentToken.Kind == lexer.EOFTokenKind {
 			p.addUnexpectedCurrentTokenError()
-			return nil
+			return nil, false
+		}
+
+		if p.currentToken.Kind == lexer.EllipsisTokenKind && len(arguments) != 0 {
+			if !extern {
+				p.problem_handler.AddCodeProblem(utils.NewLocalError(
+					p.currentToken.Location.Copy(), utils.VariadicNotExternErr))
+			}
+
+			if !p.expectPeek(lexer.CloseParentTokenKind) {
+				return nil, false
+			}
+
+			return arguments, true
 		}
 
 		argument := p.parseFunctionArgument()
-		if argument != nil {
-			arguments = append(arguments, argument)
+		if argument == nil {
+			return nil, false
 		}
 
+		arguments = append(arguments, argument)
+
 		if p.currentToken.Kind == lexer.CommaTokenKind {
 			p.advance() // skip comma
 		}
 	}
 
-	return arguments
+	return arguments, false
 }
 
+// function_argument = identifier ":" type .
 func (p *Parser) parseFunctionArgument() *ast.FunctionArgument {
 	if !p.expectCurrent(lexer.IdentifierTokenKind) {
 		return nil
@@ -354,15 +479,20 @@ func (p *Parser) parseFunctionArgument() *ast.FunctionArgument {
 
 	startLocation := p.currentToken.Location.StartLocation.Copy()
 	name := p.currentToken.Literal
-	p.advance()
+
+	if !p.expectPeek(lexer.ColonTokenKind) {
+		return nil
+	}
+
+	p.advance() // ':'
 
 	typeDef := p.parseType()
 	if typeDef == nil {
 		return nil
 	}
 
-	p.advance()
 	endLocation := p.currentToken.Location.EndLocation.Copy()
+	p.advance()
 
 	return &ast.FunctionArgument{
 		Name: name,
@@ -374,8 +504,389 @@ func (p *Parser) parseFunctionArgument() *ast.FunctionArgument {
 	}
 }
 
+// struct_declaration = [ "pub" ] "struct" identifier [ type_parameters ]
+//
+//	[ ":" implements_list ] "{" { struct_item } "}" .
 func (p *Parser) parseStructureDeclaration(public bool) ast.TopLevelStatement {
-	return nil
+	startLocation := p.currentToken.Location.StartLocation
+
+	if public {
+		p.advance() // 'pub'
+	}
+
+	if !p.expectCurrent(lexer.StructKeywordTokenKind) {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	structure := &ast.StructureDeclaration{
+		Public:         public,
+		Name:           p.currentToken.Literal,
+		TypeParameters: []*ast.TypeParameter{},
+		Methods:        []*ast.FunctionDeclaration{},
+		Members:        []*ast.StructureMember{},
+		Implements:     []*ast.CustomType{},
+	}
+
+	if p.expectPeekNoErr(lexer.LTOpTokenKind) {
+		structure.TypeParameters = p.parseTypeParameters()
+		if structure.TypeParameters == nil {
+			return nil
+		}
+	}
+
+	if p.expectPeekNoErr(lexer.ColonTokenKind) {
+		structure.Implements = p.parseImplementsList()
+		if structure.Implements == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(lexer.OpenBraceTokenKind) {
+		return nil
+	}
+
+	p.advance() // '{'
+
+	for !p.currentTokenIs(lexer.CloseBraceTokenKind) {
+		if p.currentTokenIs(lexer.EOFTokenKind) {
+			p.addUnexpectedCurrentTokenError()
+			return nil
+		}
+
+		p.parseStructureItem(structure)
+
+		p.advance() // ';' or '}'
+	}
+
+	structure.BlockLocation = &utils.CodeBlockLocation{
+		StartLocation: startLocation,
+		EndLocation:   p.currentToken.Location.EndLocation,
+	}
+
+	return structure
+}
+
+// type_parameters = "<" type_parameter { "," type_parameter } ">" .
+// type_parameter = identifier [ ":" custom_type ] .
+func (p *Parser) parseTypeParameters() []*ast.TypeParameter {
+	parameters := []*ast.TypeParameter{}
+
+	for {
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return nil
+		}
+
+		parameter := &ast.TypeParameter{Name: p.currentToken.Literal}
+		startLocation := p.currentToken.Location.StartLocation
+
+		if p.expectPeekNoErr(lexer.ColonTokenKind) {
+			if !p.expectPeek(lexer.IdentifierTokenKind) {
+				return nil
+			}
+
+			constraint := p.parseCustomType()
+			if constraint == nil {
+				return nil
+			}
+
+			parameter.Constraint = constraint.(*ast.CustomType)
+		}
+
+		parameter.BlockLocation = &utils.CodeBlockLocation{
+			StartLocation: startLocation,
+			EndLocation:   p.currentToken.Location.EndLocation,
+		}
+		parameters = append(parameters, parameter)
+
+		if !p.expectPeekNoErr(lexer.CommaTokenKind) {
+			break
+		}
+	}
+
+	if !p.expectClosingAngleBracket() {
+		return nil
+	}
+
+	return parameters
+}
+
+// implements_list = custom_type { "," custom_type } .
+func (p *Parser) parseImplementsList() []*ast.CustomType {
+	implements := []*ast.CustomType{}
+
+	for {
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return nil
+		}
+
+		implemented := p.parseCustomType()
+		if implemented == nil {
+			return nil
+		}
+
+		implements = append(implements, implemented.(*ast.CustomType))
+
+		if !p.expectPeekNoErr(lexer.CommaTokenKind) {
+			return implements
+		}
+	}
+}
+
+// struct_item = { attribute } [ "pub" ] ( struct_member | struct_method ) .
+// struct_method = "fun" identifier function_rest |
+//
+//	"init" function_rest |
+//	"destroy" function_rest .
+//
+// attribute = "@" identifier .
+//
+// The only attribute is `@nodestroy`, which can be used on members.
+func (p *Parser) parseStructureItem(structure *ast.StructureDeclaration) {
+	startLocation := p.currentToken.Location.StartLocation
+
+	var noDestroy *utils.CodeBlockLocation
+
+	for p.currentTokenIs(lexer.AtTokenKind) {
+		attributeStart := p.currentToken.Location.StartLocation
+
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return
+		}
+
+		location := &utils.CodeBlockLocation{StartLocation: attributeStart.Copy(),
+			EndLocation: p.currentToken.Location.EndLocation.Copy()}
+
+		if p.currentToken.Literal == "nodestroy" {
+			noDestroy = location
+		} else {
+			p.problem_handler.AddCodeProblem(utils.NewLocalError(
+				location, utils.UnknownAttributeErr, p.currentToken.Literal))
+		}
+
+		p.advance()
+	}
+
+	methods := len(structure.Methods)
+	members := len(structure.Members)
+
+	p.parseStructureMethodOrMember(structure, startLocation)
+
+	if noDestroy == nil {
+		return
+	}
+
+	if len(structure.Members) > members {
+		structure.Members[members].NoDestroy = true
+	} else if len(structure.Methods) > methods {
+		p.problem_handler.AddCodeProblem(utils.NewLocalError(
+			noDestroy, utils.MisplacedAttributeErr, "nodestroy"))
+	}
+}
+
+func (p *Parser) parseStructureMethodOrMember(structure *ast.StructureDeclaration,
+	startLocation *utils.CodePointLocation) {
+	public := false
+
+	if p.currentTokenIs(lexer.PubKeywordTokenKind) {
+		public = true
+		p.advance() // 'pub'
+	}
+
+	switch p.currentToken.Kind {
+	case lexer.FunKeywordTokenKind:
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return
+		}
+
+		if method := p.parseFunction(startLocation, public); method != nil {
+			structure.Methods = append(structure.Methods, method)
+		}
+	case lexer.DestroyKeywordTokenKind:
+		if method := p.parseFunction(startLocation, public); method != nil {
+			structure.Methods = append(structure.Methods, method)
+		}
+	case lexer.IdentifierTokenKind:
+		if p.currentToken.Literal == "init" && p.peekTokenIs(lexer.OpenParentTokenKind) {
+			if method := p.parseFunction(startLocation, public); method != nil {
+				structure.Methods = append(structure.Methods, method)
+			}
+			return
+		}
+
+		fallthrough
+	case lexer.ReadonlyKeywordTokenKind:
+		if member := p.parseStructureMember(startLocation, public); member != nil {
+			structure.Members = append(structure.Members, member)
+		}
+	default:
+		p.addUnexpectedCurrentTokenError()
+	}
+}
+
+// struct_member = [ "readonly" ] identifier ":" type ";" .
+func (p *Parser) parseStructureMember(startLocation *utils.CodePointLocation,
+	public bool) *ast.StructureMember {
+	readonly := false
+
+	if p.currentTokenIs(lexer.ReadonlyKeywordTokenKind) {
+		readonly = true
+		p.advance() // 'readonly'
+	}
+
+	if !p.expectCurrent(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	name := p.currentToken.Literal
+
+	if !p.expectPeek(lexer.ColonTokenKind) {
+		return nil
+	}
+
+	p.advance() // ':'
+
+	memberType := p.parseType()
+	if memberType == nil {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.SemiColTokenKind) {
+		return nil
+	}
+
+	return &ast.StructureMember{
+		Public:   public,
+		Readonly: readonly,
+		Name:     name,
+		Type:     memberType,
+		BlockLocation: &utils.CodeBlockLocation{
+			StartLocation: startLocation,
+			EndLocation:   p.currentToken.Location.EndLocation,
+		},
+	}
+}
+
+// interface_declaration = [ "pub" ] "interface" identifier [ type_parameters ]
+//
+//	"{" { "fun" function_signature ";" } "}" .
+func (p *Parser) parseInterfaceDeclaration(public bool) ast.TopLevelStatement {
+	startLocation := p.currentToken.Location.StartLocation
+
+	if public {
+		p.advance() // 'pub'
+	}
+
+	if !p.expectCurrent(lexer.InterfaceKeywordTokenKind) {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	declaration := &ast.InterfaceDeclaration{
+		Public:         public,
+		Name:           p.currentToken.Literal,
+		TypeParameters: []*ast.TypeParameter{},
+		Methods:        []*ast.FunctionSignature{},
+	}
+
+	if p.expectPeekNoErr(lexer.LTOpTokenKind) {
+		declaration.TypeParameters = p.parseTypeParameters()
+		if declaration.TypeParameters == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(lexer.OpenBraceTokenKind) {
+		return nil
+	}
+
+	p.advance() // '{'
+
+	for !p.currentTokenIs(lexer.CloseBraceTokenKind) {
+		if !p.expectCurrent(lexer.FunKeywordTokenKind) {
+			return nil
+		}
+
+		methodStartLocation := p.currentToken.Location.StartLocation
+
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return nil
+		}
+
+		method := p.parseFunctionSignature(methodStartLocation, false)
+		if method == nil {
+			return nil
+		}
+
+		if !p.expectPeek(lexer.SemiColTokenKind) {
+			return nil
+		}
+
+		declaration.Methods = append(declaration.Methods, method)
+
+		p.advance() // ';'
+	}
+
+	declaration.BlockLocation = &utils.CodeBlockLocation{
+		StartLocation: startLocation,
+		EndLocation:   p.currentToken.Location.EndLocation,
+	}
+
+	return declaration
+}
+
+// extern_function_declaration = [ "pub" ] "extern" "fun" function_signature ";" .
+func (p *Parser) parseExternFunctionDeclaration(public bool) ast.TopLevelStatement {
+	startLocation := p.currentToken.Location.StartLocation
+
+	if public {
+		p.advance() // 'pub'
+	}
+
+	if !p.expectCurrent(lexer.ExternKeywordTokenKind) {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.FunKeywordTokenKind) {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	signature := p.parseFunctionSignature(startLocation, true)
+	if signature == nil {
+		return nil
+	}
+
+	if len(signature.TypeParameters) != 0 {
+		p.problem_handler.AddCodeProblem(utils.NewLocalError(
+			signature.TypeParameters[0].Location(), utils.GenericExternFunctionErr,
+			signature.Name))
+	}
+
+	if !p.expectPeek(lexer.SemiColTokenKind) {
+		return nil
+	}
+
+	return &ast.ExternFunctionDeclaration{
+		Public:     public,
+		Name:       signature.Name,
+		Arguments:  signature.Arguments,
+		Variadic:   signature.Variadic,
+		ReturnType: signature.ReturnType,
+		BlockLocation: &utils.CodeBlockLocation{
+			StartLocation: startLocation,
+			EndLocation:   p.currentToken.Location.EndLocation,
+		},
+	}
 }
 
 func (p *Parser) parseType() ast.Type {
@@ -423,37 +934,100 @@ func (p *Parser) parseCustomType() ast.Type {
 	var name strings.Builder
 
 	startLocation := p.currentToken.Location.StartLocation.Copy()
+	name.WriteString(p.currentToken.Literal)
+
+	for p.peekTokenIs(lexer.DotTokenKind) {
+		p.advance() // '.'
+
+		if !p.expectPeek(lexer.IdentifierTokenKind) {
+			return nil
+		}
 
-	for p.currentToken.Kind == lexer.IdentifierTokenKind {
-		name.WriteString(p.currentToken.Literal)
 		name.WriteByte('.')
+		name.WriteString(p.currentToken.Literal)
+	}
 
-		if !p.expectPeekNoErr(lexer.DotTokenKind) {
-			p.advance()
-			buffer := name.String()
-			buffer = buffer[0 : len(buffer)-1]
-			return &ast.CustomType{Name: buffer, TypeLocation: &utils.CodeBlockLocation{
-				StartLocation: startLocation,
-				EndLocation:   p.currentToken.Location.StartLocation.Copy(),
-			}}
+	arguments := []ast.Type{}
+	if p.expectPeekNoErr(lexer.LTOpTokenKind) {
+		arguments = p.parseTypeArguments()
+		if arguments == nil {
+			return nil
 		}
+	}
 
-		p.advance() // '.'
+	return &ast.CustomType{Name: name.String(), Arguments: arguments,
+		TypeLocation: &utils.CodeBlockLocation{
+			StartLocation: startLocation,
+			EndLocation:   p.currentToken.Location.EndLocation.Copy(),
+		}}
+}
+
+// type_arguments = "<" type { "," type } ">" .
+func (p *Parser) parseTypeArguments() []ast.Type {
+	arguments := []ast.Type{}
+
+	for {
+		p.advance() // '<' or ','
+
+		argument := p.parseType()
+		if argument == nil {
+			return nil
+		}
+
+		arguments = append(arguments, argument)
+
+		if !p.expectPeekNoErr(lexer.CommaTokenKind) {
+			break
+		}
 	}
 
-	buffer := name.String()
-	buffer = buffer[0 : len(buffer)-1]
-	return &ast.CustomType{Name: buffer, TypeLocation: &utils.CodeBlockLocation{
-		StartLocation: startLocation,
-		EndLocation:   p.currentToken.Location.StartLocation.Copy(),
-	}}
+	if !p.expectClosingAngleBracket() {
+		return nil
+	}
+
+	return arguments
+}
+
+// expectClosingAngleBracket works like expectPeek(lexer.GTOpTokenKind), but
+// also accepts `>>`, which closes two nested lists of type arguments at once
+// (`Vec<Vec<i32>>`).
+func (p *Parser) expectClosingAngleBracket() bool {
+	if p.peekTokenIs(lexer.RShiftOpTokenKind) {
+		location := p.peekToken.Location
+		middle := location.StartLocation.NextByteLocation()
+
+		p.pendingToken = &lexer.Token{Kind: lexer.GTOpTokenKind, Literal: ">",
+			Location: &utils.CodeBlockLocation{StartLocation: middle,
+				EndLocation: location.EndLocation.Copy()}}
+		p.peekToken = &lexer.Token{Kind: lexer.GTOpTokenKind, Literal: ">",
+			Location: &utils.CodeBlockLocation{StartLocation: location.StartLocation.Copy(),
+				EndLocation: middle.Copy()}}
+	}
+
+	return p.expectPeek(lexer.GTOpTokenKind)
+}
+
+// block = "{" { statement } "}" .
+func (p *Parser) parseStatementsBlock() *ast.StatementsBlock {
+	block := &ast.StatementsBlock{StartLocation: p.currentToken.Location.StartLocation}
+
+	p.advance() // '{'
+
+	block.Statements = p.parseStatementList()
+
+	if !p.expectCurrent(lexer.CloseBraceTokenKind) {
+		return nil
+	}
+
+	block.EndLocation = p.currentToken.Location.EndLocation
+	return block
 }
 
 func (p *Parser) parseStatementList() []ast.Statement {
 	statements := []ast.Statement{}
 
-	p.advance() // openbrace
-	for p.currentToken.Kind != lexer.EOFTokenKind {
+	for !p.currentTokenIs(lexer.CloseBraceTokenKind) &&
+		!p.currentTokenIs(lexer.EOFTokenKind) {
 		statement := p.parseStatement()
 
 		if statement != nil {
@@ -470,6 +1044,14 @@ func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Kind {
 	case lexer.ReturnKeywordTokenKind:
 		return p.parseReturnStatement()
+	case lexer.VarKeywordTokenKind:
+		return p.parseVarStatement()
+	case lexer.DestroyKeywordTokenKind:
+		return p.parseDestroyStatement()
+	case lexer.IfKeywordTokenKind:
+		return p.parseIfStatement()
+	case lexer.FailKeywordTokenKind:
+		return p.parseFailStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -478,24 +1060,47 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseReturnStatement() ast.Statement {
 	statement := &ast.ReturnStatement{TokenLocation: p.currentToken.Location.Copy()}
 
-	p.advance()
-	if p.currentTokenIs(lexer.SemiColTokenKind) {
+	if p.peekTokenIs(lexer.SemiColTokenKind) {
+		p.advance() // ';'
 		statement.HasReturnValue = false
-		p.advance()
+		return statement
+	}
+
+	// `}` can't start a value, so only the semicolon after `return` is
+	// missing
+	if p.peekTokenIs(lexer.CloseBraceTokenKind) {
+		p.expectPeek(lexer.SemiColTokenKind)
+		statement.HasReturnValue = false
+		return statement
 	}
 
+	p.advance()
 	statement.ReturnValue = p.parseExpression(Lowest)
-	statement.HasReturnValue = true
+	statement.HasReturnValue = statement.ReturnValue != nil
 
-	if p.expectPeek(lexer.SemiColTokenKind) {
-		p.advance()
-	}
+	p.expectPeek(lexer.SemiColTokenKind)
 
 	return statement
 }
 
+// expression_statement = expression [ "=" expression ] ";" .
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	expression := p.parseExpression(Lowest)
+	if expression == nil {
+		return nil
+	}
+
+	if p.expectPeekNoErr(lexer.AssignOpTokenKind) {
+		p.advance() // '='
+
+		value := p.parseExpression(Lowest)
+		if value == nil || !p.expectPeek(lexer.SemiColTokenKind) {
+			return nil
+		}
+
+		return &ast.AssignStatement{Target: expression, Value: value,
+			EndLocation: p.currentToken.Location.EndLocation}
+	}
 
 	if p.peekTokenIs(lexer.SemiColTokenKind) {
 		p.advance()
@@ -504,17 +1109,126 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 	return expression
 }
 
-// func (p *Parser) parseVarStatement() ast.Statement {}
+// var_statement = "var" identifier [ ":" type ] [ "=" expression ] ";" .
+func (p *Parser) parseVarStatement() ast.Statement {
+	statement := &ast.VarStatement{StartLocation: p.currentToken.Location.StartLocation}
+
+	if !p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	statement.Name = &ast.Name{TokenLocation: p.currentToken.Location.Copy(),
+		Name: p.currentToken.Literal}
+
+	if p.expectPeekNoErr(lexer.ColonTokenKind) {
+		p.advance() // ':'
+
+		statement.Type = p.parseType()
+		if statement.Type == nil {
+			return nil
+		}
+	}
+
+	if p.expectPeekNoErr(lexer.AssignOpTokenKind) {
+		p.advance() // '='
+
+		statement.Value = p.parseExpression(Lowest)
+		if statement.Value == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(lexer.SemiColTokenKind) {
+		return nil
+	}
+
+	statement.EndLocation = p.currentToken.Location.EndLocation
+	return statement
+}
+
+// destroy_statement = "destroy" expression ";" .
+func (p *Parser) parseDestroyStatement() ast.Statement {
+	statement := &ast.DestroyStatement{StartLocation: p.currentToken.Location.StartLocation}
+
+	p.advance() // 'destroy'
+
+	statement.Value = p.parseExpression(Lowest)
+	if statement.Value == nil || !p.expectPeek(lexer.SemiColTokenKind) {
+		return nil
+	}
+
+	statement.EndLocation = p.currentToken.Location.EndLocation
+	return statement
+}
+
+// fail_statement = "fail" expression ";" .
+func (p *Parser) parseFailStatement() ast.Statement {
+	statement := &ast.FailStatement{StartLocation: p.currentToken.Location.StartLocation}
+
+	p.advance() // 'fail'
+
+	statement.Error = p.parseExpression(Lowest)
+	if statement.Error == nil || !p.expectPeek(lexer.SemiColTokenKind) {
+		return nil
+	}
+
+	statement.EndLocation = p.currentToken.Location.EndLocation
+	return statement
+}
+
+// if_statement = "if" expression block [ "else" ( if_statement | block ) ] .
+func (p *Parser) parseIfStatement() ast.Statement {
+	statement := &ast.IfStatement{StartLocation: p.currentToken.Location.StartLocation}
+
+	p.advance() // 'if'
+
+	statement.Condition = p.parseExpression(Lowest)
+	if statement.Condition == nil || !p.expectPeek(lexer.OpenBraceTokenKind) {
+		return nil
+	}
+
+	statement.Consequence = p.parseStatementsBlock()
+	if statement.Consequence == nil {
+		return nil
+	}
+
+	if !p.expectPeekNoErr(lexer.ElseKeywordTokenKind) {
+		return statement
+	}
+
+	if p.expectPeekNoErr(lexer.IfKeywordTokenKind) {
+		statement.Alternative = p.parseIfStatement()
+		if statement.Alternative == nil {
+			return nil
+		}
+
+		return statement
+	}
+
+	if !p.expectPeek(lexer.OpenBraceTokenKind) {
+		return nil
+	}
+
+	alternative := p.parseStatementsBlock()
+	if alternative == nil {
+		return nil
+	}
+
+	statement.Alternative = alternative
+	return statement
+}
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFunctions[p.currentToken.Kind]
 	if prefix == nil {
+		p.addUnexpectedCurrentTokenError()
 		return nil
 	}
 
 	leftExpression := prefix()
 
-	if !p.peekTokenIs(lexer.SemiColTokenKind) && precedence < p.peekPrecedence() {
+	for leftExpression != nil && !p.peekTokenIs(lexer.SemiColTokenKind) &&
+		precedence < p.peekPrecedence() {
 		infix := p.infixParseFunctions[p.peekToken.Kind]
 		if infix == nil {
 			return leftExpression
@@ -536,6 +1250,11 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	p.advance()
 	expression.Expression = p.parseExpression(Prefix)
 
+	// location of the expression can't be known without its operand
+	if expression.Expression == nil {
+		return nil
+	}
+
 	return expression
 }
 
@@ -548,6 +1267,11 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.currentPrecedence()
 	p.advance()
 	expression.Right = p.parseExpression(precedence)
+
+	if expression.Right == nil {
+		return nil
+	}
+
 	return expression
 }
 
@@ -563,6 +1287,8 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	expression.EndLocation = p.currentToken.Location.EndLocation
+
 	return expression
 }
 
@@ -585,6 +1311,9 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 
 	array := &ast.ArrayLiteral{}
 	array.Elements, endLocation = p.parseExpressionList(lexer.CloseBracketTokenKind)
+	if endLocation == nil {
+		return nil
+	}
 
 	array.BlockLocation = &utils.CodeBlockLocation{
 		StartLocation: startLocation,
@@ -594,6 +1323,19 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	return array
 }
 
+func (p *Parser) parseName() ast.Expression {
+	return &ast.Name{
+		TokenLocation: p.currentToken.Location.Copy(),
+		Name:          p.currentToken.Literal}
+}
+
+func (p *Parser) parseNumberLiteral() ast.Expression {
+	return &ast.NumberLiteral{
+		TokenLocation: p.currentToken.Location.Copy(),
+		Kind:          p.currentToken.Kind,
+		Value:         p.currentToken.Literal}
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{
 		TokenLocation: p.currentToken.Location.Copy(),
@@ -606,13 +1348,92 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 		Value:         p.currentToken.Literal}
 }
 
+// member_expression = expression "." ( identifier | "destroy" ) .
 func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
-	return nil
+	// destructor is called like other methods: `this.parent.destroy()`
+	if !p.expectPeekNoErr(lexer.DestroyKeywordTokenKind) &&
+		!p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	return &ast.MemberExpression{Left: left,
+		Member:         p.currentToken.Literal,
+		MemberLocation: p.currentToken.Location.Copy()}
+}
+
+// new_expression = "new" custom_type "(" [ expression { "," expression } ] ")" .
+func (p *Parser) parseNewExpression() ast.Expression {
+	expression := &ast.NewExpression{StartLocation: p.currentToken.Location.StartLocation.Copy()}
+
+	if !p.expectPeek(lexer.IdentifierTokenKind) {
+		return nil
+	}
+
+	t := p.parseCustomType()
+	if t == nil {
+		return nil
+	}
+
+	expression.Type = t.(*ast.CustomType)
+
+	if !p.expectPeek(lexer.OpenParentTokenKind) {
+		return nil
+	}
+
+	expression.Arguments, expression.EndLocation = p.parseExpressionList(lexer.CloseParentTokenKind)
+	if expression.EndLocation == nil {
+		return nil
+	}
+
+	return expression
 }
 
 func (p *Parser) parseFunctionCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Function: function}
 	expression.Arguments, expression.EndLocation = p.parseExpressionList(lexer.CloseParentTokenKind)
+	if expression.EndLocation == nil {
+		return nil
+	}
+
+	return expression
+}
+
+// handle_expression = call_expression "handle" [ [ identifier ] block ] .
+func (p *Parser) parseHandleExpression(call ast.Expression) ast.Expression {
+	expression := &ast.HandleExpression{HandleLocation: p.currentToken.Location.Copy()}
+
+	if p.expectPeekNoErr(lexer.IdentifierTokenKind) {
+		expression.ErrorName = &ast.Name{TokenLocation: p.currentToken.Location.Copy(),
+			Name: p.currentToken.Literal}
+
+		if !p.expectPeek(lexer.OpenBraceTokenKind) {
+			return nil
+		}
+	} else if !p.expectPeekNoErr(lexer.OpenBraceTokenKind) {
+		// default handler
+		return p.handledCall(expression, call)
+	}
+
+	expression.Handler = p.parseStatementsBlock()
+	if expression.Handler == nil {
+		return nil
+	}
+
+	return p.handledCall(expression, call)
+}
+
+// handledCall sets call of the handle expression and reports a problem if the
+// handled expression is not a call.
+func (p *Parser) handledCall(expression *ast.HandleExpression, call ast.Expression) ast.Expression {
+	c, ok := call.(*ast.CallExpression)
+	if !ok {
+		p.problem_handler.AddCodeProblem(utils.NewLocalError(
+			call.Location(), utils.HandleNotCallErr).
+			WithLabel(expression.HandleLocation, "handler is declared here"))
+		return nil
+	}
+
+	expression.Call = c
 	return expression
 }
 
@@ -677,11 +1498,20 @@ func (p *Parser) expectPeek(tokenKind int) bool {
 		p.advance()
 		return true
 	} else {
-		p.problem_handler.AddCodeProblem(utils.NewLocalError(p.peekToken.Location.Copy(),
+		problem := utils.NewLocalError(p.peekToken.Location.Copy(),
 			utils.UnexpectedTokenErr,
 
 			lexer.DumpTokenKind(tokenKind),
-			lexer.DumpTokenKind(p.peekToken.Kind)))
+			lexer.DumpTokenKind(p.peekToken.Kind))
+
+		// semicolon is usually forgotten at the end of the line, so it is
+		// inserted right after the last token
+		if tokenKind == lexer.SemiColTokenKind {
+			problem.WithFix("insert semicolon",
+				utils.NewInsertion(p.currentToken.Location.EndLocation, ";"))
+		}
+
+		p.problem_handler.AddCodeProblem(problem)
 		return false
 	}
 }
@@ -720,5 +1550,17 @@ func (p *Parser) registerInfixFunction(tokenKind int, function infixParseFunctio
 
 func (p *Parser) advance() {
 	p.currentToken = p.peekToken
-	p.peekToken = p.lexer.NextToken()
+
+	if p.pendingToken != nil {
+		p.peekToken = p.pendingToken
+		p.pendingToken = nil
+		return
+	}
+
+	p.peekToken = p.nextToken()
+
+	// comments are not a part of syntax tree
+	for p.peekToken.Kind == lexer.CommentTokenKind {
+		p.peekToken = p.nextToken()
+	}
 }
@@ -70,6 +70,165 @@ func TestReturnStatement(t *testing.T) {
 		statement.(*ast.ReturnStatement).ReturnValue.(*ast.StringLiteral).Value)
 }
 
+func TestReturnWithoutSemicolon(t *testing.T) {
+	for _, input := range []string{"return }", "return\n}"} {
+		p := utils.NewCodeProblemHandler()
+		statement := NewParser("", []byte(input), p).parseStatement()
+		assert.False(t, statement.(*ast.ReturnStatement).HasReturnValue, input)
+
+		// semicolon is inserted right after `return`
+		problems := p.Problems()
+		assert.Equal(t, 1, len(problems), input)
+		assert.Equal(t, "insert semicolon", problems[0].Fixes()[0].Message)
+		assert.Equal(t, 6, problems[0].Fixes()[0].Edits[0].Location.StartLocation.Index, input)
+	}
+}
+
+// TestIncompleteExpressions checks that expressions, whose parts can't be
+// parsed, are dropped instead of having no location.
+func TestIncompleteExpressions(t *testing.T) {
+	for _, input := range []string{"-x;", "true + -x;", "return -x;", "[true, x;"} {
+		p := utils.NewCodeProblemHandler()
+		statement := NewParser("", []byte(input), p).parseStatement()
+
+		if returnStatement, ok := statement.(*ast.ReturnStatement); ok {
+			assert.False(t, returnStatement.HasReturnValue, input)
+			assert.NotNil(t, returnStatement.Location(), input)
+			continue
+		}
+
+		assert.Nil(t, statement, input)
+	}
+
+	p := utils.NewCodeProblemHandler()
+	statement := NewParser("", []byte("[true][false];"), p).parseStatement()
+	assert.Equal(t, 13, statement.Location().EndLocation.Index)
+}
+
+func TestStatements(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`fun main() {
+		var me = new bank.Account(14, "Adi");
+		var age: i32;
+		me.age = 15;
+		if me.check(1_000) {
+			destroy me;
+		} else if false {
+			return;
+		} else {
+			me.print();
+		}
+	}`), p)
+	function := parser.parseTopLevelStatement().(*ast.FunctionDeclaration)
+	assert.True(t, p.Ok)
+
+	statements := function.StatementsBlock.Statements
+	assert.Equal(t, 4, len(statements))
+
+	me := statements[0].(*ast.VarStatement)
+	assert.Equal(t, "me", me.Name.Name)
+	assert.Nil(t, me.Type)
+	assert.Equal(t, "bank.Account", me.Value.(*ast.NewExpression).Type.Name)
+	assert.Equal(t, 2, len(me.Value.(*ast.NewExpression).Arguments))
+
+	age := statements[1].(*ast.VarStatement)
+	assert.Equal(t, lexer.I32KeywordTokenKind, age.Type.(*ast.PrimaryType).Token.Kind)
+	assert.Nil(t, age.Value)
+
+	assign := statements[2].(*ast.AssignStatement)
+	assert.Equal(t, "age", assign.Target.(*ast.MemberExpression).Member)
+	assert.Equal(t, "me", assign.Target.(*ast.MemberExpression).Left.(*ast.Name).Name)
+	assert.Equal(t, "15", assign.Value.(*ast.NumberLiteral).Value)
+
+	statement := statements[3].(*ast.IfStatement)
+	call := statement.Condition.(*ast.CallExpression)
+	assert.Equal(t, "check", call.Function.(*ast.MemberExpression).Member)
+	assert.Equal(t, "1_000", call.Arguments[0].(*ast.NumberLiteral).Value)
+	assert.Equal(t, "me",
+		statement.Consequence.Statements[0].(*ast.DestroyStatement).Value.(*ast.Name).Name)
+
+	elseIf := statement.Alternative.(*ast.IfStatement)
+	assert.False(t, elseIf.Condition.(*ast.BooleanLiteral).Value)
+	assert.Equal(t, 1, len(elseIf.Alternative.(*ast.StatementsBlock).Statements))
+	assert.Equal(t, 11, statement.Location().EndLocation.Line)
+}
+
+func TestMemberCalls(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("this.parent.destroy() == a + b.c;"), p)
+	statement := parser.parseStatement().(*ast.InfixExpression)
+	assert.True(t, p.Ok)
+
+	call := statement.Left.(*ast.CallExpression)
+	assert.Equal(t, "destroy", call.Function.(*ast.MemberExpression).Member)
+	assert.Equal(t, "parent",
+		call.Function.(*ast.MemberExpression).Left.(*ast.MemberExpression).Member)
+
+	sum := statement.Right.(*ast.InfixExpression)
+	assert.Equal(t, "+", sum.Operator)
+	assert.Equal(t, "c", sum.Right.(*ast.MemberExpression).Member)
+}
+
+func TestFailableFunction(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`fun read(size: u64): u8 fails {
+		var header = io.read(4) handle;
+		var crc = io.crc() + check(header) handle err {
+			fail err;
+		};
+		write(crc) handle {
+			return 0;
+		}
+	}`), p)
+	function := parser.parseTopLevelStatement().(*ast.FunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.True(t, function.Failable)
+
+	statements := function.StatementsBlock.Statements
+	assert.Equal(t, 3, len(statements))
+
+	header := statements[0].(*ast.VarStatement).Value.(*ast.HandleExpression)
+	assert.Equal(t, "read", header.Call.Function.(*ast.MemberExpression).Member)
+	assert.Nil(t, header.ErrorName)
+	assert.Nil(t, header.Handler)
+
+	// handler binds to the nearest call
+	crc := statements[1].(*ast.VarStatement).Value.(*ast.InfixExpression)
+	check := crc.Right.(*ast.HandleExpression)
+	assert.Equal(t, "check", check.Call.Function.(*ast.Name).Name)
+	assert.Equal(t, "err", check.ErrorName.Name)
+	assert.Equal(t, "err",
+		check.Handler.Statements[0].(*ast.FailStatement).Error.(*ast.Name).Name)
+
+	write := statements[2].(*ast.HandleExpression)
+	assert.Nil(t, write.ErrorName)
+	assert.Equal(t, 1, len(write.Handler.Statements))
+	assert.Equal(t, 8, write.Location().EndLocation.Line)
+}
+
+func TestFailableSignatures(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`interface Reader {
+		fun read(): u8 fails;
+		fun close() fails;
+	}`), p)
+	reader := parser.parseTopLevelStatement().(*ast.InterfaceDeclaration)
+	assert.True(t, p.Ok)
+	assert.True(t, reader.Methods[0].Failable)
+	assert.True(t, reader.Methods[1].Failable)
+	assert.Nil(t, reader.Methods[1].ReturnType)
+
+	p = utils.NewCodeProblemHandler()
+	parser = NewParser("", []byte("extern fun getchar(): i32 fails;"), p)
+	parser.parseTopLevelStatement()
+	assert.False(t, p.Ok)
+
+	p = utils.NewCodeProblemHandler()
+	parser = NewParser("", []byte("(a + b) handle;"), p)
+	parser.parseStatement()
+	assert.False(t, p.Ok)
+}
+
 func TestPrimaryType(t *testing.T) {
 	p := utils.NewCodeProblemHandler()
 	parser := NewParser("", []byte("i32"), p)
@@ -132,3 +291,214 @@ func TestCustomType2(t *testing.T) {
 		tp.(*ast.CustomType).Name)
 	p.PrintProblems()
 }
+
+func TestFunctionDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("pub fun max(a: f64, b: mylib.f64) { return a; }"), p)
+	function := parser.parseTopLevelStatement().(*ast.FunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, true, function.Public)
+	assert.Equal(t, "max", function.Name)
+	assert.Equal(t, 2, len(function.Arguments))
+	assert.Equal(t, "a", function.Arguments[0].Name)
+	assert.Equal(t, "mylib.f64", function.Arguments[1].Type.(*ast.CustomType).Name)
+	assert.Equal(t, lexer.CloseBraceTokenKind, parser.currentToken.Kind)
+}
+
+func TestStructureDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`pub struct Account {
+		pub readonly age: i32;
+		password: *u8;
+
+		pub init(age: i32, password: *u8) {}
+		pub destroy() {}
+		fun check(password: *u8) {}
+	}`), p)
+	structure := parser.parseTopLevelStatement().(*ast.StructureDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, true, structure.Public)
+	assert.Equal(t, "Account", structure.Name)
+
+	assert.Equal(t, 2, len(structure.Members))
+	assert.Equal(t, true, structure.Members[0].Public)
+	assert.Equal(t, true, structure.Members[0].Readonly)
+	assert.Equal(t, "age", structure.Members[0].Name)
+	assert.Equal(t, false, structure.Members[1].Public)
+	assert.Equal(t, false, structure.Members[1].Readonly)
+	assert.Equal(t, lexer.U8KeywordTokenKind,
+		structure.Members[1].Type.(*ast.PointerType).Type.(*ast.PrimaryType).Token.Kind)
+
+	assert.Equal(t, 3, len(structure.Methods))
+	assert.Equal(t, "init", structure.Methods[0].Name)
+	assert.Equal(t, 2, len(structure.Methods[0].Arguments))
+	assert.Equal(t, "destroy", structure.Methods[1].Name)
+	assert.Equal(t, "check", structure.Methods[2].Name)
+	assert.Equal(t, false, structure.Methods[2].Public)
+}
+
+func TestAttributes(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`struct Node {
+		@nodestroy pub parent: *Node;
+		children: []*Node;
+	}`), p)
+	structure := parser.parseTopLevelStatement().(*ast.StructureDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 2, len(structure.Members))
+	assert.Equal(t, true, structure.Members[0].NoDestroy)
+	assert.Equal(t, true, structure.Members[0].Public)
+	assert.Equal(t, false, structure.Members[1].NoDestroy)
+
+	p = utils.NewCodeProblemHandler()
+	parser = NewParser("", []byte(`struct Node {
+		@weak parent: *Node;
+		@nodestroy fun get(): *Node {}
+	}`), p)
+	structure = parser.parseTopLevelStatement().(*ast.StructureDeclaration)
+	assert.False(t, p.Ok)
+	assert.Equal(t, 2, len(p.Problems()))
+	assert.Equal(t, 1, len(structure.Members))
+	assert.Equal(t, 1, len(structure.Methods))
+}
+
+func TestFunctionReturnType(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("fun max(a: f64, b: f64): *f64 {}"), p)
+	function := parser.parseTopLevelStatement().(*ast.FunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, "f64",
+		function.ReturnType.(*ast.PointerType).Type.(*ast.CustomType).Name)
+}
+
+func TestInterfaceDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`pub interface Reader {
+		fun read(buffer: *u8, size: u64): u64;
+		fun close();
+	}`), p)
+	declaration := parser.parseTopLevelStatement().(*ast.InterfaceDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, true, declaration.Public)
+	assert.Equal(t, "Reader", declaration.Name)
+	assert.Equal(t, 2, len(declaration.Methods))
+	assert.Equal(t, "read", declaration.Methods[0].Name)
+	assert.Equal(t, 2, len(declaration.Methods[0].Arguments))
+	assert.Equal(t, lexer.U64KeywordTokenKind,
+		declaration.Methods[0].ReturnType.(*ast.PrimaryType).Token.Kind)
+	assert.Equal(t, "close", declaration.Methods[1].Name)
+	assert.Nil(t, declaration.Methods[1].ReturnType)
+}
+
+func TestStructureImplements(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("struct File : Reader, io.Writer {}"), p)
+	structure := parser.parseTopLevelStatement().(*ast.StructureDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 2, len(structure.Implements))
+	assert.Equal(t, "Reader", structure.Implements[0].Name)
+	assert.Equal(t, "io.Writer", structure.Implements[1].Name)
+}
+
+func TestGenericFunctionDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("fun max<T: Comparable, U>(a: T, b: T): T {}"), p)
+	function := parser.parseTopLevelStatement().(*ast.FunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 2, len(function.TypeParameters))
+	assert.Equal(t, "T", function.TypeParameters[0].Name)
+	assert.Equal(t, "Comparable", function.TypeParameters[0].Constraint.Name)
+	assert.Equal(t, "U", function.TypeParameters[1].Name)
+	assert.Nil(t, function.TypeParameters[1].Constraint)
+	assert.Equal(t, "T", function.ReturnType.(*ast.CustomType).Name)
+}
+
+func TestGenericStructureDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("struct Vec<T> : Container<T> { items: []T; }"), p)
+	structure := parser.parseTopLevelStatement().(*ast.StructureDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, "T", structure.TypeParameters[0].Name)
+	assert.Equal(t, "T", structure.Implements[0].Arguments[0].(*ast.CustomType).Name)
+	assert.Equal(t, 1, len(structure.Members))
+}
+
+func TestGenericInterfaceDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`interface Container<T, K: Comparable> {
+		fun get(key: K): T;
+	}`), p)
+	declaration := parser.parseTopLevelStatement().(*ast.InterfaceDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, 2, len(declaration.TypeParameters))
+	assert.Equal(t, "T", declaration.TypeParameters[0].Name)
+	assert.Equal(t, "Comparable", declaration.TypeParameters[1].Constraint.Name)
+	assert.Equal(t, "get", declaration.Methods[0].Name)
+}
+
+func TestGenericCustomType(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte("collections.Map<*u8, Vec<Vec<i32>>>;"), p)
+	tp := parser.parseType().(*ast.CustomType)
+	assert.True(t, p.Ok)
+	assert.Equal(t, "collections.Map", tp.Name)
+	assert.Equal(t, 2, len(tp.Arguments))
+
+	inner := tp.Arguments[1].(*ast.CustomType).Arguments[0].(*ast.CustomType)
+	assert.Equal(t, "Vec", inner.Name)
+	assert.Equal(t, lexer.I32KeywordTokenKind,
+		inner.Arguments[0].(*ast.PrimaryType).Token.Kind)
+
+	assert.Equal(t, lexer.GTOpTokenKind, parser.currentToken.Kind)
+	assert.Equal(t, lexer.SemiColTokenKind, parser.peekToken.Kind)
+}
+
+func TestExternFunctionDeclaration(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`extern fun printf(fmt: *u8, ...): i32;`), p)
+	declaration := parser.parseTopLevelStatement().(*ast.ExternFunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, false, declaration.Public)
+	assert.Equal(t, "printf", declaration.Name)
+	assert.Equal(t, 1, len(declaration.Arguments))
+	assert.Equal(t, true, declaration.Variadic)
+	assert.Equal(t, lexer.I32KeywordTokenKind,
+		declaration.ReturnType.(*ast.PrimaryType).Token.Kind)
+
+	parser = NewParser("", []byte(`pub extern fun HAL_Delay(delay: u32);`), p)
+	declaration = parser.parseTopLevelStatement().(*ast.ExternFunctionDeclaration)
+	assert.True(t, p.Ok)
+	assert.Equal(t, true, declaration.Public)
+	assert.Equal(t, false, declaration.Variadic)
+	assert.Nil(t, declaration.ReturnType)
+}
+
+func TestVariadicFunctionIsNotExtern(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`fun log(format: *u8, ...) {}`), p)
+	parser.parseTopLevelStatement()
+	assert.False(t, p.Ok)
+
+	p = utils.NewCodeProblemHandler()
+	parser = NewParser("", []byte(`extern fun printf(..., fmt: *u8);`), p)
+	parser.parseTopLevelStatement()
+	assert.False(t, p.Ok)
+
+	p = utils.NewCodeProblemHandler()
+	parser = NewParser("", []byte(`extern fun max<T>(a: T, b: T): T;`), p)
+	parser.parseTopLevelStatement()
+	assert.False(t, p.Ok)
+}
+
+func TestComments(t *testing.T) {
+	p := utils.NewCodeProblemHandler()
+	parser := NewParser("", []byte(`// Code generated by tinyc bindgen. DO NOT EDIT.
+namespace "hal"; /* HAL bindings */
+
+// delay in milliseconds
+pub extern fun HAL_Delay(delay: u32);`), p)
+	unit := parser.ParseProgramUnit()
+	assert.True(t, p.Ok)
+	assert.Equal(t, "hal", unit.Namespace.Name)
+	assert.Equal(t, 1, len(unit.TLStatements))
+}
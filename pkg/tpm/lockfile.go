@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// LockfileFilename is the name of the file with resolved dependencies of a
+// Tiny project.
+const LockfileFilename = "tiny.lock"
+
+const lockfileHeader = "# This file is generated by tpm. Do not edit it manually.\n\n"
+
+// LockedPackage is a resolved dependency.
+type LockedPackage struct {
+	Name    string
+	Version string
+
+	// Checksum of the package directory, see HashDirectory.
+	Hash string
+
+	// Names of direct dependencies of the package.
+	Dependencies []string
+}
+
+// Lockfile lists every package the project depends on, directly or
+// indirectly, with exact versions and checksums:
+//
+//	[[package]]
+//	name = "util"
+//	version = "1.2.0"
+//	hash = "sha256:..."
+//	dependencies = []
+type Lockfile struct {
+	// Packages sorted by name.
+	Packages []*LockedPackage
+}
+
+func ParseLockfile(data []byte) (*Lockfile, error) {
+	tables, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	lockfile := &Lockfile{Packages: []*LockedPackage{}}
+
+	for _, table := range tables {
+		if table.name == "" && len(table.keys) == 0 {
+			continue
+		}
+
+		if table.name != "package" || !table.array {
+			return nil, fmt.Errorf("line %d: unknown table %s", table.line, table.name)
+		}
+
+		locked := &LockedPackage{Dependencies: []string{}}
+
+		for _, key := range table.keys {
+			if key == "dependencies" {
+				value := table.values[key]
+				if !value.isList {
+					return nil, fmt.Errorf("line %d: dependencies must be a list", table.line)
+				}
+
+				locked.Dependencies = value.list
+				continue
+			}
+
+			value, _, err := table.getString(key)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key {
+			case "name":
+				locked.Name = value
+			case "version":
+				locked.Version = value
+			case "hash":
+				locked.Hash = value
+			default:
+				return nil, fmt.Errorf("line %d: unknown package field %s", table.line, key)
+			}
+		}
+
+		if locked.Name == "" || locked.Version == "" || locked.Hash == "" {
+			return nil, fmt.Errorf("line %d: package must have name, version and hash",
+				table.line)
+		}
+
+		if lockfile.Find(locked.Name) != nil {
+			return nil, fmt.Errorf("line %d: package %s is locked twice", table.line, locked.Name)
+		}
+
+		lockfile.Packages = append(lockfile.Packages, locked)
+	}
+
+	lockfile.sort()
+	return lockfile, nil
+}
+
+func ReadLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lockfile, err := ParseLockfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return lockfile, nil
+}
+
+func (l *Lockfile) sort() {
+	sort.Slice(l.Packages, func(i, j int) bool {
+		return l.Packages[i].Name < l.Packages[j].Name
+	})
+
+	for _, locked := range l.Packages {
+		sort.Strings(locked.Dependencies)
+	}
+}
+
+// Encode returns lock file in TOML format. Output depends only on the
+// resolved packages, so the same resolution always produces the same file.
+func (l *Lockfile) Encode() []byte {
+	l.sort()
+
+	tables := []*tomlTable{}
+	for _, locked := range l.Packages {
+		table := newTOMLTable("package", true, 0)
+		table.setString("name", locked.Name)
+		table.setString("version", locked.Version)
+		table.setString("hash", locked.Hash)
+		table.setList("dependencies", locked.Dependencies)
+
+		tables = append(tables, table)
+	}
+
+	return append([]byte(lockfileHeader), encodeTOML(tables)...)
+}
+
+func (l *Lockfile) Write(path string) error {
+	return os.WriteFile(path, l.Encode(), 0644)
+}
+
+// Find returns locked package with the given name or nil.
+func (l *Lockfile) Find(name string) *LockedPackage {
+	for _, locked := range l.Packages {
+		if locked.Name == name {
+			return locked
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package tpm implements the Tiny package manager: project manifests, lock
+// files, a filesystem-backed package registry and dependency resolution.
+package tpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFilename is the name of the file describing a Tiny project.
+const ManifestFilename = "tiny.toml"
+
+// Manifest describes a Tiny package and its dependencies:
+//
+//	[package]
+//	name = "bank"
+//	version = "0.1.0"
+//
+//	[dependencies]
+//	util = "^1.2.0"
+//
+// See Constraint for syntax of dependency versions.
+type Manifest struct {
+	Name    string
+	Version string
+
+	// Version constraints of dependencies by their names.
+	Dependencies map[string]string
+
+	// Absolute path of the directory with the manifest file, empty if the
+	// manifest wasn't read from a file.
+	Directory string
+}
+
+func NewManifest(name string, version string) *Manifest {
+	return &Manifest{Name: name, Version: version, Dependencies: map[string]string{}}
+}
+
+func ParseManifest(data []byte) (*Manifest, error) {
+	tables, err := parseTOML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Dependencies: map[string]string{}}
+	hasPackage := false
+
+	for _, table := range tables {
+		switch {
+		case table.name == "" && len(table.keys) == 0:
+		case table.name == "package" && !table.array:
+			hasPackage = true
+
+			for _, key := range table.keys {
+				value, _, err := table.getString(key)
+				if err != nil {
+					return nil, err
+				}
+
+				switch key {
+				case "name":
+					manifest.Name = value
+				case "version":
+					manifest.Version = value
+				default:
+					return nil, fmt.Errorf("line %d: unknown package field %s", table.line, key)
+				}
+			}
+		case table.name == "dependencies" && !table.array:
+			for _, key := range table.keys {
+				value, _, err := table.getString(key)
+				if err != nil {
+					return nil, err
+				}
+
+				manifest.Dependencies[key] = value
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown table %s", table.line, table.name)
+		}
+	}
+
+	if !hasPackage {
+		return nil, fmt.Errorf("missing [package] table")
+	}
+
+	if err := ValidatePackageName(manifest.Name); err != nil {
+		return nil, err
+	}
+
+	if manifest.Version == "" {
+		return nil, fmt.Errorf("missing package version")
+	}
+
+	if _, err := ParseVersion(manifest.Version); err != nil {
+		return nil, err
+	}
+
+	for name, constraint := range manifest.Dependencies {
+		if err := ValidatePackageName(name); err != nil {
+			return nil, err
+		}
+
+		if _, err := ParseConstraint(constraint); err != nil {
+			return nil, fmt.Errorf("dependency %s: %s", name, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	manifest.Directory, err = filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Encode returns manifest in TOML format. Dependencies are sorted by name.
+func (m *Manifest) Encode() []byte {
+	pkg := newTOMLTable("package", false, 0)
+	pkg.setString("name", m.Name)
+	pkg.setString("version", m.Version)
+
+	dependencies := newTOMLTable("dependencies", false, 0)
+	for _, name := range m.DependencyNames() {
+		dependencies.setString(name, m.Dependencies[name])
+	}
+
+	return encodeTOML([]*tomlTable{pkg, dependencies})
+}
+
+func (m *Manifest) Write(path string) error {
+	return os.WriteFile(path, m.Encode(), 0644)
+}
+
+// DependencyNames returns sorted names of dependencies.
+func (m *Manifest) DependencyNames() []string {
+	names := make([]string, 0, len(m.Dependencies))
+	for name := range m.Dependencies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ValidatePackageName checks that name can be used both as a directory name
+// in the registry and as an import path.
+func ValidatePackageName(name string) error {
+	if name == "" {
+		return fmt.Errorf("package name is empty")
+	}
+
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			return fmt.Errorf("invalid package name %q: only lowercase letters, digits, "+
+				"'_' and '-' are allowed", name)
+		}
+	}
+
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid package name %q: name can't start with '-'", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Registry is a directory with published packages. Every version of a
+// package is stored in its own directory: `<root>/<name>/<version>/`, which
+// contains package sources and its manifest.
+type Registry struct {
+	Root string
+}
+
+func NewRegistry(root string) *Registry {
+	return &Registry{Root: root}
+}
+
+// Versions returns published versions of the package from the oldest to the
+// newest.
+func (r *Registry) Versions(name string) ([]*Version, error) {
+	entries, err := os.ReadDir(filepath.Join(r.Root, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("package %s is not found in registry %s", name, r.Root)
+	} else if err != nil {
+		return nil, err
+	}
+
+	versions := []*Version{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// directories, which are not named by versions, are ignored
+		version, err := ParseVersion(entry.Name())
+		if err == nil && version.String() == entry.Name() {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) < 0
+	})
+
+	return versions, nil
+}
+
+// Directory returns path of the directory with sources of the package
+// version.
+func (r *Registry) Directory(name string, version string) string {
+	return filepath.Join(r.Root, name, version)
+}
+
+// Manifest returns manifest of the published package version. Package without
+// manifest has no dependencies.
+func (r *Registry) Manifest(name string, version string) (*Manifest, error) {
+	directory := r.Directory(name, version)
+
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("package %s@%s is not found in registry %s",
+			name, version, r.Root)
+	}
+
+	manifest, err := ReadManifest(filepath.Join(directory, ManifestFilename))
+	if os.IsNotExist(err) {
+		return NewManifest(name, version), nil
+	}
+
+	return manifest, err
+}
+
+// HashDirectory returns checksum of all regular files in the directory, which
+// depends on their contents and paths relative to the directory.
+func HashDirectory(directory string) (string, error) {
+	paths := []string{}
+
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(paths)
+
+	h := sha256.New()
+	for _, path := range paths {
+		relative, err := filepath.Rel(directory, path)
+		if err != nil {
+			return "", err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s\x00%x\n", filepath.ToSlash(relative), fileHash.Sum(nil))
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,359 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type requirement struct {
+	constraint *Constraint
+
+	// packages from the project to the dependent, which requires the
+	// package, for example ["bank", "json@0.3.1"]
+	path []string
+}
+
+func (r *requirement) describe(name string) string {
+	return fmt.Sprintf("%s requires %s %s", strings.Join(r.path, " -> "), name, r.constraint)
+}
+
+type resolver struct {
+	registry *Registry
+
+	// versions from the previous lock file by package names, they are
+	// preferred over newer ones to keep resolution reproducible
+	locked map[string]string
+
+	versions  map[string][]*Version
+	manifests map[string]*Manifest
+}
+
+// Resolve finds versions of every package the project depends on, directly or
+// indirectly, so that all version constraints are satisfied. Each package can
+// be used only in one version. The newest matching versions are chosen,
+// except for packages locked in the previous lock file (it can be nil), which
+// keep their versions as long as they match.
+//
+// If there is no solution, returned error lists the requirements, which
+// can't be satisfied together, with the chains of dependents leading to them.
+func Resolve(manifest *Manifest, registry *Registry, previous *Lockfile) (*Lockfile, error) {
+	r := &resolver{
+		registry:  registry,
+		locked:    map[string]string{},
+		versions:  map[string][]*Version{},
+		manifests: map[string]*Manifest{},
+	}
+
+	if previous != nil {
+		for _, locked := range previous.Packages {
+			r.locked[locked.Name] = locked.Version
+		}
+	}
+
+	requirements, err := r.addRequirements(map[string][]*requirement{},
+		manifest, []string{manifest.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := r.solve(map[string]*Version{}, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	lockfile := &Lockfile{Packages: []*LockedPackage{}}
+	for name, version := range selected {
+		dependencyManifest, err := r.manifest(name, version)
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := HashDirectory(registry.Directory(name, version.String()))
+		if err != nil {
+			return nil, err
+		}
+
+		lockfile.Packages = append(lockfile.Packages, &LockedPackage{
+			Name:         name,
+			Version:      version.String(),
+			Hash:         hash,
+			Dependencies: dependencyManifest.DependencyNames(),
+		})
+	}
+
+	lockfile.sort()
+	return lockfile, nil
+}
+
+// solve selects versions of required packages one by one in order of their
+// names, backtracking if selected version leads to a conflict.
+func (r *resolver) solve(selected map[string]*Version,
+	requirements map[string][]*requirement) (map[string]*Version, error) {
+	name := ""
+	for _, required := range sortedKeys(requirements) {
+		if _, ok := selected[required]; !ok {
+			name = required
+			break
+		}
+	}
+
+	if name == "" {
+		return selected, nil
+	}
+
+	candidates, err := r.candidates(name, requirements[name])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, r.conflict(name, requirements[name], nil)
+	}
+
+	var firstErr error
+
+	for _, candidate := range candidates {
+		manifest, err := r.manifest(name, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		path := append(append([]string{}, requirements[name][0].path...),
+			name+"@"+candidate.String())
+
+		newRequirements, err := r.addRequirements(requirements, manifest, path)
+		if err != nil {
+			return nil, err
+		}
+
+		newSelected := map[string]*Version{name: candidate}
+		for selectedName, version := range selected {
+			newSelected[selectedName] = version
+		}
+
+		err = r.verify(newSelected, newRequirements)
+		if err == nil {
+			var result map[string]*Version
+			if result, err = r.solve(newSelected, newRequirements); err == nil {
+				return result, nil
+			}
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// verify checks that already selected versions satisfy new requirements.
+func (r *resolver) verify(selected map[string]*Version,
+	requirements map[string][]*requirement) error {
+	for _, name := range sortedKeys(requirements) {
+		version, ok := selected[name]
+		if !ok {
+			continue
+		}
+
+		for _, requirement := range requirements[name] {
+			if !requirement.constraint.Matches(version) {
+				return r.conflict(name, requirements[name], version)
+			}
+		}
+	}
+
+	return nil
+}
+
+// addRequirements returns copy of requirements with dependencies of the
+// manifest added.
+func (r *resolver) addRequirements(requirements map[string][]*requirement,
+	manifest *Manifest, path []string) (map[string][]*requirement, error) {
+	result := map[string][]*requirement{}
+	for name, list := range requirements {
+		result[name] = list
+	}
+
+	for _, name := range manifest.DependencyNames() {
+		constraint, err := ParseConstraint(manifest.Dependencies[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: dependency %s: %s",
+				strings.Join(path, " -> "), name, err)
+		}
+
+		list := append([]*requirement{}, result[name]...)
+		result[name] = append(list, &requirement{constraint: constraint, path: path})
+	}
+
+	return result, nil
+}
+
+// candidates returns versions of the package matching all requirements in
+// order they should be tried in: the locked version first, then from the
+// newest to the oldest.
+func (r *resolver) candidates(name string, requirements []*requirement) ([]*Version, error) {
+	versions, ok := r.versions[name]
+	if !ok {
+		var err error
+		versions, err = r.registry.Versions(name)
+		if err != nil {
+			return nil, err
+		}
+
+		r.versions[name] = versions
+	}
+
+	candidates := []*Version{}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		matches := true
+		for _, requirement := range requirements {
+			if !requirement.constraint.Matches(versions[i]) {
+				matches = false
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		if versions[i].String() == r.locked[name] {
+			candidates = append([]*Version{versions[i]}, candidates...)
+		} else {
+			candidates = append(candidates, versions[i])
+		}
+	}
+
+	return candidates, nil
+}
+
+func (r *resolver) manifest(name string, version *Version) (*Manifest, error) {
+	key := name + "@" + version.String()
+
+	if manifest, ok := r.manifests[key]; ok {
+		return manifest, nil
+	}
+
+	manifest, err := r.registry.Manifest(name, version.String())
+	if err != nil {
+		return nil, err
+	}
+
+	r.manifests[key] = manifest
+	return manifest, nil
+}
+
+// conflict returns error explaining why no version of the package can be
+// selected. selected is the version chosen before the last requirement was
+// added, nil if none.
+func (r *resolver) conflict(name string, requirements []*requirement,
+	selected *Version) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "no version of %s satisfies all requirements:", name)
+	for _, requirement := range requirements {
+		sb.WriteString("\n\t")
+		sb.WriteString(requirement.describe(name))
+	}
+
+	if selected != nil {
+		fmt.Fprintf(&sb, "\n%s@%s is already selected", name, selected)
+	} else {
+		available := []string{}
+		for _, version := range r.versions[name] {
+			available = append(available, version.String())
+		}
+
+		if len(available) == 0 {
+			fmt.Fprintf(&sb, "\nno versions of %s are published", name)
+		} else {
+			fmt.Fprintf(&sb, "\navailable versions: %s", strings.Join(available, ", "))
+		}
+	}
+
+	return fmt.Errorf("%s", sb.String())
+}
+
+func sortedKeys(requirements map[string][]*requirement) []string {
+	keys := make([]string, 0, len(requirements))
+	for key := range requirements {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Satisfies reports whether lock file contains all dependencies of the
+// manifest in versions matching their constraints together with their own
+// dependencies and no packages, which are not required anymore.
+func (l *Lockfile) Satisfies(manifest *Manifest) bool {
+	for name, constraint := range manifest.Dependencies {
+		locked := l.Find(name)
+		if locked == nil {
+			return false
+		}
+
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			return false
+		}
+
+		version, err := ParseVersion(locked.Version)
+		if err != nil || !c.Matches(version) {
+			return false
+		}
+	}
+
+	// packages required by the manifest directly or through dependencies
+	required := map[string]bool{}
+	queue := []string{}
+
+	for name := range manifest.Dependencies {
+		required[name] = true
+		queue = append(queue, name)
+	}
+
+	for len(queue) > 0 {
+		locked := l.Find(queue[0])
+		queue = queue[1:]
+
+		if locked == nil {
+			return false
+		}
+
+		for _, name := range locked.Dependencies {
+			if !required[name] {
+				required[name] = true
+				queue = append(queue, name)
+			}
+		}
+	}
+
+	return len(required) == len(l.Packages)
+}
@@ -0,0 +1,257 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version: `MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]`.
+// Build metadata is ignored.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+
+	// dot separated prerelease identifiers, empty for releases
+	Prerelease []string
+}
+
+func ParseVersion(version string) (*Version, error) {
+	if i := strings.Index(version, "+"); i != -1 {
+		version = version[:i]
+	}
+
+	core, prerelease := version, ""
+	if i := strings.Index(version, "-"); i != -1 {
+		core, prerelease = version[:i], version[i+1:]
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version %q: expected MAJOR.MINOR.PATCH", version)
+	}
+
+	numbers := [3]int{}
+	for i, part := range parts {
+		number, err := parseVersionNumber(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q: %s", version, err)
+		}
+
+		numbers[i] = number
+	}
+
+	v := &Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+
+	if i := strings.Index(version, "-"); i != -1 {
+		v.Prerelease = strings.Split(prerelease, ".")
+
+		for _, identifier := range v.Prerelease {
+			if identifier == "" {
+				return nil, fmt.Errorf("invalid version %q: empty prerelease identifier", version)
+			}
+		}
+	}
+
+	return v, nil
+}
+
+func parseVersionNumber(s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty version component")
+	}
+
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("version component %s has leading zero", s)
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("version component %s is not a number", s)
+		}
+	}
+
+	return strconv.Atoi(s)
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+
+	if len(v.Prerelease) != 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+
+	return s
+}
+
+// Compare returns -1, 0 or 1 if version has lower, the same or higher
+// precedence than the other one. Prerelease versions have lower precedence
+// than the release: 1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-beta < 1.0.0.
+func (v *Version) Compare(other *Version) int {
+	if c := compareInts(v.Major, other.Major); c != 0 {
+		return c
+	}
+
+	if c := compareInts(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+
+	if c := compareInts(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := comparePrereleaseIdentifiers(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareInts(len(v.Prerelease), len(other.Prerelease))
+}
+
+// comparePrereleaseIdentifiers compares numeric identifiers numerically and
+// others in ASCII order. Numeric identifiers are lower than alphanumeric ones.
+func comparePrereleaseIdentifiers(a string, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// Constraint is a set of versions, which can be used to satisfy a
+// dependency:
+//
+//	1.2.3, =1.2.3  exactly 1.2.3
+//	^1.2.3         >=1.2.3, <2.0.0 (<0.3.0 for ^0.2.3, <0.0.4 for ^0.0.3)
+//	~1.2.3         >=1.2.3, <1.3.0
+//	*              any release
+//
+// Prerelease versions match only constraints, which mention a prerelease of
+// the same MAJOR.MINOR.PATCH, so ^1.2.3-beta matches 1.2.3-rc.1 and 1.2.4,
+// but not 1.2.4-rc.1.
+type Constraint struct {
+	source string
+
+	// nil for `*`
+	lower *Version
+
+	// exclusive upper bound, nil if only lower bound matches
+	upper *Version
+}
+
+func ParseConstraint(constraint string) (*Constraint, error) {
+	constraint = strings.TrimSpace(constraint)
+
+	if constraint == "*" {
+		return &Constraint{source: constraint}, nil
+	}
+
+	operator, version := "=", constraint
+	if constraint != "" && strings.ContainsRune("=^~", rune(constraint[0])) {
+		operator, version = constraint[:1], strings.TrimSpace(constraint[1:])
+	}
+
+	lower, err := ParseVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %s", constraint, err)
+	}
+
+	c := &Constraint{source: constraint, lower: lower}
+
+	switch operator {
+	case "^":
+		switch {
+		case lower.Major != 0:
+			c.upper = &Version{Major: lower.Major + 1}
+		case lower.Minor != 0:
+			c.upper = &Version{Minor: lower.Minor + 1}
+		default:
+			c.upper = &Version{Patch: lower.Patch + 1}
+		}
+	case "~":
+		c.upper = &Version{Major: lower.Major, Minor: lower.Minor + 1}
+	}
+
+	if c.upper != nil {
+		// the lowest possible version, so that prereleases of the next
+		// version are not matched
+		c.upper.Prerelease = []string{"0"}
+	}
+
+	return c, nil
+}
+
+func (c *Constraint) String() string {
+	return c.source
+}
+
+// Matches reports whether version satisfies the constraint.
+func (c *Constraint) Matches(version *Version) bool {
+	if c.lower == nil {
+		return len(version.Prerelease) == 0
+	}
+
+	if c.upper == nil {
+		return version.Compare(c.lower) == 0
+	}
+
+	if len(version.Prerelease) != 0 && (len(c.lower.Prerelease) == 0 ||
+		version.Major != c.lower.Major || version.Minor != c.lower.Minor ||
+		version.Patch != c.lower.Patch) {
+		return false
+	}
+
+	return version.Compare(c.lower) >= 0 && version.Compare(c.upper) < 0
+}
@@ -0,0 +1,285 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Manifest and lock files use a small subset of TOML: tables (`[name]`),
+// arrays of tables (`[[name]]`), string values and arrays of strings, each
+// on a single line, and `#` comments.
+
+type tomlValue struct {
+	str    string
+	list   []string
+	isList bool
+}
+
+type tomlTable struct {
+	name  string // empty for the root table
+	array bool   // declared with `[[name]]`
+	line  int
+
+	keys   []string // in order of declaration
+	values map[string]*tomlValue
+}
+
+func (t *tomlTable) set(key string, value *tomlValue) {
+	if _, ok := t.values[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+
+	t.values[key] = value
+}
+
+func (t *tomlTable) setString(key string, value string) {
+	t.set(key, &tomlValue{str: value})
+}
+
+func (t *tomlTable) setList(key string, value []string) {
+	t.set(key, &tomlValue{list: value, isList: true})
+}
+
+// getString returns string value of the key, or error if value is a list.
+func (t *tomlTable) getString(key string) (string, bool, error) {
+	value, ok := t.values[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	if value.isList {
+		return "", true, fmt.Errorf("line %d: %s must be a string", t.line, key)
+	}
+
+	return value.str, true, nil
+}
+
+func newTOMLTable(name string, array bool, line int) *tomlTable {
+	return &tomlTable{name: name, array: array, line: line,
+		values: map[string]*tomlValue{}}
+}
+
+func parseTOML(data []byte) ([]*tomlTable, error) {
+	tables := []*tomlTable{newTOMLTable("", false, 0)}
+	declared := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			array := strings.HasPrefix(line, "[[")
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+
+			if name == "" || (array && !strings.HasSuffix(line, "]]")) ||
+				!strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header %s", lineNumber, line)
+			}
+
+			if !array {
+				if declared[name] {
+					return nil, fmt.Errorf("line %d: table %s is declared twice", lineNumber, name)
+				}
+				declared[name] = true
+			}
+
+			tables = append(tables, newTOMLTable(name, array, lineNumber))
+			continue
+		}
+
+		equals := strings.IndexByte(line, '=')
+		if equals < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNumber)
+		}
+
+		key, err := parseTOMLKey(strings.TrimSpace(line[:equals]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(line[equals+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		table := tables[len(tables)-1]
+		if _, ok := table.values[key]; ok {
+			return nil, fmt.Errorf("line %d: key %s is declared twice", lineNumber, key)
+		}
+
+		table.set(key, value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// stripTOMLComment removes comment, which is not inside of a string.
+func stripTOMLComment(line string) string {
+	inString := false
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
+
+func parseTOMLKey(key string) (string, error) {
+	if strings.HasPrefix(key, "\"") {
+		return strconv.Unquote(key)
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+
+	for _, c := range key {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '_' || c == '-') {
+			return "", fmt.Errorf("invalid key %s, use quotes", key)
+		}
+	}
+
+	return key, nil
+}
+
+func parseTOMLValue(value string) (*tomlValue, error) {
+	if strings.HasPrefix(value, "\"") {
+		str, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+
+		return &tomlValue{str: str}, nil
+	}
+
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		list := []string{}
+
+		for _, element := range splitTOMLList(value[1 : len(value)-1]) {
+			element = strings.TrimSpace(element)
+			if element == "" {
+				continue
+			}
+
+			str, err := strconv.Unquote(element)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", element)
+			}
+
+			list = append(list, str)
+		}
+
+		return &tomlValue{list: list, isList: true}, nil
+	}
+
+	return nil, fmt.Errorf("expected string or list of strings, got %s", value)
+}
+
+// splitTOMLList splits list elements by commas, which are not inside of
+// strings.
+func splitTOMLList(list string) []string {
+	elements := []string{}
+	inString := false
+	start := 0
+
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case ',':
+			if !inString {
+				elements = append(elements, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(elements, list[start:])
+}
+
+func encodeTOML(tables []*tomlTable) []byte {
+	var b bytes.Buffer
+
+	for i, table := range tables {
+		if table.name != "" {
+			if i != 0 {
+				b.WriteString("\n")
+			}
+
+			if table.array {
+				fmt.Fprintf(&b, "[[%s]]\n", table.name)
+			} else {
+				fmt.Fprintf(&b, "[%s]\n", table.name)
+			}
+		}
+
+		for _, key := range table.keys {
+			value := table.values[key]
+
+			if _, err := parseTOMLKey(key); err != nil {
+				key = strconv.Quote(key)
+			}
+
+			if value.isList {
+				quoted := make([]string, len(value.list))
+				for j, element := range value.list {
+					quoted[j] = strconv.Quote(element)
+				}
+
+				fmt.Fprintf(&b, "%s = [%s]\n", key, strings.Join(quoted, ", "))
+			} else {
+				fmt.Fprintf(&b, "%s = %s\n", key, strconv.Quote(value.str))
+			}
+		}
+	}
+
+	return b.Bytes()
+}
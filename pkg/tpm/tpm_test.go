@@ -0,0 +1,350 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTree creates files with the given contents in a temporary directory
+// and returns path of the directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+
+	for path, content := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	return root
+}
+
+func TestManifest(t *testing.T) {
+	source := `# bank service
+[package]
+name = "bank"
+version = "0.1.0"
+
+[dependencies]
+util = "1.2.0" # strings and math
+"json" = "0.3.1"
+`
+
+	manifest, err := ParseManifest([]byte(source))
+	assert.NoError(t, err)
+	assert.Equal(t, "bank", manifest.Name)
+	assert.Equal(t, "0.1.0", manifest.Version)
+	assert.Equal(t, map[string]string{"util": "1.2.0", "json": "0.3.1"}, manifest.Dependencies)
+	assert.Equal(t, []string{"json", "util"}, manifest.DependencyNames())
+
+	encoded, err := ParseManifest(manifest.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, manifest, encoded)
+
+	_, err = ParseManifest([]byte("[package]\nversion = \"0.1.0\"\n"))
+	assert.Error(t, err)
+
+	_, err = ParseManifest([]byte("[package]\nname = \"bank\"\nversion = 1\n"))
+	assert.Error(t, err)
+
+	_, err = ParseManifest([]byte("[package]\nname = \"bank\"\nversion = \"0.1\"\n"))
+	assert.Error(t, err)
+
+	_, err = ParseManifest([]byte("[package]\nname = \"bank\"\nversion = \"0.1.0\"\n" +
+		"[dependencies]\nutil = \">1.0.0\"\n"))
+	assert.Error(t, err)
+}
+
+func TestLockfile(t *testing.T) {
+	lockfile := &Lockfile{Packages: []*LockedPackage{
+		{Name: "util", Version: "1.2.0", Hash: "sha256:01", Dependencies: []string{}},
+		{Name: "json", Version: "0.3.1", Hash: "sha256:02", Dependencies: []string{"util"}},
+	}}
+
+	parsed, err := ParseLockfile(lockfile.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(parsed.Packages))
+
+	// packages are sorted by name
+	assert.Equal(t, "json", parsed.Packages[0].Name)
+	assert.Equal(t, []string{"util"}, parsed.Packages[0].Dependencies)
+	assert.Equal(t, "util", parsed.Packages[1].Name)
+	assert.Equal(t, "sha256:01", parsed.Find("util").Hash)
+	assert.Nil(t, parsed.Find("http"))
+
+	lockfile.Packages = append(lockfile.Packages, &LockedPackage{Name: "util",
+		Version: "1.0.0", Hash: "sha256:03", Dependencies: []string{}})
+
+	_, err = ParseLockfile(lockfile.Encode())
+	assert.EqualError(t, err, "line 15: package util is locked twice")
+}
+
+func TestResolve(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"json/0.3.1/tiny.toml": "[package]\nname = \"json\"\nversion = \"0.3.1\"\n\n[dependencies]\nutil = \"1.2.0\"\n",
+		"json/0.3.1/json.tiny": "namespace \"json\";",
+		"util/1.0.0/util.tiny": "namespace \"util\";",
+		"util/1.2.0/util.tiny": "namespace \"util\";\npub fun max() {}",
+	})
+	registry := NewRegistry(root)
+
+	versions, err := registry.Versions("util")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(versions))
+	assert.Equal(t, "1.0.0", versions[0].String())
+	assert.Equal(t, "1.2.0", versions[1].String())
+
+	manifest := NewManifest("bank", "0.1.0")
+	manifest.Dependencies["json"] = "0.3.1"
+
+	lockfile, err := Resolve(manifest, registry, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(lockfile.Packages))
+	assert.Equal(t, []string{"util"}, lockfile.Find("json").Dependencies)
+	assert.Equal(t, "1.2.0", lockfile.Find("util").Version)
+	assert.True(t, lockfile.Satisfies(manifest))
+
+	hash, err := HashDirectory(registry.Directory("util", "1.2.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, hash, lockfile.Find("util").Hash)
+
+	// locked packages, which are not required anymore, are stale
+	stale := NewManifest("bank", "0.1.0")
+	stale.Dependencies["util"] = "1.2.0"
+	assert.False(t, lockfile.Satisfies(stale))
+
+	manifest.Dependencies["util"] = "1.0.0"
+	assert.False(t, lockfile.Satisfies(manifest))
+
+	_, err = Resolve(manifest, registry, nil)
+	assert.EqualError(t, err, `no version of util satisfies all requirements:
+	bank requires util 1.0.0
+	bank -> json@0.3.1 requires util 1.2.0
+available versions: 1.0.0, 1.2.0`)
+
+	manifest.Dependencies["http"] = "1.0.0"
+	_, err = Resolve(manifest, registry, nil)
+	assert.Error(t, err)
+}
+
+func TestVersion(t *testing.T) {
+	ordered := []string{
+		"0.9.0", "1.0.0-0", "1.0.0-2", "1.0.0-10", "1.0.0-alpha", "1.0.0-alpha.1",
+		"1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "1.10.0", "2.0.0",
+	}
+
+	for i := range ordered {
+		a, err := ParseVersion(ordered[i])
+		assert.NoError(t, err)
+		assert.Equal(t, ordered[i], a.String())
+
+		for j := range ordered {
+			b, _ := ParseVersion(ordered[j])
+
+			switch {
+			case i < j:
+				assert.Equal(t, -1, a.Compare(b), "%s < %s", ordered[i], ordered[j])
+			case i > j:
+				assert.Equal(t, 1, a.Compare(b), "%s > %s", ordered[i], ordered[j])
+			default:
+				assert.Equal(t, 0, a.Compare(b))
+			}
+		}
+	}
+
+	build, err := ParseVersion("1.0.0+20220101")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", build.String())
+
+	for _, invalid := range []string{"", "1", "1.0", "1.0.0.0", "01.0.0", "1.x.0", "1.0.0-", "1.0.0-a..b"} {
+		_, err := ParseVersion(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matching   []string
+		other      []string
+	}{
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.2"}},
+		{"^1.2.3", []string{"1.2.3", "1.2.10", "1.9.0"}, []string{"1.2.2", "2.0.0", "2.0.0-rc.1", "1.3.0-rc.1"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"*", []string{"0.0.1", "3.0.0"}, []string{"3.1.0-beta"}},
+		{"^1.2.3-beta", []string{"1.2.3-beta", "1.2.3-rc.1", "1.2.3", "1.2.4"}, []string{"1.2.3-alpha", "1.2.4-rc.1"}},
+		{"1.2.3-beta.2", []string{"1.2.3-beta.2"}, []string{"1.2.3"}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		assert.NoError(t, err)
+
+		for _, version := range test.matching {
+			v, _ := ParseVersion(version)
+			assert.True(t, c.Matches(v), "%s matches %s", test.constraint, version)
+		}
+
+		for _, version := range test.other {
+			v, _ := ParseVersion(version)
+			assert.False(t, c.Matches(v), "%s doesn't match %s", test.constraint, version)
+		}
+	}
+
+	for _, invalid := range []string{"", "^", ">=1.0.0", "~1.2"} {
+		_, err := ParseConstraint(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// packageManifest returns manifest of a registry package with the given
+// dependencies in `name = "constraint"` form.
+func packageManifest(name string, version string, dependencies ...string) string {
+	manifest := "[package]\nname = \"" + name + "\"\nversion = \"" + version + "\"\n\n[dependencies]\n"
+	for _, dependency := range dependencies {
+		manifest += dependency + "\n"
+	}
+
+	return manifest
+}
+
+func TestResolveRanges(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"http/2.0.0/tiny.toml":      packageManifest("http", "2.0.0", `json = "^0.4.0"`, `log = "~1.1.0"`),
+		"http/2.1.0/tiny.toml":      packageManifest("http", "2.1.0", `json = "^0.5.0"`, `log = "~1.1.0"`),
+		"json/0.4.2/tiny.toml":      packageManifest("json", "0.4.2", `util = "^1.0.0"`),
+		"json/0.5.0/tiny.toml":      packageManifest("json", "0.5.0", `util = "^2.0.0"`),
+		"log/1.1.0/log.tiny":        "namespace \"log\";",
+		"log/1.1.4/log.tiny":        "namespace \"log\";",
+		"log/1.2.0/log.tiny":        "namespace \"log\";",
+		"log/1.3.0-beta/log.tiny":   "namespace \"log\";",
+		"util/1.0.0/util.tiny":      "namespace \"util\";",
+		"util/1.4.0/util.tiny":      "namespace \"util\";",
+		"util/2.0.0/util.tiny":      "namespace \"util\";",
+		"util/not-a-version/README": "",
+	})
+	registry := NewRegistry(root)
+
+	manifest := NewManifest("bank", "0.1.0")
+	manifest.Dependencies["http"] = "^2.0.0"
+	manifest.Dependencies["util"] = "^1.0.0"
+	manifest.Dependencies["log"] = "*"
+
+	// http 2.1.0 requires util 2, so the resolver goes back to http 2.0.0
+	lockfile, err := Resolve(manifest, registry, nil)
+	assert.NoError(t, err)
+
+	versions := map[string]string{}
+	for _, locked := range lockfile.Packages {
+		versions[locked.Name] = locked.Version
+	}
+
+	assert.Equal(t, map[string]string{
+		"http": "2.0.0", "json": "0.4.2", "log": "1.1.4", "util": "1.4.0",
+	}, versions)
+	assert.True(t, lockfile.Satisfies(manifest))
+
+	// resolution is reproducible
+	again, err := Resolve(manifest, registry, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, string(lockfile.Encode()), string(again.Encode()))
+
+	// locked versions are kept if they still match
+	lockfile.Find("util").Version = "1.0.0"
+	locked, err := Resolve(manifest, registry, lockfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", locked.Find("util").Version)
+	assert.Equal(t, "2.0.0", locked.Find("http").Version)
+
+	manifest.Dependencies["util"] = "^1.5.0"
+	assert.False(t, locked.Satisfies(manifest))
+
+	// conflict is reported for the newest version of http
+	_, err = Resolve(manifest, registry, nil)
+	assert.EqualError(t, err, `no version of util satisfies all requirements:
+	bank requires util ^1.5.0
+	bank -> http@2.1.0 -> json@0.5.0 requires util ^2.0.0
+available versions: 1.0.0, 1.4.0, 2.0.0`)
+
+	manifest.Dependencies["util"] = "^1.0.0"
+	manifest.Dependencies["log"] = "^1.2.0"
+
+	_, err = Resolve(manifest, registry, nil)
+	assert.EqualError(t, err, `no version of log satisfies all requirements:
+	bank requires log ^1.2.0
+	bank -> http@2.1.0 requires log ~1.1.0
+available versions: 1.1.0, 1.1.4, 1.2.0, 1.3.0-beta`)
+}
+
+func TestVendor(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"util/1.2.0/util.tiny":     "namespace \"util\";",
+		"util/1.2.0/math/max.tiny": "namespace \"math\";",
+	})
+	registry := NewRegistry(root)
+
+	manifest := NewManifest("bank", "0.1.0")
+	manifest.Dependencies["util"] = "1.2.0"
+
+	lockfile, err := Resolve(manifest, registry, nil)
+	assert.NoError(t, err)
+
+	// vendor directory is next to the manifest, not in the working directory
+	project := t.TempDir()
+	path := filepath.Join(project, ManifestFilename)
+	assert.NoError(t, os.WriteFile(path, manifest.Encode(), 0644))
+
+	manifest, err = ReadManifest(path)
+	assert.NoError(t, err)
+	assert.Equal(t, project, manifest.Directory)
+
+	vendor := filepath.Join(project, VendorDirectory)
+	assert.NoError(t, Vendor(lockfile, registry, manifest.Directory))
+
+	directories, err := PackageDirectories(lockfile, registry, manifest.Directory)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"util": filepath.Join(vendor, "util")}, directories)
+
+	// vendored copy is modified
+	path = filepath.Join(vendor, "util", "math", "max.tiny")
+	assert.NoError(t, os.WriteFile(path, []byte("namespace \"max\";"), 0644))
+
+	_, err = PackageDirectories(lockfile, registry, project)
+	assert.Error(t, err)
+
+	// registry copy is used without vendor directory
+	assert.NoError(t, os.RemoveAll(vendor))
+
+	directories, err = PackageDirectories(lockfile, registry, project)
+	assert.NoError(t, err)
+	assert.Equal(t, registry.Directory("util", "1.2.0"), directories["util"])
+}
@@ -0,0 +1,145 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package tpm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// VendorDirectory is the name of the directory, which contains copies of
+// dependencies made by `tpm vendor`.
+const VendorDirectory = "vendor"
+
+// Verify checks that the package directory has the checksum recorded in the
+// lock file.
+func (p *LockedPackage) Verify(directory string) error {
+	hash, err := HashDirectory(directory)
+	if err != nil {
+		return err
+	}
+
+	if hash != p.Hash {
+		return fmt.Errorf("checksum mismatch for %s@%s in %s:\n\tlocked: %s\n\tactual: %s",
+			p.Name, p.Version, directory, p.Hash, hash)
+	}
+
+	return nil
+}
+
+// PackageDirectories returns verified directories of locked packages by their
+// names. Vendored copy of a package in the project directory is preferred
+// over the registry one.
+func PackageDirectories(lockfile *Lockfile, registry *Registry,
+	projectDirectory string) (map[string]string, error) {
+	directories := map[string]string{}
+	vendorDirectory := filepath.Join(projectDirectory, VendorDirectory)
+
+	for _, locked := range lockfile.Packages {
+		directory := filepath.Join(vendorDirectory, locked.Name)
+		if _, err := os.Stat(directory); err != nil {
+			directory = registry.Directory(locked.Name, locked.Version)
+		}
+
+		if err := locked.Verify(directory); err != nil {
+			return nil, err
+		}
+
+		directories[locked.Name] = directory
+	}
+
+	return directories, nil
+}
+
+// Vendor replaces contents of the vendor directory of the project with copies
+// of all locked packages from the registry.
+func Vendor(lockfile *Lockfile, registry *Registry, projectDirectory string) error {
+	vendorDirectory := filepath.Join(projectDirectory, VendorDirectory)
+
+	for _, locked := range lockfile.Packages {
+		if err := locked.Verify(registry.Directory(locked.Name, locked.Version)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(vendorDirectory); err != nil {
+		return err
+	}
+
+	for _, locked := range lockfile.Packages {
+		err := copyDirectory(registry.Directory(locked.Name, locked.Version),
+			filepath.Join(vendorDirectory, locked.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyDirectory(source string, destination string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, relative)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultTabWidth is the amount of display columns between tab stops.
+const DefaultTabWidth = 4
+
+// Columns of a line are counted in four ways: byte offsets of UTF-8 source,
+// code points (Column of CodePointLocation), UTF-16 code units (positions in
+// LSP) and display columns of a terminal, where tabs are expanded and wide
+// characters take two columns. Functions below convert between them within
+// a line, which must not contain line breaks. Positions past the end of the
+// line are counted as if the line was followed by spaces.
+
+// ByteToRuneColumn returns amount of code points before the byte offset.
+func ByteToRuneColumn(line string, offset int) int {
+	if offset > len(line) {
+		return utf8.RuneCountInString(line) + offset - len(line)
+	}
+
+	return utf8.RuneCountInString(line[:offset])
+}
+
+// RuneColumnToByte returns byte offset of the code point with the column.
+func RuneColumnToByte(line string, column int) int {
+	for offset := range line {
+		if column == 0 {
+			return offset
+		}
+
+		column--
+	}
+
+	return len(line) + column
+}
+
+// ByteToUTF16Column returns amount of UTF-16 code units before the byte
+// offset.
+func ByteToUTF16Column(line string, offset int) int {
+	column := 0
+	if offset > len(line) {
+		column = offset - len(line)
+		offset = len(line)
+	}
+
+	for _, r := range line[:offset] {
+		column += utf16Length(r)
+	}
+
+	return column
+}
+
+// UTF16ColumnToByte returns byte offset of the code point, which starts at
+// the UTF-16 column. Column in the middle of a surrogate pair is moved to the
+// next code point.
+func UTF16ColumnToByte(line string, column int) int {
+	for offset, r := range line {
+		if column <= 0 {
+			return offset
+		}
+
+		column -= utf16Length(r)
+	}
+
+	if column < 0 {
+		column = 0
+	}
+
+	return len(line) + column
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// ByteToDisplayColumn returns width of the line before the byte offset in a
+// terminal, where tab moves to the next multiple of tabWidth.
+func ByteToDisplayColumn(line string, offset int, tabWidth int) int {
+	column := 0
+	if offset > len(line) {
+		column = offset - len(line)
+		offset = len(line)
+	}
+
+	width := 0
+	for _, r := range line[:offset] {
+		if r == '\t' {
+			width += tabWidth - width%tabWidth
+		} else {
+			width += RuneWidth(r)
+		}
+	}
+
+	return width + column
+}
+
+// RuneWidth returns amount of display columns taken by the code point: 2 for
+// wide East Asian characters and emoji, 0 for combining and format
+// characters, 1 for others.
+func RuneWidth(r rune) int {
+	switch {
+	case r == 0x200B || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && (r <= 0x115F || // Hangul Jamo
+		r == 0x2329 || r == 0x232A ||
+		r >= 0x2E80 && r <= 0xA4CF && r != 0x303F || // CJK ... Yi
+		r >= 0xAC00 && r <= 0xD7A3 || // Hangul Syllables
+		r >= 0xF900 && r <= 0xFAFF || // CJK Compatibility Ideographs
+		r >= 0xFE10 && r <= 0xFE19 || // Vertical Forms
+		r >= 0xFE30 && r <= 0xFE6F || // CJK Compatibility Forms
+		r >= 0xFF00 && r <= 0xFF60 || // Fullwidth Forms
+		r >= 0xFFE0 && r <= 0xFFE6 ||
+		r >= 0x1F300 && r <= 0x1F64F || // pictographs and emoticons
+		r >= 0x1F680 && r <= 0x1F6FF || // transport and map symbols
+		r >= 0x1F900 && r <= 0x1F9FF || // supplemental pictographs
+		r >= 0x20000 && r <= 0x2FFFD ||
+		r >= 0x30000 && r <= 0x3FFFD):
+		return 2
+	}
+
+	return 1
+}
+
+// expandTabs replaces tabs of the line with spaces up to the next tab stop.
+func expandTabs(line string, tabWidth int) string {
+	expanded := []rune{}
+	width := 0
+
+	for _, r := range line {
+		if r != '\t' {
+			expanded = append(expanded, r)
+			width += RuneWidth(r)
+			continue
+		}
+
+		for spaces := tabWidth - width%tabWidth; spaces > 0; spaces-- {
+			expanded = append(expanded, ' ')
+			width++
+		}
+	}
+
+	return string(expanded)
+}
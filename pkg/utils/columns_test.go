@@ -0,0 +1,90 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumns(t *testing.T) {
+	// byte offsets of code points: 0 1 2 5 9 10
+	line := "\ta本🙂b"
+
+	for i, offset := range []int{0, 1, 2, 5, 9, 10, 11} {
+		assert.Equal(t, i, ByteToRuneColumn(line, offset))
+		assert.Equal(t, offset, RuneColumnToByte(line, i))
+	}
+
+	for i, column := range []int{0, 1, 2, 3, 5, 6, 7} {
+		offset := []int{0, 1, 2, 5, 9, 10, 11}[i]
+		assert.Equal(t, column, ByteToUTF16Column(line, offset))
+		assert.Equal(t, offset, UTF16ColumnToByte(line, column))
+	}
+
+	// middle of the surrogate pair
+	assert.Equal(t, 9, UTF16ColumnToByte(line, 4))
+
+	for i, column := range []int{0, 4, 5, 7, 9, 10, 11} {
+		offset := []int{0, 1, 2, 5, 9, 10, 11}[i]
+		assert.Equal(t, column, ByteToDisplayColumn(line, offset, 4))
+	}
+
+	assert.Equal(t, 8, ByteToDisplayColumn("ab\tc", 3, 8))
+	assert.Equal(t, 0, RuneWidth('́'))
+	assert.Equal(t, 2, RuneWidth('한'))
+	assert.Equal(t, 1, RuneWidth('я'))
+
+	assert.Equal(t, "a   b", expandTabs("a\tb", 4))
+	assert.Equal(t, "本  b", expandTabs("本\tb", 4))
+}
+
+func TestWideCharacters(t *testing.T) {
+	var output bytes.Buffer
+
+	files := NewFileSet()
+	files.AddFile("a.tiny", []byte("\tf(\"日本🙂\", x);\n"))
+
+	h := NewCodeProblemHandler()
+	h.SetWriter(&output)
+	h.SetFileSet(files)
+
+	location := func(column int, endColumn int) *CodeBlockLocation {
+		return &CodeBlockLocation{
+			StartLocation: &CodePointLocation{Filepath: "a.tiny", Line: 1, Column: column},
+			EndLocation:   &CodePointLocation{Filepath: "a.tiny", Line: 1, Column: endColumn},
+		}
+	}
+
+	h.AddCodeProblem(NewLocalError(location(10, 11), RedeclaredErr, "x", "app").
+		WithLabel(location(3, 8), "wide characters"))
+	h.PrintProblems()
+
+	assert.Equal(t, "a.tiny(1:10) error[E0033]: x redeclared in namespace app\n"+
+		"   |\n"+
+		" 1 |     f(\"日本🙂\", x);\n"+
+		"   |                 ^\n"+
+		"   |       -------- wide characters\n\n", output.String())
+}
@@ -0,0 +1,396 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// DiagnosticsFormat is the way problems are printed by CodeProblemHandler.
+type DiagnosticsFormat int
+
+const (
+	// HumanFormat prints problems with the code they are found in.
+	HumanFormat DiagnosticsFormat = iota
+
+	// JSONFormat prints every problem as a JSON object on its own line.
+	JSONFormat
+
+	// SARIFFormat prints all problems as a SARIF 2.1.0 log.
+	SARIFFormat
+
+	// GCCFormat prints problems as `file:line:column: error: message` lines
+	// understood by editors and CI problem matchers.
+	GCCFormat
+)
+
+var diagnosticsFormatNames = map[DiagnosticsFormat]string{
+	HumanFormat: "human",
+	JSONFormat:  "json",
+	SARIFFormat: "sarif",
+	GCCFormat:   "gcc",
+}
+
+func (f DiagnosticsFormat) String() string {
+	return diagnosticsFormatNames[f]
+}
+
+func ParseDiagnosticsFormat(name string) (DiagnosticsFormat, error) {
+	for format, formatName := range diagnosticsFormatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+
+	return HumanFormat, fmt.Errorf(
+		"unknown diagnostics format %q, expected human, json, sarif or gcc", name)
+}
+
+// jsonProblem is a problem in JSON format. Lines and columns start from 1,
+// columns count unicode code points, end position is exclusive.
+type jsonProblem struct {
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+
+	Label  string       `json:"label,omitempty"`
+	Labels []*jsonLabel `json:"labels,omitempty"`
+	Notes  []string     `json:"notes,omitempty"`
+	Help   []string     `json:"help,omitempty"`
+	Fixes  []*jsonFix   `json:"fixes,omitempty"`
+}
+
+// jsonSpan is a location in JSON format.
+type jsonSpan struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+}
+
+func newJSONSpan(location *CodeBlockLocation) jsonSpan {
+	return jsonSpan{
+		File:      location.StartLocation.Filepath,
+		Line:      location.StartLocation.Line,
+		Column:    location.StartLocation.Column + 1,
+		EndLine:   location.EndLocation.Line,
+		EndColumn: location.EndLocation.Column + 1,
+	}
+}
+
+// jsonLabel is a secondary location of a problem in JSON format.
+type jsonLabel struct {
+	jsonSpan
+	Message string `json:"message"`
+}
+
+// jsonFix is a fix of a problem in JSON format.
+type jsonFix struct {
+	Message string      `json:"message"`
+	Edits   []*jsonEdit `json:"edits"`
+}
+
+type jsonEdit struct {
+	jsonSpan
+	Text string `json:"text"`
+}
+
+func newJSONProblem(problem *CodeProblem) *jsonProblem {
+	p := &jsonProblem{
+		Severity: problem.Severity().String(),
+		Code:     problem.ID(),
+		Message:  problem.Message(),
+		Label:    problem.primaryLabel,
+		Notes:    problem.notes,
+		Help:     problem.help,
+	}
+
+	if !problem.global {
+		p.File = problem.location.StartLocation.Filepath
+		p.Line = problem.location.StartLocation.Line
+		p.Column = problem.location.StartLocation.Column + 1
+		p.EndLine = problem.location.EndLocation.Line
+		p.EndColumn = problem.location.EndLocation.Column + 1
+	}
+
+	for _, label := range problem.labels {
+		p.Labels = append(p.Labels, &jsonLabel{
+			jsonSpan: newJSONSpan(label.Location),
+			Message:  label.Message,
+		})
+	}
+
+	for _, fix := range problem.fixes {
+		f := &jsonFix{Message: fix.Message, Edits: []*jsonEdit{}}
+		for _, edit := range fix.Edits {
+			f.Edits = append(f.Edits, &jsonEdit{
+				jsonSpan: newJSONSpan(edit.Location),
+				Text:     edit.Text,
+			})
+		}
+
+		p.Fixes = append(p.Fixes, f)
+	}
+
+	return p
+}
+
+func (h *CodeProblemHandler) printJSON(problems []*CodeProblem) {
+	encoder := json.NewEncoder(h.writer)
+	for _, problem := range problems {
+		encoder.Encode(newJSONProblem(problem))
+	}
+}
+
+// printGCC prints every problem on its own line followed by `note` lines for
+// its labels and notes and `help` lines for its help and fixes, like GCC does.
+// Edits of fixes are printed as `fix-it` lines understood by editors.
+func (h *CodeProblemHandler) printGCC(problems []*CodeProblem) {
+	for _, problem := range problems {
+		prefix := "tinyc"
+		if !problem.global {
+			prefix = gccLocation(problem.location)
+		}
+
+		id := problem.ID()
+		if !problem.critical {
+			id += ", -W" + WarningName(problem.code)
+		}
+
+		fmt.Fprintf(h.writer, "%s: %s: %s [%s]\n",
+			prefix, problem.Severity(), problem.Message(), id)
+
+		for _, label := range problem.labels {
+			fmt.Fprintf(h.writer, "%s: note: %s\n", gccLocation(label.Location), label.Message)
+		}
+
+		for _, note := range problem.notes {
+			fmt.Fprintf(h.writer, "%s: note: %s\n", prefix, note)
+		}
+
+		for _, help := range problem.help {
+			fmt.Fprintf(h.writer, "%s: help: %s\n", prefix, help)
+		}
+
+		for _, fix := range problem.fixes {
+			fmt.Fprintf(h.writer, "%s: help: %s\n", prefix, fix.Message)
+
+			// format of clang's -fdiagnostics-parseable-fixits
+			for _, edit := range fix.Edits {
+				fmt.Fprintf(h.writer, "fix-it:%s:{%d:%d-%d:%d}:%s\n",
+					strconv.Quote(edit.Location.StartLocation.Filepath),
+					edit.Location.StartLocation.Line, edit.Location.StartLocation.Column+1,
+					edit.Location.EndLocation.Line, edit.Location.EndLocation.Column+1,
+					strconv.Quote(edit.Text))
+			}
+		}
+	}
+}
+
+func gccLocation(location *CodeBlockLocation) string {
+	return fmt.Sprintf("%s:%d:%d", location.StartLocation.Filepath,
+		location.StartLocation.Line, location.StartLocation.Column+1)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func newSARIFPhysicalLocation(file string, line int, column int,
+	endLine int, endColumn int) sarifPhysicalLocation {
+	return sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(file)},
+		Region: sarifRegion{
+			StartLine:   line,
+			StartColumn: column,
+			EndLine:     endLine,
+			EndColumn:   endColumn,
+		},
+	}
+}
+
+// newSARIFFix returns fix with edits grouped by files.
+func newSARIFFix(fix *jsonFix) sarifFix {
+	f := sarifFix{Description: sarifMessage{Text: fix.Message}}
+
+	changes := map[string]int{}
+	for _, edit := range fix.Edits {
+		uri := filepath.ToSlash(edit.File)
+
+		i, ok := changes[uri]
+		if !ok {
+			i = len(f.ArtifactChanges)
+			changes[uri] = i
+			f.ArtifactChanges = append(f.ArtifactChanges, sarifArtifactChange{
+				ArtifactLocation: sarifArtifactLocation{URI: uri}})
+		}
+
+		f.ArtifactChanges[i].Replacements = append(f.ArtifactChanges[i].Replacements,
+			sarifReplacement{
+				DeletedRegion: sarifRegion{
+					StartLine:   edit.Line,
+					StartColumn: edit.Column,
+					EndLine:     edit.EndLine,
+					EndColumn:   edit.EndColumn,
+				},
+				InsertedContent: sarifMessage{Text: edit.Text},
+			})
+	}
+
+	return f
+}
+
+func (h *CodeProblemHandler) printSARIF(problems []*CodeProblem) {
+	results := []sarifResult{}
+
+	for _, problem := range problems {
+		p := newJSONProblem(problem)
+
+		// SARIF has no notes, so they are shown after the message
+		text := p.Message
+		for _, note := range p.Notes {
+			text += "\nnote: " + note
+		}
+
+		for _, help := range p.Help {
+			text += "\nhelp: " + help
+		}
+
+		result := sarifResult{
+			RuleID:  p.Code,
+			Level:   p.Severity,
+			Message: sarifMessage{Text: text},
+		}
+
+		if !problem.global {
+			result.Locations = []sarifLocation{{
+				PhysicalLocation: newSARIFPhysicalLocation(
+					p.File, p.Line, p.Column, p.EndLine, p.EndColumn),
+			}}
+		}
+
+		for i, label := range p.Labels {
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID: i + 1,
+				PhysicalLocation: newSARIFPhysicalLocation(
+					label.File, label.Line, label.Column, label.EndLine, label.EndColumn),
+				Message: &sarifMessage{Text: label.Message},
+			})
+		}
+
+		for _, fix := range p.Fixes {
+			result.Fixes = append(result.Fixes, newSARIFFix(fix))
+		}
+
+		results = append(results, result)
+	}
+
+	log := &sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: sarifDriver{Name: "tinyc"}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+
+	encoder := json.NewEncoder(h.writer)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(log)
+}
@@ -0,0 +1,828 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import "strings"
+
+// ErrorExplanation is a long description of an error, which is printed by
+// `tinyc explain`.
+type ErrorExplanation struct {
+	Text string
+
+	// Source, which causes the error, and the same source fixed. Empty if the
+	// error can't be shown in a single source file.
+	Wrong string
+	Right string
+}
+
+// ExplainError returns explanation of the error or nil if the code is
+// unknown.
+func ExplainError(code int) *ErrorExplanation {
+	return error_explanations[code]
+}
+
+func (e *ErrorExplanation) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(e.Text)
+
+	if e.Wrong != "" {
+		sb.WriteString("\nErroneous code example:\n\n")
+		writeIndented(&sb, e.Wrong)
+		sb.WriteString("\nCorrected code:\n\n")
+		writeIndented(&sb, e.Right)
+	}
+
+	return sb.String()
+}
+
+func writeIndented(sb *strings.Builder, source string) {
+	for _, line := range strings.Split(strings.TrimRight(source, "\n"), "\n") {
+		if line != "" {
+			sb.WriteString("    ")
+			sb.WriteString(line)
+		}
+
+		sb.WriteByte('\n')
+	}
+}
+
+var error_explanations = map[int]*ErrorExplanation{
+	IllegalNullCharacterErr: {
+		Text: `Source file contains a null character (U+0000).
+
+Null characters are not allowed anywhere in Tiny source, even in comments and
+string literals. They usually come from a file saved in UTF-16 or from a binary
+file passed to the compiler by mistake. Save the file in UTF-8 or use the "\0"
+escape sequence in string literals.
+`,
+	},
+	IllegalUTF8EncodingErr: {
+		Text: `Source file is not valid UTF-8.
+
+Tiny source files must be encoded in UTF-8. The error is reported at the first
+byte, which doesn't form a valid UTF-8 sequence. Convert the file to UTF-8, for
+example with "iconv -f latin1 -t utf-8".
+`,
+	},
+	UnableToReadFileErr: {
+		Text: `A file given to the compiler can't be read.
+
+The file doesn't exist, is a directory or the compiler has no permission to
+read it. Check the path and permissions of the file.
+`,
+	},
+	UnexpectedCharacterErr: {
+		Text: `Source contains a character, which can't start any token.
+
+Characters outside of string literals and comments must be a part of an
+identifier, a number, an operator or a punctuation mark.
+`,
+		Wrong: `namespace "app";
+
+fun f() { # }
+`,
+		Right: `namespace "app";
+
+fun f() {}
+`,
+	},
+	NotClosedWrappedIdentifierErr: {
+		Text: "An identifier wrapped in backquotes is not closed on the same line.\n\n" +
+			"Keywords can be used as identifiers when they are wrapped in backquotes:\n" +
+			"`fun`. The closing backquote must be on the same line.\n",
+		Wrong: "namespace \"app\";\n\nfun `var() {}\n",
+		Right: "namespace \"app\";\n\nfun `var`() {}\n",
+	},
+	NotClosedMultiLineCommentErr: {
+		Text: `A multiline comment is not closed before the end of file.
+
+Multiline comments start with "/*" and end with "*/".
+`,
+		Wrong: `namespace "app";
+
+/* the comment goes until the end of file
+fun f() {}
+`,
+		Right: `namespace "app";
+
+/* the comment is closed */
+fun f() {}
+`,
+	},
+	NotClosedStringErr: {
+		Text: `A string literal is not closed on the same line.
+
+String literals can't span several lines. Use the "\n" escape sequence to put
+a line break into a string.
+`,
+		Wrong: `namespace "app";
+
+fun f() { "hello; }
+`,
+		Right: `namespace "app";
+
+fun f() { "hello"; }
+`,
+	},
+	UnknownEscapeSequenceErr: {
+		Text: `A string literal contains an unknown escape sequence.
+
+Supported escape sequences are \a, \b, \f, \n, \r, \t, \v, \\, \", octal
+\NNN, hexadecimal \xNN and unicode \uNNNN and \UNNNNNNNN. To put a backslash
+into a string, escape it.
+`,
+		Wrong: `namespace "app";
+
+fun f() { "C:\qemu"; }
+`,
+		Right: `namespace "app";
+
+fun f() { "C:\\qemu"; }
+`,
+	},
+	EscapeSequenceNotTerminatedErr: {
+		Text: `An escape sequence is cut off by the end of file.
+
+Escape sequences with numbers need the exact amount of digits: three octal
+digits after "\", two hexadecimal digits after "\x", four after "\u" and eight
+after "\U".
+`,
+	},
+	IllegalCharacterInEscapeSequenceErr: {
+		Text: `An escape sequence contains a character, which is not a digit of its base.
+
+Octal escapes "\NNN" take three octal digits, "\xNN" takes two hexadecimal
+digits, "\uNNNN" four and "\UNNNNNNNN" eight.
+`,
+		Wrong: `namespace "app";
+
+fun f() { "\xZZ"; }
+`,
+		Right: `namespace "app";
+
+fun f() { "\x5A"; }
+`,
+	},
+	EscapeSequenceIsInvalidUTF8CodePointErr: {
+		Text: `An escape sequence denotes a value, which is not a Unicode code point.
+
+Octal and hexadecimal escapes can't be greater than 255. Unicode escapes can't
+be greater than U+10FFFF or be surrogate halves (U+D800 to U+DFFF), which can't
+be encoded in UTF-8.
+`,
+		Wrong: `namespace "app";
+
+fun f() { "\uD800"; }
+`,
+		Right: `namespace "app";
+
+fun f() { "\u00E9"; }
+`,
+	},
+	InvalidRadixPointErr: {
+		Text: `A binary or octal literal has a fractional part.
+
+Only decimal and hexadecimal literals can be floating point numbers.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 0b1.1; }
+`,
+		Right: `namespace "app";
+
+fun f() { 1.5; }
+`,
+	},
+	HasNoDigitsErr: {
+		Text: `A number literal has a prefix, but no digits after it.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 0x; }
+`,
+		Right: `namespace "app";
+
+fun f() { 0x0; }
+`,
+	},
+	ExponentRequiresDecimalMantissaErr: {
+		Text: `A binary, octal or hexadecimal literal has an "e" exponent.
+
+The "e" exponent (power of 10) can only follow a decimal number.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 0o7e3; }
+`,
+		Right: `namespace "app";
+
+fun f() { 7e3; }
+`,
+	},
+	ExponentRequiresHexadecimalMantissaErr: {
+		Text: `A literal, which is not hexadecimal, has a "p" exponent.
+
+The "p" exponent (power of 2) can only follow a hexadecimal number.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 1p3; }
+`,
+		Right: `namespace "app";
+
+fun f() { 0x1p3; }
+`,
+	},
+	ExponentHasNoDigitsErr: {
+		Text: `An exponent of a number literal has no digits.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 1e; }
+`,
+		Right: `namespace "app";
+
+fun f() { 1e3; }
+`,
+	},
+	HexadecimalMantissaRequiresPExponentErr: {
+		Text: `A hexadecimal floating point literal has no "p" exponent.
+
+Hexadecimal literals with a fractional part must have a binary exponent, which
+can be zero.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 0x1.8; }
+`,
+		Right: `namespace "app";
+
+fun f() { 0x1.8p0; }
+`,
+	},
+	InvalidDigitErr: {
+		Text: `An integer literal contains a digit, which is not valid in its base.
+
+Binary literals (0b) contain digits 0 and 1, octal literals (0o or a leading 0)
+digits from 0 to 7.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 0b102; }
+`,
+		Right: `namespace "app";
+
+fun f() { 0b101; }
+`,
+	},
+	UnderscoreMustSeparateSuccessiveDigitsErr: {
+		Text: `An underscore in a number literal doesn't separate two digits.
+
+Underscores make long numbers readable. There must be a digit on both sides of
+each underscore, the only exception is an underscore right after the base
+prefix: 0x_FF.
+`,
+		Wrong: `namespace "app";
+
+fun f() { 1__000; }
+`,
+		Right: `namespace "app";
+
+fun f() { 1_000; }
+`,
+	},
+	UnexpectedTokenErr: {
+		Text: `The parser expected a certain token, but found another one.
+
+Usually a punctuation mark is missing or there's a typo near the reported
+location.
+`,
+		Wrong: `namespace "app";
+
+fun f(a i32) {}
+`,
+		Right: `namespace "app";
+
+fun f(a: i32) {}
+`,
+	},
+	UnexpectedToken2Err: {
+		Text: `A token can't appear at this place.
+
+For example, only functions, structures, interfaces and extern functions can
+be declared at the top level of a file.
+`,
+		Wrong: `namespace "app";
+
+struct Point {};
+`,
+		Right: `namespace "app";
+
+struct Point {}
+`,
+	},
+	UndefinedInterfaceErr: {
+		Text: `An interface, which is not declared, is used.
+
+Check the spelling of the name. Interfaces from other namespaces must be
+imported and qualified with the namespace.
+`,
+		Wrong: `namespace "app";
+
+struct File: Reader {}
+`,
+		Right: `namespace "app";
+
+interface Reader {}
+
+struct File: Reader {}
+`,
+	},
+	NotAnInterfaceErr: {
+		Text: `A name, which is not an interface, is used where an interface is expected.
+
+Structures can only implement interfaces and type parameters can only be
+constrained by interfaces.
+`,
+		Wrong: `namespace "app";
+
+struct Reader {}
+
+struct File: Reader {}
+`,
+		Right: `namespace "app";
+
+interface Reader {}
+
+struct File: Reader {}
+`,
+	},
+	MissingInterfaceMethodErr: {
+		Text: `A structure doesn't have a method of an interface it implements.
+
+A structure must declare every method of every interface listed after ":" in
+its declaration.
+`,
+		Wrong: `namespace "app";
+
+interface Reader {
+	fun close();
+}
+
+struct File: Reader {}
+`,
+		Right: `namespace "app";
+
+interface Reader {
+	fun close();
+}
+
+struct File: Reader {
+	fun close() {}
+}
+`,
+	},
+	WrongInterfaceMethodSignatureErr: {
+		Text: `A method of a structure has a different signature than the same method of
+an interface the structure implements.
+
+Types of arguments and the return type must be the same as in the interface.
+`,
+		Wrong: `namespace "app";
+
+interface Reader {
+	fun read(buffer: *u8, size: u64): u64;
+}
+
+struct File: Reader {
+	fun read(buffer: *u8, size: u32): u32 {}
+}
+`,
+		Right: `namespace "app";
+
+interface Reader {
+	fun read(buffer: *u8, size: u64): u64;
+}
+
+struct File: Reader {
+	fun read(buffer: *u8, size: u64): u64 {}
+}
+`,
+	},
+	WrongTypeArgumentsAmountErr: {
+		Text: `A generic structure is used with a wrong amount of type arguments.
+
+Every type parameter of a generic structure must get a type argument.
+`,
+		Wrong: `namespace "app";
+
+struct Box<T> {
+	pub value: T;
+}
+
+fun f(box: Box) {}
+`,
+		Right: `namespace "app";
+
+struct Box<T> {
+	pub value: T;
+}
+
+fun f(box: Box<i32>) {}
+`,
+	},
+	DoesNotSatisfyConstraintErr: {
+		Text: `A type argument doesn't implement the interface, which constrains the type
+parameter.
+`,
+		Wrong: `namespace "app";
+
+interface Printable {
+	fun print();
+}
+
+struct List<T: Printable> {}
+
+struct Point {}
+
+fun f(points: List<Point>) {}
+`,
+		Right: `namespace "app";
+
+interface Printable {
+	fun print();
+}
+
+struct List<T: Printable> {}
+
+struct Point: Printable {
+	fun print() {}
+}
+
+fun f(points: List<Point>) {}
+`,
+	},
+	UnableToReadDirectoryErr: {
+		Text: `A package directory can't be read.
+
+The directory doesn't exist or the compiler has no permission to read it.
+`,
+	},
+	NoSourceFilesErr: {
+		Text: `A package directory doesn't contain Tiny source files.
+
+Every package is a directory with at least one ".tiny" file. Files in
+subdirectories belong to other packages.
+`,
+	},
+	PackageNotFoundErr: {
+		Text: `An imported package is not found.
+
+Paths starting with "./" or "../" are resolved relative to the importing
+package. Other paths are looked up in packages provided by tpm, then in the
+project root, then in directories given with "--search-path". For example,
+"app/main.tiny" can't import "util" if there's no "util" directory in any of
+these places:
+
+    namespace "app";
+    import "util";
+`,
+	},
+	ImportCycleErr: {
+		Text: `Packages import each other.
+
+Imports must not form a cycle: if package "a" imports "b", then "b" can't
+import "a", directly or through other packages. Move declarations used by both
+packages to a third package, which both of them import.
+`,
+	},
+	MultipleNamespacesErr: {
+		Text: `Files of one package declare different namespaces.
+
+All files in a package directory must start with the same namespace
+declaration.
+`,
+	},
+	NamespaceImportedTwiceErr: {
+		Text: `Two imported packages have the same namespace.
+
+Declarations of imported packages are referred to by their namespaces, so a
+file can't import two packages with the same namespace.
+`,
+	},
+	RedeclaredErr: {
+		Text: `A name is declared twice in one namespace.
+
+Top level declarations of all files of a package share one namespace, so
+their names must be different:
+
+    // a.tiny
+    namespace "app";
+    fun run() {}
+
+    // b.tiny
+    namespace "app";
+    struct run {}
+`,
+	},
+	NotPublicErr: {
+		Text: `A declaration from another namespace is used, but it is not public.
+
+Only declarations, structure members and methods marked with "pub" can be
+used outside of their namespace:
+
+    namespace "bank";
+    pub struct Account {
+        pub readonly age: i32;
+        password: string;
+    }
+
+Here code of other namespaces can read account.age, but not
+account.password.
+`,
+	},
+	VariadicNotExternErr: {
+		Text: `A function, which is not extern, has "..." in its arguments.
+
+Variable amount of arguments is only supported for extern functions
+implemented in C, like printf.
+`,
+		Wrong: `namespace "app";
+
+fun log(format: *u8, ...) {}
+`,
+		Right: `namespace "app";
+
+extern fun printf(format: *u8, ...): i32;
+`,
+	},
+	GenericExternFunctionErr: {
+		Text: `An extern function has type parameters.
+
+Extern functions are implemented in C, so they can't be generic.
+`,
+		Wrong: `namespace "app";
+
+extern fun max<T>(a: T, b: T): T;
+`,
+		Right: `namespace "app";
+
+extern fun max(a: i64, b: i64): i64;
+`,
+	},
+	InvalidExternTypeErr: {
+		Text: `A type, which has no C equivalent, is used in an extern function.
+
+Arrays and interfaces can't be passed to C functions. Pass a pointer to the
+first element and the length instead of an array.
+`,
+		Wrong: `namespace "app";
+
+extern fun sum(values: []i32): i32;
+`,
+		Right: `namespace "app";
+
+extern fun sum(values: *i32, length: u64): i32;
+`,
+	},
+	UnknownAttributeErr: {
+		Text: `An attribute, which the compiler doesn't know, is used.
+
+The only attribute is @nodestroy. It marks a structure member, which the
+structure doesn't own, so the default destroy doesn't destroy it.
+`,
+		Wrong: `namespace "app";
+
+struct Node {
+	@borrowed parent: *Node;
+}
+`,
+		Right: `namespace "app";
+
+struct Node {
+	@nodestroy parent: *Node;
+}
+`,
+	},
+	MisplacedAttributeErr: {
+		Text: `An attribute is used on a method.
+
+@nodestroy describes ownership of a member value, so it can only be used on
+structure members.
+`,
+		Wrong: `namespace "app";
+
+struct Node {
+	@nodestroy parent: *Node;
+
+	@nodestroy pub fun get(): *Node {
+		return this.parent;
+	}
+}
+`,
+		Right: `namespace "app";
+
+struct Node {
+	@nodestroy parent: *Node;
+
+	pub fun get(): *Node {
+		return this.parent;
+	}
+}
+`,
+	},
+	FailableExternErr: {
+		Text: `An extern function is declared with fails.
+
+Extern functions are implemented in C, which can't pass errors to handlers.
+Declare the C function as it is and wrap it in a failable Tiny function,
+which checks its result.
+`,
+		Wrong: `namespace "hal";
+
+extern fun i2c_write(address: u8, data: *u8, size: u64): i32 fails;
+`,
+		Right: `namespace "hal";
+
+extern fun i2c_write(address: u8, data: *u8, size: u64): i32;
+
+pub fun write(address: u8, data: *u8, size: u64) fails {
+	if i2c_write(address, data, size) != 0 {
+		fail 1;
+	}
+}
+`,
+	},
+	HandleNotCallErr: {
+		Text: `Something other than a function call is handled.
+
+Only calls to failable functions can fail, so handle must follow a call.
+`,
+		Wrong: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun main() {
+	var f = read;
+	f handle;
+}
+`,
+		Right: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun main() {
+	read() handle err {
+		return;
+	}
+}
+`,
+	},
+	UnhandledFailableCallErr: {
+		Text: `Error of a call to a failable function is not handled.
+
+A failable function is declared with fails and can stop with an error using
+the fail statement. Every call to it must either handle the error with a
+custom handler, which runs if the call fails:
+
+	read() handle err { ... }
+
+or propagate it to the caller with the default handler, if the calling
+function is failable itself:
+
+	read() handle;
+`,
+		Wrong: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun main() {
+	read();
+}
+`,
+		Right: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun main() {
+	read() handle err {
+		return;
+	}
+}
+`,
+	},
+	HandleNotFailableErr: {
+		Text: `Call to a function, which is not failable, is handled.
+
+The function is not declared with fails, so it never fails and the handler
+never runs.
+`,
+		Wrong: `namespace "app";
+
+fun read(): u8 {
+	return 0;
+}
+
+fun main() {
+	read() handle err {
+		return;
+	}
+}
+`,
+		Right: `namespace "app";
+
+fun read(): u8 {
+	return 0;
+}
+
+fun main() {
+	read();
+}
+`,
+	},
+	NotFailableFunctionErr: {
+		Text: `A function, which is not declared with fails, fails or propagates
+error of a call with the default handler.
+
+Either declare the function with fails, so its callers handle the error, or
+handle the error in place with a custom handler.
+`,
+		Wrong: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun main() {
+	read() handle;
+}
+`,
+		Right: `namespace "app";
+
+fun read(): u8 fails {
+	return 0;
+}
+
+fun process() fails {
+	read() handle;
+}
+`,
+	},
+	ReadonlyAssignmentErr: {
+		Text: `A readonly structure member is changed outside of methods of the
+structure.
+
+Other code can read readonly members, but only methods of the structure can
+change them.
+`,
+		Wrong: `namespace "bank";
+
+struct Account {
+	readonly age: i32;
+}
+
+fun birthday(account: *Account) {
+	account.age = account.age + 1;
+}
+`,
+		Right: `namespace "bank";
+
+struct Account {
+	readonly age: i32;
+
+	fun birthday() {
+		this.age = this.age + 1;
+	}
+}
+`,
+	},
+}
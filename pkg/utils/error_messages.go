@@ -22,28 +22,63 @@
 
 package utils
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error codes are shown to users as E0019 (see ErrorID), so they must never
+// change: new errors are appended with the next number and numbers of removed
+// errors are not reused.
 const (
-	IllegalNullCharacterErr = iota
-	IllegalUTF8EncodingErr
-	UnableToReadFileErr
-	UnexpectedCharacterErr
-	NotClosedWrappedIdentifierErr
-	NotClosedMultiLineCommentErr
-	NotClosedStringErr
-	UnknownEscapeSequenceErr
-	EscapeSequenceNotTerminatedErr
-	IllegalCharacterInEscapeSequenceErr
-	EscapeSequenceIsInvalidUTF8CodePointErr
-	InvalidRadixPointErr
-	HasNoDigitsErr
-	ExponentRequiresDecimalMantissaErr
-	ExponentRequiresHexadecimalMantissaErr
-	ExponentHasNoDigitsErr
-	HexadecimalMantissaRequiresPExponentErr
-	InvalidDigitErr
-	UnderscoreMustSeparateSuccessiveDigitsErr
-	UnexpectedTokenErr
-	UnexpectedToken2Err
+	IllegalNullCharacterErr                   = 0
+	IllegalUTF8EncodingErr                    = 1
+	UnableToReadFileErr                       = 2
+	UnexpectedCharacterErr                    = 3
+	NotClosedWrappedIdentifierErr             = 4
+	NotClosedMultiLineCommentErr              = 5
+	NotClosedStringErr                        = 6
+	UnknownEscapeSequenceErr                  = 7
+	EscapeSequenceNotTerminatedErr            = 8
+	IllegalCharacterInEscapeSequenceErr       = 9
+	EscapeSequenceIsInvalidUTF8CodePointErr   = 10
+	InvalidRadixPointErr                      = 11
+	HasNoDigitsErr                            = 12
+	ExponentRequiresDecimalMantissaErr        = 13
+	ExponentRequiresHexadecimalMantissaErr    = 14
+	ExponentHasNoDigitsErr                    = 15
+	HexadecimalMantissaRequiresPExponentErr   = 16
+	InvalidDigitErr                           = 17
+	UnderscoreMustSeparateSuccessiveDigitsErr = 18
+	UnexpectedTokenErr                        = 19
+	UnexpectedToken2Err                       = 20
+	UndefinedInterfaceErr                     = 21
+	NotAnInterfaceErr                         = 22
+	MissingInterfaceMethodErr                 = 23
+	WrongInterfaceMethodSignatureErr          = 24
+	WrongTypeArgumentsAmountErr               = 25
+	DoesNotSatisfyConstraintErr               = 26
+	UnableToReadDirectoryErr                  = 27
+	NoSourceFilesErr                          = 28
+	PackageNotFoundErr                        = 29
+	ImportCycleErr                            = 30
+	MultipleNamespacesErr                     = 31
+	NamespaceImportedTwiceErr                 = 32
+	RedeclaredErr                             = 33
+	NotPublicErr                              = 34
+	VariadicNotExternErr                      = 35
+	GenericExternFunctionErr                  = 36
+	InvalidExternTypeErr                      = 37
+	UnknownAttributeErr                       = 38
+	MisplacedAttributeErr                     = 39
+	FailableExternErr                         = 40
+	HandleNotCallErr                          = 41
+	UnhandledFailableCallErr                  = 42
+	HandleNotFailableErr                      = 43
+	NotFailableFunctionErr                    = 44
+	ReadonlyAssignmentErr                     = 45
 )
 
 var error_messages = map[int]string{
@@ -68,4 +103,62 @@ var error_messages = map[int]string{
 	UnderscoreMustSeparateSuccessiveDigitsErr: "`_` must separate successive digits",
 	UnexpectedTokenErr:                        "expected token to be %s, got %s instead",
 	UnexpectedToken2Err:                       "unexpected %s token",
+	UndefinedInterfaceErr:                     "undefined interface %s",
+	NotAnInterfaceErr:                         "%s is not an interface",
+	MissingInterfaceMethodErr:                 "%s does not implement %s (missing method %s)",
+	WrongInterfaceMethodSignatureErr:          "%s does not implement %s (wrong signature for method %s)",
+	WrongTypeArgumentsAmountErr:               "%s expects %d type argument(-s), got %d",
+	DoesNotSatisfyConstraintErr:               "%s does not implement %s",
+	UnableToReadDirectoryErr:                  "unable to read directory %s",
+	NoSourceFilesErr:                          "no Tiny source files in %s",
+	PackageNotFoundErr:                        "cannot find package %q",
+	ImportCycleErr:                            "import cycle not allowed: %s",
+	MultipleNamespacesErr:                     "found namespaces %s and %s in directory %s",
+	NamespaceImportedTwiceErr:                 "namespace %s is already imported from %s",
+	RedeclaredErr:                             "%s redeclared in namespace %s",
+	NotPublicErr:                              "%s is not public",
+	VariadicNotExternErr:                      "only extern functions can be variadic",
+	GenericExternFunctionErr:                  "extern function %s can't have type parameters",
+	InvalidExternTypeErr:                      "type %s can't be used in extern function %s",
+	UnknownAttributeErr:                       "unknown attribute @%s",
+	MisplacedAttributeErr:                     "attribute @%s can only be used on structure members",
+	FailableExternErr:                         "extern function %s can't fail",
+	HandleNotCallErr:                          "only calls can be handled",
+	UnhandledFailableCallErr:                  "error of failable function %s is not handled",
+	HandleNotFailableErr:                      "function %s can't fail, so there is nothing to handle",
+	NotFailableFunctionErr:                    "function %s is not declared with fails",
+	ReadonlyAssignmentErr:                     "%s is readonly",
+}
+
+// ErrorCodes returns codes of all errors in increasing order.
+func ErrorCodes() []int {
+	codes := []int{}
+	for code := range error_messages {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	return codes
+}
+
+// ErrorID returns public code of the error: `E0019` for UnexpectedTokenErr.
+func ErrorID(code int) string {
+	return fmt.Sprintf("E%04d", code)
+}
+
+// ParseErrorID returns error code by its public code. Leading `E` can be
+// omitted.
+func ParseErrorID(id string) (int, error) {
+	digits := strings.TrimPrefix(strings.ToUpper(id), "E")
+
+	code, err := strconv.Atoi(digits)
+	if err != nil || digits == "" || digits[0] == '+' || digits[0] == '-' {
+		return 0, fmt.Errorf("invalid error code %s", id)
+	}
+
+	if _, ok := error_messages[code]; !ok {
+		return 0, fmt.Errorf("unknown error code %s", id)
+	}
+
+	return code, nil
 }
@@ -0,0 +1,118 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import "strings"
+
+// SourceFile is a source file registered in a FileSet.
+type SourceFile struct {
+	path   string
+	source []byte
+
+	// byte offsets of line starts
+	lines []int
+}
+
+// Path returns path of the file as it is shown in diagnostics.
+func (f *SourceFile) Path() string {
+	return f.path
+}
+
+// Source returns content of the file.
+func (f *SourceFile) Source() []byte {
+	return f.source
+}
+
+// LineCount returns amount of lines in the file.
+func (f *SourceFile) LineCount() int {
+	return len(f.lines)
+}
+
+// Line returns text of the line without line break, lines start from 1.
+// Returns false if there's no such line.
+func (f *SourceFile) Line(line int) (string, bool) {
+	if line < 1 || line > len(f.lines) {
+		return "", false
+	}
+
+	end := len(f.source)
+	if line < len(f.lines) {
+		end = f.lines[line] - 1
+	}
+
+	return strings.TrimSuffix(string(f.source[f.lines[line-1]:end]), "\r"), true
+}
+
+// FileSet is a set of source files, which diagnostics can refer to. Problem
+// handler with a file set shows code of any registered file, so one handler
+// can report problems of all files of a build.
+type FileSet struct {
+	files map[string]*SourceFile
+
+	// files in the order they were added
+	list []*SourceFile
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{files: map[string]*SourceFile{}}
+}
+
+// AddFile registers content of the file with the path used in locations of
+// its code and returns the file. File added again with the same path replaces
+// the previous one.
+func (s *FileSet) AddFile(path string, source []byte) *SourceFile {
+	file := &SourceFile{path: path, source: source, lines: []int{0}}
+
+	for i, c := range source {
+		if c == '\n' {
+			file.lines = append(file.lines, i+1)
+		}
+	}
+
+	if previous, ok := s.files[path]; ok {
+		for i, f := range s.list {
+			if f == previous {
+				s.list[i] = file
+			}
+		}
+	} else {
+		s.list = append(s.list, file)
+	}
+
+	s.files[path] = file
+	return file
+}
+
+// File returns file with the path or nil if it is not registered.
+func (s *FileSet) File(path string) *SourceFile {
+	if s == nil {
+		return nil
+	}
+
+	return s.files[path]
+}
+
+// Files returns registered files in the order they were added.
+func (s *FileSet) Files() []*SourceFile {
+	return s.list
+}
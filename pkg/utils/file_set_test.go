@@ -0,0 +1,100 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSet(t *testing.T) {
+	files := NewFileSet()
+	a := files.AddFile("a.tiny", []byte("namespace \"app\";\r\n\r\n\tfun f() {}\n"))
+
+	assert.Equal(t, "a.tiny", a.Path())
+	assert.Equal(t, 4, a.LineCount())
+
+	for line, text := range []string{"namespace \"app\";", "", "\tfun f() {}", ""} {
+		got, ok := a.Line(line + 1)
+		assert.True(t, ok)
+		assert.Equal(t, text, got)
+	}
+
+	_, ok := a.Line(5)
+	assert.False(t, ok)
+
+	b := files.AddFile("b.tiny", []byte("namespace \"app\";"))
+	assert.Equal(t, b, files.File("b.tiny"))
+	assert.Nil(t, files.File("c.tiny"))
+
+	// adding file again replaces it
+	a = files.AddFile("a.tiny", []byte(""))
+	assert.Equal(t, []*SourceFile{a, b}, files.Files())
+
+	var nilSet *FileSet
+	assert.Nil(t, nilSet.File("a.tiny"))
+}
+
+func TestFileSetProblems(t *testing.T) {
+	files := NewFileSet()
+	files.AddFile("app/a.tiny", []byte("namespace \"app\";\nfun f() {}\n"))
+	files.AddFile("app/b.tiny", []byte("namespace \"app\";\n\tstruct f {}\n"))
+
+	location := func(path string, line int, column int, endColumn int) *CodeBlockLocation {
+		return &CodeBlockLocation{
+			StartLocation: &CodePointLocation{Filepath: path, Line: line, Column: column},
+			EndLocation:   &CodePointLocation{Filepath: path, Line: line, Column: endColumn},
+		}
+	}
+
+	var output bytes.Buffer
+
+	// one handler without its own source reports problems in both files
+	h := NewCodeProblemHandler()
+	h.SetWriter(&output)
+	h.SetFileSet(files)
+	h.AddCodeProblem(NewLocalError(location("app/b.tiny", 2, 1, 12), RedeclaredErr, "f", "app").
+		WithLabel(location("app/a.tiny", 2, 0, 10), "previous declaration of f is here").
+		WithLabel(location("app/c.tiny", 1, 0, 1), "unknown file"))
+	h.AddCodeProblem(NewGlobalError(NoSourceFilesErr, "lib").
+		WithLabel(location("app/a.tiny", 1, 10, 15), "imported here"))
+	h.PrintProblems()
+
+	assert.Equal(t, "app/b.tiny(2:1) error[E0033]: f redeclared in namespace app\n"+
+		"   |\n"+
+		" 2 |     struct f {}\n"+
+		"   |     ^~~~~~~~~~~\n"+
+		"   |\n"+
+		"  ::: app/a.tiny(2:0)\n"+
+		"   |\n"+
+		" 2 | fun f() {}\n"+
+		"   | ---------- previous declaration of f is here\n"+
+		"  ::: app/c.tiny(1:0): unknown file\n\n"+
+		"error[E0028]: no Tiny source files in lib\n"+
+		"  ::: app/a.tiny(1:10)\n"+
+		"   |\n"+
+		" 1 | namespace \"app\";\n"+
+		"   |           ----- imported here\n\n", output.String())
+}
@@ -0,0 +1,117 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import "sort"
+
+// Edit replaces code in the location with the text. Text is inserted if the
+// location is empty.
+type Edit struct {
+	Location *CodeBlockLocation
+	Text     string
+}
+
+// NewInsertion returns edit, which inserts the text before the code point.
+func NewInsertion(location *CodePointLocation, text string) *Edit {
+	return &Edit{
+		Location: &CodeBlockLocation{StartLocation: location.Copy(), EndLocation: location.Copy()},
+		Text:     text,
+	}
+}
+
+// NewReplacement returns edit, which replaces the code block with the text.
+func NewReplacement(location *CodeBlockLocation, text string) *Edit {
+	return &Edit{Location: location.Copy(), Text: text}
+}
+
+// Fix is a change of the source, which fixes a problem and can be applied
+// without looking at it, like insertion of a missing semicolon.
+type Fix struct {
+	Message string
+	Edits   []*Edit
+}
+
+// overlaps reports whether two edits change the same code. Insertions at the
+// same place overlap too, because their order is unknown.
+func (e *Edit) overlaps(other *Edit) bool {
+	start, end := e.Location.StartLocation.Index, e.Location.EndLocation.Index
+	otherStart, otherEnd := other.Location.StartLocation.Index, other.Location.EndLocation.Index
+
+	return start == otherStart || start < otherEnd && otherStart < end
+}
+
+// applicable reports whether edits of the fix are in the source of the given
+// length and don't overlap each other and already accepted edits.
+func (f *Fix) applicable(length int, accepted []*Edit) bool {
+	for i, edit := range f.Edits {
+		start, end := edit.Location.StartLocation.Index, edit.Location.EndLocation.Index
+		if start < 0 || start > end || end > length {
+			return false
+		}
+
+		for _, other := range accepted {
+			if edit.overlaps(other) {
+				return false
+			}
+		}
+
+		for _, other := range f.Edits[:i] {
+			if edit.overlaps(other) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ApplyFixes applies fixes to the source and returns the changed source with
+// amount of applied fixes. All edits of the fixes must be in the source. A fix
+// is skipped, if it changes code already changed by one of previous fixes, so
+// fixes should be applied again after the source is checked again.
+func ApplyFixes(source []byte, fixes []*Fix) ([]byte, int) {
+	applied := 0
+	edits := []*Edit{}
+
+	for _, fix := range fixes {
+		if fix.applicable(len(source), edits) {
+			edits = append(edits, fix.Edits...)
+			applied++
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Location.StartLocation.Index < edits[j].Location.StartLocation.Index
+	})
+
+	result := []byte{}
+	offset := 0
+
+	for _, edit := range edits {
+		result = append(result, source[offset:edit.Location.StartLocation.Index]...)
+		result = append(result, edit.Text...)
+		offset = edit.Location.EndLocation.Index
+	}
+
+	return append(result, source[offset:]...), applied
+}
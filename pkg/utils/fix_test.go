@@ -0,0 +1,62 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFixes(t *testing.T) {
+	source := []byte("var x = 1_\nvar y = 2")
+	at := func(index int) *CodePointLocation {
+		return &CodePointLocation{Index: index}
+	}
+
+	fixes := []*Fix{
+		{Message: "insert semicolon", Edits: []*Edit{NewInsertion(at(10), ";")}},
+		{Message: "remove misplaced underscores", Edits: []*Edit{NewReplacement(
+			&CodeBlockLocation{StartLocation: at(8), EndLocation: at(10)}, "1")}},
+
+		// conflicts with the first fix
+		{Message: "insert colon", Edits: []*Edit{NewInsertion(at(10), ":")}},
+
+		{Message: "rename", Edits: []*Edit{
+			NewReplacement(&CodeBlockLocation{StartLocation: at(4), EndLocation: at(5)}, "a"),
+			NewReplacement(&CodeBlockLocation{StartLocation: at(15), EndLocation: at(16)}, "b"),
+		}},
+		{Message: "insert semicolon", Edits: []*Edit{NewInsertion(at(20), ";")}},
+
+		// outside of the source
+		{Message: "insert semicolon", Edits: []*Edit{NewInsertion(at(21), ";")}},
+	}
+
+	fixed, applied := ApplyFixes(source, fixes)
+	assert.Equal(t, 4, applied)
+	assert.Equal(t, "var a = 1;\nvar b = 2;", string(fixed))
+
+	fixed, applied = ApplyFixes(source, nil)
+	assert.Equal(t, 0, applied)
+	assert.Equal(t, source, fixed)
+}
@@ -22,6 +22,31 @@
 
 package utils
 
+import "fmt"
+
+// Severity tells whether problem is an error or a warning.
+type Severity int
+
+const (
+	ErrorSeverity Severity = iota
+	WarningSeverity
+)
+
+func (s Severity) String() string {
+	if s == ErrorSeverity {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// Label marks a location related to the problem, for example the previous
+// declaration of a redeclared name.
+type Label struct {
+	Location *CodeBlockLocation
+	Message  string
+}
+
 // CodeProblem describes error/warning happened to be in code.
 type CodeProblem struct {
 	// If true, it is error, if false, it is warning.
@@ -38,6 +63,18 @@ type CodeProblem struct {
 
 	// Context. (vargs)
 	ctx []interface{}
+
+	// Description of the primary location, shown under it.
+	primaryLabel string
+
+	// Secondary locations, which explain the problem.
+	labels []*Label
+
+	notes []string
+	help  []string
+
+	// Changes of the source, which fix the problem.
+	fixes []*Fix
 }
 
 func NewLocalProblem(global bool, critical bool, location *CodeBlockLocation,
@@ -75,3 +112,140 @@ func NewGlobalWarning(code int, ctx ...interface{}) *CodeProblem {
 func NewGlobalError(code int, ctx ...interface{}) *CodeProblem {
 	return NewLocalProblem(true, true, nil, code, ctx...)
 }
+
+// Severity returns ErrorSeverity for errors and WarningSeverity for warnings.
+func (p *CodeProblem) Severity() Severity {
+	if p.critical {
+		return ErrorSeverity
+	}
+
+	return WarningSeverity
+}
+
+// Code returns code of the problem, one of the error codes for errors and one
+// of the warning codes for warnings.
+func (p *CodeProblem) Code() int {
+	return p.code
+}
+
+// ID returns public code of the problem, which is shown to users: `E0019`
+// for errors and `W0001` for warnings.
+func (p *CodeProblem) ID() string {
+	if p.critical {
+		return ErrorID(p.code)
+	}
+
+	return WarningID(p.code)
+}
+
+// Global reports whether the problem is not attached to any location.
+func (p *CodeProblem) Global() bool {
+	return p.global
+}
+
+// Location returns location of the problem (its primary span) or nil if the
+// problem is global.
+func (p *CodeProblem) Location() *CodeBlockLocation {
+	return p.location
+}
+
+// Message returns description of the problem.
+func (p *CodeProblem) Message() string {
+	if p.critical {
+		return fmt.Sprintf(error_messages[p.code], p.ctx...)
+	}
+
+	return fmt.Sprintf(warning_messages[p.code], p.ctx...)
+}
+
+// WithPrimaryLabel sets text shown under the location of the problem and
+// returns the problem.
+func (p *CodeProblem) WithPrimaryLabel(message string) *CodeProblem {
+	p.primaryLabel = message
+	return p
+}
+
+// WithLabel adds secondary location with its description to the problem and
+// returns the problem. The location may be in another file.
+func (p *CodeProblem) WithLabel(location *CodeBlockLocation, message string) *CodeProblem {
+	p.labels = append(p.labels, &Label{Location: location, Message: message})
+	return p
+}
+
+// WithNote adds a note, which is shown after the code of the problem, and
+// returns the problem.
+func (p *CodeProblem) WithNote(note string) *CodeProblem {
+	p.notes = append(p.notes, note)
+	return p
+}
+
+// WithHelp adds a suggestion how to fix the problem and returns the problem.
+func (p *CodeProblem) WithHelp(help string) *CodeProblem {
+	p.help = append(p.help, help)
+	return p
+}
+
+// WithFix adds a change of the source, which fixes the problem, and returns
+// the problem. The message describes the change, like "insert semicolon".
+func (p *CodeProblem) WithFix(message string, edits ...*Edit) *CodeProblem {
+	p.fixes = append(p.fixes, &Fix{Message: message, Edits: edits})
+	return p
+}
+
+// PrimaryLabel returns text shown under the location of the problem.
+func (p *CodeProblem) PrimaryLabel() string {
+	return p.primaryLabel
+}
+
+// Labels returns secondary locations of the problem.
+func (p *CodeProblem) Labels() []*Label {
+	return p.labels
+}
+
+// Notes returns notes of the problem.
+func (p *CodeProblem) Notes() []string {
+	return p.notes
+}
+
+// Help returns suggestions how to fix the problem.
+func (p *CodeProblem) Help() []string {
+	return p.help
+}
+
+// Fixes returns changes of the source, which fix the problem.
+func (p *CodeProblem) Fixes() []*Fix {
+	return p.fixes
+}
+
+// Moved returns copy of the problem, which locations, including locations of
+// labels and fixes, are changed by move. It keeps problems of code, which was
+// moved by an edit of the source, without finding them again.
+func (p *CodeProblem) Moved(move func(*CodePointLocation) *CodePointLocation) *CodeProblem {
+	moveBlock := func(location *CodeBlockLocation) *CodeBlockLocation {
+		return &CodeBlockLocation{StartLocation: move(location.StartLocation),
+			EndLocation: move(location.EndLocation)}
+	}
+
+	moved := *p
+	if !p.global {
+		moved.location = moveBlock(p.location)
+	}
+
+	moved.labels = nil
+	for _, label := range p.labels {
+		moved.labels = append(moved.labels,
+			&Label{Location: moveBlock(label.Location), Message: label.Message})
+	}
+
+	moved.fixes = nil
+	for _, fix := range p.fixes {
+		edits := []*Edit{}
+		for _, edit := range fix.Edits {
+			edits = append(edits, &Edit{Location: moveBlock(edit.Location), Text: edit.Text})
+		}
+
+		moved.fixes = append(moved.fixes, &Fix{Message: fix.Message, Edits: edits})
+	}
+
+	return &moved
+}
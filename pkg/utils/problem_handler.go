@@ -27,6 +27,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
@@ -36,11 +38,29 @@ type CodeProblemHandler struct {
 	Ok             bool
 	colorfulOutput bool
 
+	// where problems are printed, os.Stderr by default
+	writer   io.Writer
+	format   DiagnosticsFormat
+	warnings *WarningSettings
+
+	// amount of columns between tab stops in the shown code
+	tabWidth int
+
+	// files, which code of problems is shown from, nil if only source of
+	// the handler is known
+	files *FileSet
+
 	source       []byte
 	sourceLength int
 
 	problems []*CodeProblem
 
+	// keys of added problems used to drop duplicates
+	seen map[string]bool
+
+	// maximum amount of printed errors, 0 if there's no limit
+	maxErrors int
+
 	lineStartOffsets *[]int
 	lineEndOffsets   *[]int
 }
@@ -49,9 +69,14 @@ func NewCodeProblemHandler() *CodeProblemHandler {
 	return &CodeProblemHandler{
 		Ok:             true,
 		colorfulOutput: false,
+		writer:         os.Stderr,
+		format:         HumanFormat,
+		warnings:       NewWarningSettings(),
+		tabWidth:       DefaultTabWidth,
 		source:         []byte(""),
 		sourceLength:   0,
 		problems:       []*CodeProblem{},
+		seen:           map[string]bool{},
 
 		lineStartOffsets: &[]int{},
 		lineEndOffsets:   &[]int{},
@@ -62,6 +87,42 @@ func (h *CodeProblemHandler) SetColorfulOutput() {
 	h.colorfulOutput = true
 }
 
+// SetWriter sets writer, which problems are printed to.
+func (h *CodeProblemHandler) SetWriter(writer io.Writer) {
+	h.writer = writer
+}
+
+// SetDiagnosticsFormat sets format of printed problems. Colorful output is
+// used only in HumanFormat.
+func (h *CodeProblemHandler) SetDiagnosticsFormat(format DiagnosticsFormat) {
+	h.format = format
+}
+
+// SetFileSet sets files, which problems can be reported in. Code of a problem
+// is looked up in the file set by path of its location, source set with
+// SetSource is used for files missing in the set.
+func (h *CodeProblemHandler) SetFileSet(files *FileSet) {
+	h.files = files
+}
+
+// FileSet returns files known to the handler or nil.
+func (h *CodeProblemHandler) FileSet() *FileSet {
+	return h.files
+}
+
+// SetMaxErrors sets maximum amount of printed errors. Errors after the first
+// max ones are omitted, 0 removes the limit.
+func (h *CodeProblemHandler) SetMaxErrors(max int) {
+	h.maxErrors = max
+}
+
+// SetTabWidth sets amount of columns between tab stops in the shown code.
+func (h *CodeProblemHandler) SetTabWidth(width int) {
+	if width > 0 {
+		h.tabWidth = width
+	}
+}
+
 func (h *CodeProblemHandler) SetLineStartOffsets(lineStartOffsets *[]int) {
 	h.lineStartOffsets = lineStartOffsets
 }
@@ -70,108 +131,366 @@ func (h *CodeProblemHandler) SetLineEndOffsets(lineEndOffsets *[]int) {
 	h.lineEndOffsets = lineEndOffsets
 }
 
+// SetWarningSettings sets which warnings are reported and whether they are
+// treated as errors.
+func (h *CodeProblemHandler) SetWarningSettings(settings *WarningSettings) {
+	h.warnings = settings
+}
+
+// WarningSettings returns settings of warnings used by the handler.
+func (h *CodeProblemHandler) WarningSettings() *WarningSettings {
+	return h.warnings
+}
+
+// AddCodeProblem adds problem to the handler. Warnings, which are disabled or
+// suppressed by a comment in the source, are dropped, as well as problems
+// identical to already added ones.
 func (h *CodeProblemHandler) AddCodeProblem(problem *CodeProblem) {
-	if problem.critical {
+	if !problem.critical && (!h.warnings.Enabled(problem.code) || h.suppressed(problem)) {
+		return
+	}
+
+	key := problemKey(problem)
+	if h.seen[key] {
+		return
+	}
+
+	h.seen[key] = true
+
+	// with -Werror warnings fail compilation too
+	if problem.critical || h.warnings.WarningsAreErrors() {
 		h.Ok = false
 	}
 
 	h.problems = append(h.problems, problem)
 }
 
+// Problems returns problems in the order they were reported.
+func (h *CodeProblemHandler) Problems() []*CodeProblem {
+	return h.problems
+}
+
+// problemKey returns string, which is equal for problems with the same
+// severity, code and message reported at the same span.
+func problemKey(problem *CodeProblem) string {
+	key := fmt.Sprintf("%v %d %s", problem.critical, problem.code, problem.Message())
+	if problem.global {
+		return key
+	}
+
+	start := problem.location.StartLocation
+	end := problem.location.EndLocation
+
+	return fmt.Sprintf("%s %s(%d:%d-%d:%d)", key, start.Filepath,
+		start.Line, start.Column, end.Line, end.Column)
+}
+
+// sortedProblems returns problems sorted by file and position, global
+// problems go last. Errors exceeding the limit are omitted, their amount is
+// returned as well.
+func (h *CodeProblemHandler) sortedProblems() ([]*CodeProblem, int) {
+	problems := make([]*CodeProblem, len(h.problems))
+	copy(problems, h.problems)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.global || b.global {
+			return !a.global && b.global
+		}
+
+		x, y := a.location.StartLocation, b.location.StartLocation
+		if x.Filepath != y.Filepath {
+			return x.Filepath < y.Filepath
+		}
+
+		if x.Line != y.Line {
+			return x.Line < y.Line
+		}
+
+		return x.Column < y.Column
+	})
+
+	if h.maxErrors <= 0 {
+		return problems, 0
+	}
+
+	shown := []*CodeProblem{}
+	errors, omitted := 0, 0
+
+	for _, problem := range problems {
+		if problem.critical {
+			if errors == h.maxErrors {
+				omitted++
+				continue
+			}
+
+			errors++
+		}
+
+		shown = append(shown, problem)
+	}
+
+	return shown, omitted
+}
+
 func (h *CodeProblemHandler) SetSource(source []byte) {
 	h.source = source
 	h.sourceLength = len(source)
 }
 
-func (h *CodeProblemHandler) printFormattedCodeBlock(
-	start int, end int, c *color.Color) {
-	s := strings.Replace(
-		string(
-			h.source[start:end],
-		),
-		"\t",
-		" ",
-		-1,
-	)
+// span is a location shown in the code block of a problem.
+type span struct {
+	location *CodeBlockLocation
+	label    string
+	primary  bool
+}
 
-	if h.colorfulOutput {
-		c.Fprint(os.Stderr, s)
-	} else {
-		fmt.Fprint(os.Stderr, s)
+// maxSpanLines is the amount of lines of a span, which are shown completely.
+// Only first and last two lines of longer spans are shown.
+const maxSpanLines = 4
+
+// sourceLine returns text of the line of the file without line break. Lines of
+// files registered in the file set are always known. Without the file set,
+// source of the handler is the file of the problem. Returns false if the line
+// is not known to the handler.
+func (h *CodeProblemHandler) sourceLine(path string, line int,
+	problem *CodeProblem) (string, bool) {
+	if file := h.files.File(path); file != nil {
+		return file.Line(line)
+	}
+
+	if problem.global || path != problem.location.StartLocation.Filepath {
+		return "", false
+	}
+
+	if line < 1 || line > len(*h.lineStartOffsets) || line > len(*h.lineEndOffsets) {
+		return "", false
+	}
+
+	start := (*h.lineStartOffsets)[line-1]
+	end := (*h.lineEndOffsets)[line-1] + 1
+
+	if end > h.sourceLength {
+		end = h.sourceLength
+	}
+
+	// empty line
+	if end < start {
+		end = start
 	}
+
+	return strings.TrimSuffix(string(h.source[start:end]), "\r"), true
 }
 
-func (h *CodeProblemHandler) printCodeBlock(problem *CodeProblem) {
-	lineNumberStr := fmt.Sprintf("%d", problem.location.StartLocation.Line)
-	lineNumberStrLength := len(lineNumberStr)
+// shownLines returns lines of the span, which are shown in the code block.
+func (s *span) shownLines() []int {
+	start := s.location.StartLocation.Line
+	end := s.location.EndLocation.Line
 
-	spacesBeforeBar := ""
-	for i := 0; i < lineNumberStrLength+2; i++ {
-		spacesBeforeBar += " "
+	lines := []int{}
+	for line := start; line <= end; line++ {
+		if end-start+1 <= maxSpanLines || line < start+2 || line > end-2 {
+			lines = append(lines, line)
+		}
 	}
 
-	fmt.Fprint(os.Stderr, spacesBeforeBar)
-	fmt.Fprint(os.Stderr, "|\n")
-	fmt.Fprintf(os.Stderr, " %d | ", problem.location.StartLocation.Line)
+	return lines
+}
 
-	w := color.New(color.FgWhite)
+// markers returns display column, where markers of the span start on the
+// line, and amount of markers, so they stay under wide characters and tabs.
+// Lines in the middle of the span are marked from the first non-space
+// character.
+func (s *span) markers(line int, text string, tabWidth int) (int, int) {
+	from := len(text) - len(strings.TrimLeft(text, " \t"))
+	if line == s.location.StartLocation.Line {
+		from = RuneColumnToByte(text, s.location.StartLocation.Column)
+	}
 
-	var prc *color.Color
-	if problem.critical {
-		prc = color.New(color.FgRed, color.Bold)
+	to := len(text)
+	if line == s.location.EndLocation.Line {
+		to = RuneColumnToByte(text, s.location.EndLocation.Column)
+	}
+
+	start := ByteToDisplayColumn(text, from, tabWidth)
+	return start, ByteToDisplayColumn(text, to, tabWidth) - start
+}
+
+func (h *CodeProblemHandler) spanColor(s *span, critical bool) *color.Color {
+	if !s.primary {
+		return color.New(color.FgBlue, color.Bold)
+	}
+
+	if critical {
+		return color.New(color.FgRed, color.Bold)
+	}
+
+	return color.New(color.FgYellow)
+}
+
+func (h *CodeProblemHandler) print(c *color.Color, s string) {
+	if h.colorfulOutput {
+		c.Fprint(h.writer, s)
 	} else {
-		prc = color.New(color.FgYellow)
+		fmt.Fprint(h.writer, s)
 	}
+}
 
-	h.printFormattedCodeBlock(
-		(*h.lineStartOffsets)[problem.location.StartLocation.Line-1],
-		problem.location.StartLocation.Index,
-		w)
+// printMarkers prints line with markers of the span under the code line.
+func (h *CodeProblemHandler) printMarkers(s *span, critical bool, line int,
+	text string, gutter string) {
+	from, length := s.markers(line, text, h.tabWidth)
 
-	h.printFormattedCodeBlock(
-		problem.location.StartLocation.Index,
-		problem.location.EndLocation.Index,
-		prc)
+	first := line == s.location.StartLocation.Line
+	last := line == s.location.EndLocation.Line
 
-	if problem.location.StartLocation.Index < h.sourceLength {
-		h.printFormattedCodeBlock(
-			problem.location.EndLocation.Index,
-			(*h.lineEndOffsets)[problem.location.EndLocation.Line-1]+1,
-			w)
+	if length <= 0 {
+		// nothing to mark in the middle of the span
+		if !first && !last {
+			return
+		}
+
+		length = 1
 	}
 
-	fmt.Fprint(os.Stderr, "\n")
+	markers := strings.Repeat("-", length)
+	if s.primary && first {
+		markers = "^" + strings.Repeat("~", length-1)
+	} else if s.primary {
+		markers = strings.Repeat("~", length)
+	}
 
-	spaceBeforeArrow := ""
-	for i := 0; i < problem.location.StartLocation.Column+1; i++ {
-		spaceBeforeArrow += " "
+	if last && s.label != "" {
+		markers += " " + s.label
 	}
 
-	fmt.Fprint(os.Stderr, spacesBeforeBar)
-	fmt.Fprint(os.Stderr, "|")
-	fmt.Fprint(os.Stderr, spaceBeforeArrow)
+	fmt.Fprintf(h.writer, "%s| %s", gutter, strings.Repeat(" ", from))
+	h.print(h.spanColor(s, critical), markers)
+	fmt.Fprint(h.writer, "\n")
+}
 
-	tildaSymbols := ""
-	for i := 0; i < problem.location.EndLocation.Column-problem.location.StartLocation.Column-1; i++ {
-		tildaSymbols += "~"
+// spanGroup is a set of spans in one file, which are shown together.
+type spanGroup struct {
+	path  string
+	spans []*span
+	lines []int
+}
+
+// printCodeBlock prints lines of the problem and its labels with markers
+// under them grouped by files, references to labels in files unknown to the
+// handler, notes and help. Messages of fixes are shown as help.
+func (h *CodeProblemHandler) printCodeBlock(problem *CodeProblem) {
+	groups := []*spanGroup{}
+	references := []*Label{}
+
+	add := func(s *span) bool {
+		path := s.location.StartLocation.Filepath
+		if _, ok := h.sourceLine(path, s.location.StartLocation.Line, problem); !ok {
+			return false
+		}
+
+		for _, g := range groups {
+			if g.path == path {
+				g.spans = append(g.spans, s)
+				return true
+			}
+		}
+
+		groups = append(groups, &spanGroup{path: path, spans: []*span{s}})
+		return true
 	}
 
-	if !h.colorfulOutput {
-		fmt.Fprint(os.Stderr, "^")
-		fmt.Fprint(os.Stderr, tildaSymbols)
-	} else {
-		var c *color.Color
-		if problem.critical {
-			c = color.New(color.FgRed, color.Bold)
-		} else {
-			c = color.New(color.FgYellow)
+	if !problem.global {
+		add(&span{location: problem.location, label: problem.primaryLabel, primary: true})
+	}
+
+	for _, label := range problem.labels {
+		if !add(&span{location: label.Location, label: label.Message}) {
+			references = append(references, label)
+		}
+	}
+
+	last := 0
+	for _, g := range groups {
+		shown := map[int]bool{}
+		for _, s := range g.spans {
+			for _, line := range s.shownLines() {
+				if _, ok := h.sourceLine(g.path, line, problem); ok && !shown[line] {
+					shown[line] = true
+					g.lines = append(g.lines, line)
+				}
+			}
+		}
+
+		sort.Ints(g.lines)
+
+		if line := g.lines[len(g.lines)-1]; line > last {
+			last = line
+		}
+	}
+
+	width := len(strconv.Itoa(last))
+	gutter := strings.Repeat(" ", width+2)
+
+	for i, g := range groups {
+		// files other than the file of the problem are named
+		if i > 0 || !g.spans[0].primary {
+			if i > 0 {
+				fmt.Fprintf(h.writer, "%s|\n", gutter)
+			}
+
+			start := g.spans[0].location.StartLocation
+			fmt.Fprintf(h.writer, "%s::: %s(%d:%d)\n", gutter[1:], g.path, start.Line, start.Column)
+		}
+
+		fmt.Fprintf(h.writer, "%s|\n", gutter)
+
+		for j, line := range g.lines {
+			if j > 0 && line > g.lines[j-1]+1 {
+				fmt.Fprint(h.writer, "...\n")
+			}
+
+			text, _ := h.sourceLine(g.path, line, problem)
+			fmt.Fprintf(h.writer, " %*d | %s\n", width, line, expandTabs(text, h.tabWidth))
+
+			for _, s := range g.spans {
+				if s.location.StartLocation.Line <= line && line <= s.location.EndLocation.Line {
+					h.printMarkers(s, problem.critical, line, text, gutter)
+				}
+			}
 		}
+	}
 
-		c.Fprint(os.Stderr, "^")
-		c.Fprint(os.Stderr, tildaSymbols)
+	for _, label := range references {
+		fmt.Fprintf(h.writer, "%s::: %s(%d:%d): %s\n", gutter[1:],
+			label.Location.StartLocation.Filepath,
+			label.Location.StartLocation.Line, label.Location.StartLocation.Column,
+			label.Message)
 	}
 
-	fmt.Fprintf(os.Stderr, "\n\n")
+	help := problem.help
+	for _, fix := range problem.fixes {
+		help = append(help, fix.Message)
+	}
+
+	if len(groups) > 0 && len(problem.notes)+len(help) > 0 {
+		fmt.Fprintf(h.writer, "%s|\n", gutter)
+	}
+
+	bold := color.New(color.Bold)
+	for _, note := range problem.notes {
+		h.print(bold, gutter[1:]+"= note:")
+		fmt.Fprintf(h.writer, " %s\n", note)
+	}
+
+	for _, help := range help {
+		h.print(bold, gutter[1:]+"= help:")
+		fmt.Fprintf(h.writer, " %s\n", help)
+	}
+
+	if len(groups)+len(references)+len(problem.notes)+len(help) > 0 {
+		fmt.Fprint(h.writer, "\n")
+	}
 }
 
 func readLine(r io.Reader, lineNum int) (line string, lastLine int, err error) {
@@ -186,41 +505,34 @@ func readLine(r io.Reader, lineNum int) (line string, lastLine int, err error) {
 }
 
 func (h *CodeProblemHandler) printError(problem *CodeProblem) {
-	if problem.global {
-		if h.colorfulOutput {
-			color.New(color.FgRed, color.Bold).Fprint(os.Stderr, "error:")
-		} else {
-			fmt.Fprint(os.Stderr, "error:")
-		}
-
-		fmt.Fprintf(os.Stderr, " %s\n",
-			fmt.Sprintf(error_messages[problem.code], problem.ctx...))
-	} else {
-		fmt.Fprintf(os.Stderr, "%s(%d:%d) ",
+	if !problem.global {
+		fmt.Fprintf(h.writer, "%s(%d:%d) ",
 			problem.location.StartLocation.Filepath,
 			problem.location.StartLocation.Line, problem.location.StartLocation.Column)
+	}
 
-		if h.colorfulOutput {
-			color.New(color.FgRed, color.Bold).Fprint(os.Stderr, "error:")
-		} else {
-			fmt.Fprint(os.Stderr, "error:")
-		}
-
-		fmt.Fprintf(os.Stderr, " %s\n",
-			fmt.Sprintf(error_messages[problem.code], problem.ctx...))
-		h.printCodeBlock(problem)
+	label := fmt.Sprintf("error[%s]:", problem.ID())
+	if h.colorfulOutput {
+		color.New(color.FgRed, color.Bold).Fprint(h.writer, label)
+	} else {
+		fmt.Fprint(h.writer, label)
 	}
+
+	fmt.Fprintf(h.writer, " %s\n", problem.Message())
+
+	h.printCodeBlock(problem)
 }
 
 func (h *CodeProblemHandler) printWarning(problem *CodeProblem) {
 	if problem.global {
-		fmt.Fprintf(os.Stderr, "warning: %s\n",
-			fmt.Sprintf(warning_messages[problem.code], problem.ctx...))
+		fmt.Fprintf(h.writer, "warning[%s]: %s [-W%s]\n",
+			problem.ID(), problem.Message(), WarningName(problem.code))
+		h.printCodeBlock(problem)
 	} else {
-		fmt.Fprintf(os.Stderr, "%s(%d:%d) warning: %s\n",
+		fmt.Fprintf(h.writer, "%s(%d:%d) warning[%s]: %s [-W%s]\n",
 			problem.location.StartLocation.Filepath,
 			problem.location.StartLocation.Line, problem.location.StartLocation.Column,
-			fmt.Sprintf(warning_messages[problem.code], problem.ctx...))
+			problem.ID(), problem.Message(), WarningName(problem.code))
 		h.printCodeBlock(problem)
 	}
 }
@@ -233,21 +545,50 @@ func (h *CodeProblemHandler) printProblem(problem *CodeProblem) {
 	}
 }
 
+// PrintProblems prints problems sorted by file and position. If errors
+// exceed the limit set with SetMaxErrors, the rest are omitted and, in
+// HumanFormat and GCCFormat, a line telling how many there were is printed.
 func (h *CodeProblemHandler) PrintProblems() {
-	for _, problem := range h.problems {
-		h.printProblem(problem)
+	problems, omitted := h.sortedProblems()
+
+	switch h.format {
+	case JSONFormat:
+		h.printJSON(problems)
+	case SARIFFormat:
+		h.printSARIF(problems)
+	case GCCFormat:
+		h.printGCC(problems)
+
+		if omitted > 0 {
+			fmt.Fprintf(h.writer, "tinyc: note: too many errors, %d more not shown\n", omitted)
+		}
+	default:
+		for _, problem := range problems {
+			h.printProblem(problem)
+		}
+
+		if omitted > 0 {
+			message := fmt.Sprintf("error: too many errors, %d more not shown", omitted)
+			if h.colorfulOutput {
+				color.New(color.FgRed, color.Bold).Fprintln(h.writer, message)
+			} else {
+				fmt.Fprintln(h.writer, message)
+			}
+		}
 	}
 }
 
+// PrintDiagnostics prints problems and, in HumanFormat, a line telling that
+// compilation was aborted if there are errors.
 func (h *CodeProblemHandler) PrintDiagnostics() {
 	h.PrintProblems()
 
-	if !h.Ok {
+	if !h.Ok && h.format == HumanFormat {
 		if h.colorfulOutput {
 			color.New(color.FgRed, color.Bold).Fprintln(
-				os.Stderr, "error: aborting due to previous error(-s)")
+				h.writer, "error: aborting due to previous error(-s)")
 		} else {
-			fmt.Fprintln(os.Stderr, "error: aborting due to previous error(-s)")
+			fmt.Fprintln(h.writer, "error: aborting due to previous error(-s)")
 		}
 	}
 }
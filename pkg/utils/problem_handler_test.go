@@ -0,0 +1,280 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblems(t *testing.T) {
+	source := []byte("fun f(a i32) {}")
+	location := &CodeBlockLocation{
+		StartLocation: &CodePointLocation{Filepath: "a.tiny", Index: 8, Line: 1, Column: 8},
+		EndLocation:   &CodePointLocation{Filepath: "a.tiny", Index: 11, Line: 1, Column: 11},
+	}
+
+	var output bytes.Buffer
+
+	h := NewCodeProblemHandler()
+	h.SetWriter(&output)
+	h.SetSource(source)
+	h.SetLineStartOffsets(&[]int{0})
+	h.SetLineEndOffsets(&[]int{len(source) - 1})
+
+	h.AddCodeProblem(NewLocalError(location, UnexpectedTokenErr, "colon", "i32 keyword"))
+	h.AddCodeProblem(NewGlobalError(NoSourceFilesErr, "app"))
+	assert.False(t, h.Ok)
+
+	problems := h.Problems()
+	assert.Equal(t, 2, len(problems))
+
+	assert.Equal(t, ErrorSeverity, problems[0].Severity())
+	assert.Equal(t, "error", problems[0].Severity().String())
+	assert.Equal(t, UnexpectedTokenErr, problems[0].Code())
+	assert.Equal(t, "E0019", problems[0].ID())
+	assert.False(t, problems[0].Global())
+	assert.Equal(t, location, problems[0].Location())
+
+	assert.True(t, problems[1].Global())
+	assert.Nil(t, problems[1].Location())
+	assert.Equal(t, "no Tiny source files in app", problems[1].Message())
+
+	h.PrintDiagnostics()
+	assert.Equal(t, "a.tiny(1:8) error[E0019]: "+problems[0].Message()+"\n"+
+		"   |\n"+
+		" 1 | fun f(a i32) {}\n"+
+		"   |         ^~~\n\n"+
+		"error[E0028]: no Tiny source files in app\n"+
+		"error: aborting due to previous error(-s)\n", output.String())
+}
+
+func TestSortingAndLimit(t *testing.T) {
+	location := func(path string, line int, column int) *CodeBlockLocation {
+		return &CodeBlockLocation{
+			StartLocation: &CodePointLocation{Filepath: path, Line: line, Column: column},
+			EndLocation:   &CodePointLocation{Filepath: path, Line: line, Column: column + 1},
+		}
+	}
+
+	printProblems := func(maxErrors int) string {
+		var output bytes.Buffer
+
+		h := NewCodeProblemHandler()
+		h.SetWriter(&output)
+		h.SetDiagnosticsFormat(GCCFormat)
+		h.SetMaxErrors(maxErrors)
+
+		h.AddCodeProblem(NewGlobalError(NoSourceFilesErr, "lib"))
+		h.AddCodeProblem(NewLocalError(location("b.tiny", 1, 0), UnexpectedTokenErr, "semicolon", "EOF"))
+		h.AddCodeProblem(NewLocalError(location("a.tiny", 2, 3), UnexpectedTokenErr, "semicolon", "EOF"))
+		h.AddCodeProblem(NewLocalError(location("a.tiny", 1, 5), UnexpectedTokenErr, "colon", "EOF"))
+		h.AddCodeProblem(NewLocalError(location("a.tiny", 1, 5), UnexpectedTokenErr, "colon", "EOF"))
+		h.AddCodeProblem(NewLocalWarning(location("a.tiny", 1, 2), UnreachableCodeWarn))
+
+		// duplicates are dropped, but are kept in the order of reporting
+		assert.Equal(t, 5, len(h.Problems()))
+
+		h.PrintProblems()
+		return output.String()
+	}
+
+	assert.Equal(t, "a.tiny:1:3: warning: unreachable code [W0003, -Wunreachable-code]\n"+
+		"a.tiny:1:6: error: expected token to be colon, got EOF instead [E0019]\n"+
+		"a.tiny:2:4: error: expected token to be semicolon, got EOF instead [E0019]\n"+
+		"b.tiny:1:1: error: expected token to be semicolon, got EOF instead [E0019]\n"+
+		"tinyc: error: no Tiny source files in lib [E0028]\n", printProblems(0))
+
+	assert.Equal(t, "a.tiny:1:3: warning: unreachable code [W0003, -Wunreachable-code]\n"+
+		"a.tiny:1:6: error: expected token to be colon, got EOF instead [E0019]\n"+
+		"a.tiny:2:4: error: expected token to be semicolon, got EOF instead [E0019]\n"+
+		"tinyc: note: too many errors, 2 more not shown\n", printProblems(2))
+}
+
+func TestDiagnosticsFormats(t *testing.T) {
+	location := &CodeBlockLocation{
+		StartLocation: &CodePointLocation{Filepath: "app/a.tiny", Index: 8, Line: 1, Column: 8},
+		EndLocation:   &CodePointLocation{Filepath: "app/a.tiny", Index: 11, Line: 1, Column: 11},
+	}
+
+	printProblems := func(format DiagnosticsFormat) string {
+		var output bytes.Buffer
+
+		h := NewCodeProblemHandler()
+		h.SetWriter(&output)
+		h.SetDiagnosticsFormat(format)
+		h.AddCodeProblem(NewLocalError(location, UnexpectedTokenErr, "colon", "i32 keyword"))
+		h.AddCodeProblem(NewGlobalError(NoSourceFilesErr, "app"))
+		h.PrintDiagnostics()
+
+		return output.String()
+	}
+
+	assert.Equal(t,
+		"app/a.tiny:1:9: error: expected token to be colon, got i32 keyword instead [E0019]\n"+
+			"tinyc: error: no Tiny source files in app [E0028]\n",
+		printProblems(GCCFormat))
+
+	assert.Equal(t,
+		`{"file":"app/a.tiny","line":1,"column":9,"endLine":1,"endColumn":12,`+
+			`"severity":"error","code":"E0019",`+
+			`"message":"expected token to be colon, got i32 keyword instead"}`+"\n"+
+			`{"severity":"error","code":"E0028","message":"no Tiny source files in app"}`+"\n",
+		printProblems(JSONFormat))
+
+	log := &sarifLog{}
+	assert.NoError(t, json.Unmarshal([]byte(printProblems(SARIFFormat)), log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Equal(t, 2, len(log.Runs[0].Results))
+	assert.Equal(t, "E0019", log.Runs[0].Results[0].RuleID)
+	assert.Equal(t, sarifRegion{StartLine: 1, StartColumn: 9, EndLine: 1, EndColumn: 12},
+		log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region)
+	assert.Empty(t, log.Runs[0].Results[1].Locations)
+
+	for _, format := range []DiagnosticsFormat{HumanFormat, JSONFormat, SARIFFormat, GCCFormat} {
+		parsed, err := ParseDiagnosticsFormat(format.String())
+		assert.NoError(t, err)
+		assert.Equal(t, format, parsed)
+	}
+
+	_, err := ParseDiagnosticsFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestErrorID(t *testing.T) {
+	assert.Equal(t, "E0019", ErrorID(UnexpectedTokenErr))
+	assert.Equal(t, "W0003", WarningID(3))
+
+	for _, id := range []string{"E0019", "e0019", "0019", "19"} {
+		code, err := ParseErrorID(id)
+		assert.NoError(t, err)
+		assert.Equal(t, UnexpectedTokenErr, code)
+	}
+
+	for _, id := range []string{"", "E", "E-1", "E+19", "W0019", "E9999"} {
+		_, err := ParseErrorID(id)
+		assert.Error(t, err)
+	}
+}
+
+// lineOffsets returns offsets of line starts and of last characters of lines
+// like the lexer does.
+func lineOffsets(source string) (*[]int, *[]int) {
+	starts, ends := []int{}, []int{}
+
+	offset := 0
+	for _, line := range strings.Split(source, "\n") {
+		starts = append(starts, offset)
+		ends = append(ends, offset+len(line)-1)
+		offset += len(line) + 1
+	}
+
+	return &starts, &ends
+}
+
+func TestLabels(t *testing.T) {
+	source := "fun run() {}\n\nstruct run {\n\ta: i32;\n\tb: i32;\n\tc: i32;\n\td: i32;\n}"
+	location := func(path string, line int, column int, endLine int, endColumn int) *CodeBlockLocation {
+		return &CodeBlockLocation{
+			StartLocation: &CodePointLocation{Filepath: path, Line: line, Column: column},
+			EndLocation:   &CodePointLocation{Filepath: path, Line: endLine, Column: endColumn},
+		}
+	}
+
+	printProblems := func(format DiagnosticsFormat) string {
+		var output bytes.Buffer
+
+		h := NewCodeProblemHandler()
+		h.SetWriter(&output)
+		h.SetDiagnosticsFormat(format)
+		h.SetSource([]byte(source))
+
+		starts, ends := lineOffsets(source)
+		h.SetLineStartOffsets(starts)
+		h.SetLineEndOffsets(ends)
+
+		h.AddCodeProblem(NewLocalError(location("a.tiny", 3, 0, 8, 1), RedeclaredErr, "run", "app").
+			WithPrimaryLabel("redeclared here").
+			WithLabel(location("a.tiny", 1, 0, 1, 12), "previous declaration of run is here").
+			WithLabel(location("b.tiny", 2, 4, 2, 7), "run is also declared here").
+			WithNote("names of declarations in a namespace must be unique").
+			WithHelp("rename one of the declarations"))
+		h.AddCodeProblem(NewGlobalError(NoSourceFilesErr, "app").WithNote("global note"))
+		h.PrintProblems()
+
+		return output.String()
+	}
+
+	assert.Equal(t, "a.tiny(3:0) error[E0033]: run redeclared in namespace app\n"+
+		"   |\n"+
+		" 1 | fun run() {}\n"+
+		"   | ------------ previous declaration of run is here\n"+
+		"...\n"+
+		" 3 | struct run {\n"+
+		"   | ^~~~~~~~~~~~\n"+
+		" 4 |     a: i32;\n"+
+		"   |     ~~~~~~~\n"+
+		"...\n"+
+		" 7 |     d: i32;\n"+
+		"   |     ~~~~~~~\n"+
+		" 8 | }\n"+
+		"   | ~ redeclared here\n"+
+		"  ::: b.tiny(2:4): run is also declared here\n"+
+		"   |\n"+
+		"  = note: names of declarations in a namespace must be unique\n"+
+		"  = help: rename one of the declarations\n\n"+
+		"error[E0028]: no Tiny source files in app\n"+
+		"  = note: global note\n\n",
+		printProblems(HumanFormat))
+
+	assert.Equal(t, "a.tiny:3:1: error: run redeclared in namespace app [E0033]\n"+
+		"a.tiny:1:1: note: previous declaration of run is here\n"+
+		"b.tiny:2:5: note: run is also declared here\n"+
+		"a.tiny:3:1: note: names of declarations in a namespace must be unique\n"+
+		"a.tiny:3:1: help: rename one of the declarations\n"+
+		"tinyc: error: no Tiny source files in app [E0028]\n"+
+		"tinyc: note: global note\n",
+		printProblems(GCCFormat))
+
+	problem := &jsonProblem{}
+	assert.NoError(t, json.NewDecoder(strings.NewReader(printProblems(JSONFormat))).Decode(problem))
+	assert.Equal(t, "redeclared here", problem.Label)
+	assert.Equal(t, &jsonLabel{
+		jsonSpan: jsonSpan{File: "b.tiny", Line: 2, Column: 5, EndLine: 2, EndColumn: 8},
+		Message:  "run is also declared here"}, problem.Labels[1])
+	assert.Equal(t, []string{"names of declarations in a namespace must be unique"}, problem.Notes)
+	assert.Equal(t, []string{"rename one of the declarations"}, problem.Help)
+
+	log := &sarifLog{}
+	assert.NoError(t, json.Unmarshal([]byte(printProblems(SARIFFormat)), log))
+	assert.Equal(t, "run redeclared in namespace app\n"+
+		"note: names of declarations in a namespace must be unique\n"+
+		"help: rename one of the declarations", log.Runs[0].Results[0].Message.Text)
+	assert.Equal(t, 2, len(log.Runs[0].Results[0].RelatedLocations))
+	assert.Equal(t, "previous declaration of run is here",
+		log.Runs[0].Results[0].RelatedLocations[0].Message.Text)
+}
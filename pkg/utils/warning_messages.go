@@ -22,4 +22,69 @@
 
 package utils
 
-var warning_messages = map[int]string{}
+import "fmt"
+
+// Warning codes are shown to users as W0001 (see WarningID), so like error
+// codes they must never change.
+const (
+	// UnusedVariableWarn is reserved for unused variables. They are not
+	// checked yet, so it's never reported and has no name for `-W` flags.
+	UnusedVariableWarn    = 0
+	UnusedImportWarn      = 1
+	ShadowingWarn         = 2
+	UnreachableCodeWarn   = 3
+	IncompleteBindingWarn = 4
+	LeakWarn              = 5
+	DoubleDestroyWarn     = 6
+	UseAfterDestroyWarn   = 7
+	DestroyNotHeapWarn    = 8
+	IncompleteDestroyWarn = 9
+)
+
+var warning_messages = map[int]string{
+	UnusedVariableWarn:    "%s is declared but not used",
+	UnusedImportWarn:      "namespace %s is imported but not used",
+	ShadowingWarn:         "%s shadows declaration with the same name",
+	UnreachableCodeWarn:   "unreachable code",
+	IncompleteBindingWarn: "%s can't be bound: %s",
+	LeakWarn:              "%s is not destroyed on every path",
+	DoubleDestroyWarn:     "%s is destroyed twice",
+	UseAfterDestroyWarn:   "%s is used after it is destroyed",
+	DestroyNotHeapWarn:    "%s is not allocated with new",
+	IncompleteDestroyWarn: "destroy of %s doesn't destroy member %s",
+}
+
+// warning_names are names of warnings used in `-W` flags and suppression
+// comments.
+var warning_names = map[int]string{
+	UnusedImportWarn:      "unused-import",
+	ShadowingWarn:         "shadowing",
+	UnreachableCodeWarn:   "unreachable-code",
+	IncompleteBindingWarn: "incomplete-binding",
+	LeakWarn:              "leak",
+	DoubleDestroyWarn:     "double-destroy",
+	UseAfterDestroyWarn:   "use-after-destroy",
+	DestroyNotHeapWarn:    "destroy-not-heap",
+	IncompleteDestroyWarn: "incomplete-destroy",
+}
+
+// WarningName returns name of the warning, like `unused-import`.
+func WarningName(code int) string {
+	return warning_names[code]
+}
+
+// ParseWarningName returns code of the warning with the given name.
+func ParseWarningName(name string) (int, error) {
+	for code, warningName := range warning_names {
+		if warningName == name {
+			return code, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown warning %q", name)
+}
+
+// WarningID returns public code of the warning, like `W0001`.
+func WarningID(code int) string {
+	return fmt.Sprintf("W%04d", code)
+}
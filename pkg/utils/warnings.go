@@ -0,0 +1,173 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"bytes"
+	"strings"
+)
+
+// warnings, which are not reported unless enabled with `-W<name>`
+var disabledByDefault = map[int]bool{
+	ShadowingWarn: true,
+}
+
+// WarningSettings tells which warnings are reported and whether they are
+// treated as errors.
+type WarningSettings struct {
+	// warnings enabled or disabled explicitly
+	enabled map[int]bool
+
+	errors bool
+}
+
+// NewWarningSettings returns settings, which report warnings enabled by
+// default and don't treat them as errors.
+func NewWarningSettings() *WarningSettings {
+	return &WarningSettings{enabled: map[int]bool{}}
+}
+
+// Set applies option of `-W` flag: `<name>` enables the warning, `no-<name>`
+// disables it, `all` enables all warnings, `error` makes warnings errors and
+// `no-error` makes them warnings again.
+func (s *WarningSettings) Set(option string) error {
+	if option == "error" || option == "no-error" {
+		s.errors = option == "error"
+		return nil
+	}
+
+	enable := !strings.HasPrefix(option, "no-")
+	name := strings.TrimPrefix(option, "no-")
+
+	if name == "all" {
+		for code := range warning_names {
+			s.enabled[code] = enable
+		}
+
+		return nil
+	}
+
+	code, err := ParseWarningName(name)
+	if err != nil {
+		return err
+	}
+
+	s.enabled[code] = enable
+	return nil
+}
+
+// Enabled reports whether the warning is reported.
+func (s *WarningSettings) Enabled(code int) bool {
+	if enabled, ok := s.enabled[code]; ok {
+		return enabled
+	}
+
+	return !disabledByDefault[code]
+}
+
+// WarningsAreErrors reports whether warnings fail compilation (`-Werror`).
+func (s *WarningSettings) WarningsAreErrors() bool {
+	return s.errors
+}
+
+// suppressionPrefix starts comments, which suppress warnings:
+//
+//	import "util"; // tinyc:ignore unused-import
+//
+//	// tinyc:ignore shadowing, unreachable-code
+//	fun f<Account>() {}
+//
+// Comment suppresses listed warnings (or all warnings if none are listed)
+// on its line or, if the comment is the only thing on its line, on the next
+// line.
+const suppressionPrefix = "tinyc:ignore"
+
+// suppressed reports whether the warning is suppressed by a comment in the
+// source of its file.
+func (h *CodeProblemHandler) suppressed(problem *CodeProblem) bool {
+	if problem.global {
+		return false
+	}
+
+	source := h.source
+	if file := h.files.File(problem.location.StartLocation.Filepath); file != nil {
+		source = file.Source()
+	}
+
+	index := problem.location.StartLocation.Index
+	if index > len(source) {
+		return false
+	}
+
+	start := bytes.LastIndexByte(source[:index], '\n') + 1
+	end := bytes.IndexByte(source[index:], '\n')
+	if end < 0 {
+		end = len(source)
+	} else {
+		end += index
+	}
+
+	if suppresses(string(source[start:end]), problem.code, false) {
+		return true
+	}
+
+	if start == 0 {
+		return false
+	}
+
+	previous := bytes.LastIndexByte(source[:start-1], '\n') + 1
+	return suppresses(string(source[previous:start-1]), problem.code, true)
+}
+
+// suppresses reports whether the line has comment suppressing the warning.
+// If alone is true, the line must contain only the comment.
+func suppresses(line string, code int, alone bool) bool {
+	i := strings.Index(line, "//")
+	if i < 0 || alone && strings.TrimSpace(line[:i]) != "" {
+		return false
+	}
+
+	comment := strings.TrimSpace(line[i+2:])
+	if !strings.HasPrefix(comment, suppressionPrefix) {
+		return false
+	}
+
+	comment = strings.TrimPrefix(comment, suppressionPrefix)
+	if comment != "" && comment[0] != ' ' && comment[0] != '\t' {
+		return false
+	}
+
+	names := strings.FieldsFunc(comment,
+		func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		if name == WarningName(code) {
+			return true
+		}
+	}
+
+	return false
+}
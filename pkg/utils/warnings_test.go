@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2022 Adi Salimgereev
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarningSettings(t *testing.T) {
+	s := NewWarningSettings()
+	assert.True(t, s.Enabled(UnusedImportWarn))
+	assert.False(t, s.Enabled(ShadowingWarn))
+	assert.False(t, s.WarningsAreErrors())
+
+	assert.NoError(t, s.Set("shadowing"))
+	assert.NoError(t, s.Set("no-unused-import"))
+	assert.NoError(t, s.Set("error"))
+	assert.True(t, s.Enabled(ShadowingWarn))
+	assert.False(t, s.Enabled(UnusedImportWarn))
+	assert.True(t, s.WarningsAreErrors())
+
+	assert.NoError(t, s.Set("no-all"))
+	for code := range warning_names {
+		assert.False(t, s.Enabled(code))
+	}
+
+	assert.NoError(t, s.Set("no-error"))
+	assert.False(t, s.WarningsAreErrors())
+
+	assert.Error(t, s.Set("unused"))
+	assert.Error(t, s.Set("no-"))
+
+	// reserved warning can't be enabled
+	assert.Error(t, s.Set("unused-variable"))
+}
+
+func TestWarnings(t *testing.T) {
+	source := "import \"a\"; // tinyc:ignore unused-import\n" +
+		"import \"b\"; // tinyc:ignore shadowing\n" +
+		"  // tinyc:ignore\n" +
+		"import \"c\";\n" +
+		"import \"d\"; // tinyc:ignored\r\n"
+
+	h := NewCodeProblemHandler()
+	h.SetSource([]byte(source))
+
+	// report warning at the path of every import
+	index := 0
+	for i, line := range strings.SplitAfter(source, "\n")[:5] {
+		location := &CodePointLocation{Index: index + 7, Line: i + 1, Column: 7}
+		h.AddCodeProblem(NewLocalWarning(
+			&CodeBlockLocation{StartLocation: location, EndLocation: location.NextByteLocation()},
+			UnusedImportWarn, "x"))
+
+		index += len(line)
+	}
+
+	// only warnings on the second and the last lines are not suppressed
+	assert.True(t, h.Ok)
+	assert.Equal(t, 2, len(h.Problems()))
+	assert.Equal(t, 2, h.Problems()[0].Location().StartLocation.Line)
+	assert.Equal(t, 5, h.Problems()[1].Location().StartLocation.Line)
+
+	settings := NewWarningSettings()
+	settings.Set("error")
+
+	h = NewCodeProblemHandler()
+	h.SetWarningSettings(settings)
+	h.AddCodeProblem(NewGlobalWarning(UnusedImportWarn, "x"))
+	assert.False(t, h.Ok)
+
+	settings.Set("no-unused-import")
+
+	h = NewCodeProblemHandler()
+	h.SetWarningSettings(settings)
+	h.AddCodeProblem(NewGlobalWarning(UnusedImportWarn, "x"))
+	assert.True(t, h.Ok)
+	assert.Empty(t, h.Problems())
+}